@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// commands are the offline subcommands of the binary. Each returns the process
+// exit code. Without a subcommand the binary runs the manager.
+var commands = map[string]func(args []string) int{
+	"eval":         runEval,
+	"explain":      runExplain,
+	"gatus-render": runGatusRender,
+	"lint":         runLint,
+	"replay":       runReplay,
+	"test":         runTest,
+}
+
+func runCommand(name string, args []string) int {
+	command, ok := commands[name]
+	if !ok {
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected one of: %s\n", name, strings.Join(names, ", "))
+		return 2
+	}
+	return command(args)
+}
+
+// newCommandFlagSet returns the flag set for a subcommand, with the zap flags
+// bound so that policy logs can be turned up. Logs only show errors by default
+// to keep the command output readable.
+func newCommandFlagSet(name string, usage string) (*flag.FlagSet, *zap.Options) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s\n\n", os.Args[0], usage)
+		flags.PrintDefaults()
+	}
+
+	opts := &zap.Options{
+		Level: zapcore.ErrorLevel,
+	}
+	opts.BindFlags(flags)
+	return flags, opts
+}
+
+func setupCommandLogger(opts *zap.Options) {
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(opts), zap.WriteTo(os.Stderr)))
+}
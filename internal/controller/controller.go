@@ -5,32 +5,34 @@ import (
 	"os"
 	"strconv"
 
-	"github.com/aumer-amr/k8s-policy-control/internal/policy"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	client "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 var (
-	controllerLog              = ctrl.Log.WithName("controller")
-	controllerTriggerUid       = "policy-control.aumer.io/controller-trigger-uid"
-	controllerTriggerGroup     = "policy-control.aumer.io/controller-trigger-group"
-	controllerTriggerKind      = "policy-control.aumer.io/controller-trigger-kind"
-	controllerTriggerVersion   = "policy-control.aumer.io/controller-trigger-version"
-	controllerTriggerNamespace = "policy-control.aumer.io/controller-trigger-namespace"
+	controllerLog = ctrl.Log.WithName("controller")
 )
 
+// controllerNamePrefix is followed by the policy type in the controller names.
+const controllerNamePrefix = "controller-policy-"
+
 type ReconcilerHandler struct {
 	Client     client.Client
 	PolicyType int
 	Manager    ctrl.Manager
 	Controller controller.Controller
+	Recorder   record.EventRecorder
 }
 
 func New(mgr ctrl.Manager, policyType int) *ReconcilerHandler {
@@ -44,11 +46,12 @@ func New(mgr ctrl.Manager, policyType int) *ReconcilerHandler {
 }
 
 func (r *ReconcilerHandler) SetupWithManager(mgr ctrl.Manager) {
-	c, err := controller.New("controller-policy-"+strconv.Itoa(r.PolicyType), mgr, controller.Options{
+	c, err := controller.New(controllerNamePrefix+strconv.Itoa(r.PolicyType), mgr, controller.Options{
 		Reconciler: &ReconcilerHandler{
 			Client:     mgr.GetClient(),
 			PolicyType: r.PolicyType,
 			Manager:    mgr,
+			Recorder:   mgr.GetEventRecorderFor("policy-control"),
 		},
 	})
 	if err != nil {
@@ -64,39 +67,76 @@ func (r *ReconcilerHandler) SetupWithManager(mgr ctrl.Manager) {
 		r.WatchResource(mgr, &networkingv1.Ingress{}, controllerLog)
 	} else if r.PolicyType == policy.PolicyTypePod {
 		r.WatchResource(mgr, &corev1.Pod{}, controllerLog)
+	} else if r.PolicyType == policy.PolicyTypeService {
+		r.WatchResource(mgr, &corev1.Service{}, controllerLog)
+	} else if r.PolicyType == policy.PolicyTypeNamespace {
+		r.WatchResource(mgr, &corev1.Namespace{}, controllerLog)
+		r.WatchServiceAccounts(mgr)
 	} else if r.PolicyType == policy.PolicyTypeUnknown {
 		controllerLog.Info("PolicyType is unknown, not watching any resources")
 	}
 }
 
 func (r *ReconcilerHandler) WatchResource(mgr ctrl.Manager, resourceType client.Object, log logr.Logger) {
-	if err := r.Controller.Watch(source.Kind(mgr.GetCache(), resourceType), &handler.EnqueueRequestForObject{}); err != nil {
+	addWatchedType(resourceType)
+	predicates := policyPredicate(r.PolicyType)
+
+	if err := r.Controller.Watch(source.Kind(mgr.GetCache(), resourceType), &handler.EnqueueRequestForObject{}, predicates); err != nil {
 		log.Error(err, "unable to watch resource")
 		os.Exit(1)
 	}
 
 	if err := r.Controller.Watch(source.Kind(mgr.GetCache(), resourceType),
-		handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), resourceType, handler.OnlyControllerOwner())); err != nil {
+		handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), resourceType, handler.OnlyControllerOwner()), predicates); err != nil {
 		log.Error(err, "unable to watch Pods")
 		os.Exit(1)
 	}
+
+	parentGVK, err := apiutil.GVKForObject(resourceType, mgr.GetScheme())
+	if err != nil {
+		log.Error(err, "unable to resolve resource kind")
+		os.Exit(1)
+	}
+	r.WatchTriggers(mgr, parentGVK)
+	r.WatchDependencies(mgr, resourceType, parentGVK)
+	r.WatchSources(mgr, parentGVK)
+	// Namespaces are the parent themselves, their annotations are watched above.
+	if _, ok := resourceType.(*corev1.Namespace); !ok {
+		r.WatchNamespaces(mgr, parentGVK)
+	}
 }
 
 func (r *ReconcilerHandler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	controllerLog.Info("Reconciling", "request", req)
 
+	key := strconv.Itoa(r.PolicyType) + "/" + req.String()
+	progress.start(key)
+	defer progress.done(key)
+
 	if r.PolicyType == policy.PolicyTypeIngress {
 		return r.reconcileIngress(ctx, req, controllerLog)
 	} else if r.PolicyType == policy.PolicyTypePod {
 		//return r.reconcilePod(ctx, req, controllerLog)
 		return ctrl.Result{}, nil
+	} else if r.PolicyType == policy.PolicyTypeService {
+		return r.reconcileService(ctx, req, controllerLog)
+	} else if r.PolicyType == policy.PolicyTypeNamespace {
+		return r.reconcileNamespace(ctx, req, controllerLog)
 	} else {
 		return ctrl.Result{}, nil
 	}
 }
 
-func (r *ReconcilerHandler) checkCache(ctx context.Context, namespacedName string, typed client.Object) (error, bool) {
-	err := r.Client.Get(ctx, client.ObjectKey{Name: namespacedName}, typed)
+// policyEnv is the Env policies run with, Events are recorded on the object
+// being reconciled.
+func (r *ReconcilerHandler) policyEnv() *policy.Env {
+	env := policy.NewEnv(r.Client)
+	env.Recorder = r.Recorder
+	return env
+}
+
+func (r *ReconcilerHandler) checkCache(ctx context.Context, namespacedName types.NamespacedName, typed client.Object) (error, bool) {
+	err := r.Client.Get(ctx, namespacedName, typed)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			controllerLog.Info("Cache miss", "namespacedName", namespacedName)
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	watchedMu    sync.Mutex
+	watchedTypes = map[reflect.Type]client.Object{}
+	progress     = &reconcileProgress{inFlight: map[string]time.Time{}, lastDone: time.Now()}
+	// queueDepth is swapped out in tests.
+	queueDepth = workqueueDepth
+)
+
+// reconcileProgress tracks when each running reconcile started, and when the
+// last one finished.
+type reconcileProgress struct {
+	mu       sync.Mutex
+	inFlight map[string]time.Time
+	lastDone time.Time
+}
+
+func (p *reconcileProgress) start(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight[key] = time.Now()
+}
+
+func (p *reconcileProgress) done(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.inFlight, key)
+	p.lastDone = time.Now()
+}
+
+// sinceDone is how long ago the last reconcile finished, or the process started
+// if none has.
+func (p *reconcileProgress) sinceDone() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return time.Since(p.lastDone)
+}
+
+func (p *reconcileProgress) oldest() (string, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var oldestKey string
+	var oldestAge time.Duration
+	for key, started := range p.inFlight {
+		if age := time.Since(started); age > oldestAge {
+			oldestKey, oldestAge = key, age
+		}
+	}
+	return oldestKey, oldestAge
+}
+
+func addWatchedType(resourceType client.Object) {
+	watchedMu.Lock()
+	defer watchedMu.Unlock()
+	watchedTypes[reflect.TypeOf(resourceType)] = resourceType
+}
+
+// CacheSyncedCheck is ready once the informer of every kind watched by a
+// controller has synced.
+func CacheSyncedCheck(c cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		watchedMu.Lock()
+		defer watchedMu.Unlock()
+
+		for _, resourceType := range watchedTypes {
+			informer, err := c.GetInformer(req.Context(), resourceType, cache.BlockUntilSynced(false))
+			if err != nil {
+				return fmt.Errorf("no informer for %T: %w", resourceType, err)
+			}
+			if !informer.HasSynced() {
+				return fmt.Errorf("cache for %T not synced", resourceType)
+			}
+		}
+		return nil
+	}
+}
+
+// ReconcileProgressCheck fails when a single reconcile has been running for
+// longer than timeout, which means the worker is stuck, or when requests are
+// queued and no reconcile finished within timeout, which means no worker picks
+// them up.
+func ReconcileProgressCheck(timeout time.Duration) healthz.Checker {
+	return func(_ *http.Request) error {
+		if key, age := progress.oldest(); age > timeout {
+			return fmt.Errorf("reconcile of %s running for %s", key, age.Round(time.Second))
+		}
+		if depth := queueDepth(); depth > 0 {
+			if idle := progress.sinceDone(); idle > timeout {
+				return fmt.Errorf("%d requests queued and no reconcile finished for %s", depth, idle.Round(time.Second))
+			}
+		}
+		return nil
+	}
+}
+
+// workqueueDepth sums the depth of the work queues of the policy controllers,
+// as reported by the controller-runtime metrics.
+func workqueueDepth() int {
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		controllerLog.Error(err, "unable to read work queue metrics")
+		return 0
+	}
+
+	depth := 0
+	for _, family := range families {
+		if family.GetName() != "workqueue_"+metrics.DepthKey {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "name" && strings.HasPrefix(label.GetValue(), controllerNamePrefix) {
+					depth += int(metric.GetGauge().GetValue())
+				}
+			}
+		}
+	}
+	return depth
+}
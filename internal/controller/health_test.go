@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestReconcileProgressCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		inFlight map[string]time.Time
+		lastDone time.Duration
+		depth    int
+		wantErr  bool
+	}{
+		{name: "idle", lastDone: time.Hour},
+		{name: "making progress", lastDone: time.Second, depth: 10},
+		{name: "stuck reconcile", inFlight: map[string]time.Time{"1/default/web": time.Now().Add(-time.Hour)}, lastDone: time.Second, wantErr: true},
+		{name: "queue not drained", lastDone: time.Hour, depth: 3, wantErr: true},
+	}
+
+	saved := queueDepth
+	t.Cleanup(func() {
+		queueDepth = saved
+		progress = &reconcileProgress{inFlight: map[string]time.Time{}, lastDone: time.Now()}
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inFlight := tt.inFlight
+			if inFlight == nil {
+				inFlight = map[string]time.Time{}
+			}
+			progress = &reconcileProgress{inFlight: inFlight, lastDone: time.Now().Add(-tt.lastDone)}
+			depth := tt.depth
+			queueDepth = func() int { return depth }
+
+			err := ReconcileProgressCheck(time.Minute)(nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("check error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWorkqueueDepth(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueueWithConfig(workqueue.DefaultControllerRateLimiter(), workqueue.RateLimitingQueueConfig{Name: controllerNamePrefix + "test"})
+	defer queue.ShutDown()
+	other := workqueue.NewRateLimitingQueueWithConfig(workqueue.DefaultControllerRateLimiter(), workqueue.RateLimitingQueueConfig{Name: "other"})
+	defer other.ShutDown()
+
+	queue.Add("default/a")
+	queue.Add("default/b")
+	other.Add("default/c")
+
+	if got := workqueueDepth(); got != 2 {
+		t.Errorf("workqueueDepth() = %d, want 2", got)
+	}
+}
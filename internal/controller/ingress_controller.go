@@ -2,9 +2,8 @@ package controller
 
 import (
 	"context"
-	"fmt"
 
-	"github.com/aumer-amr/k8s-policy-control/internal/policy"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
 	"github.com/go-logr/logr"
 	networkingv1 "k8s.io/api/networking/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -12,18 +11,18 @@ import (
 
 func (r *ReconcilerHandler) reconcileIngress(ctx context.Context, req ctrl.Request, controllerLog logr.Logger) (ctrl.Result, error) {
 	ingress := &networkingv1.Ingress{}
-	err, cacheMiss := r.checkCache(ctx, req.NamespacedName.String(), ingress)
+	err, cacheMiss := r.checkCache(ctx, req.NamespacedName, ingress)
 
 	if err != nil {
 		if cacheMiss {
-			fmt.Println("reconcileIngress cache miss")
+			controllerLog.Info("Ingress not found, skipping", "request", req)
 			return ctrl.Result{}, nil
 		}
-		fmt.Println("reconcileIngress error", err)
+		controllerLog.Error(err, "Failed to get Ingress", "request", req)
 		return ctrl.Result{}, err
 	}
 
-	policy.ApplyPoliciesByType(policy.PolicyTypeIngress, ingress, r.Manager)
+	policy.ApplyControllerPolicies(policy.PolicyTypeIngress, ingress, r.policyEnv())
 
 	return ctrl.Result{}, nil
 }
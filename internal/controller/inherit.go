@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"context"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// WatchNamespaces requeues every object of the parent kind in a Namespace when
+// the policy annotations of the Namespace change, since objects inherit them.
+func (r *ReconcilerHandler) WatchNamespaces(mgr ctrl.Manager, parentGVK schema.GroupVersionKind) {
+	annotationsChanged := predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc:  policyAnnotationChangedPredicate().UpdateFunc,
+	}
+
+	mapNamespace := handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		newList, err := mgr.GetScheme().New(parentGVK.GroupVersion().WithKind(parentGVK.Kind + "List"))
+		if err != nil {
+			controllerLog.Error(err, "unable to resolve list kind", "kind", parentGVK.Kind)
+			return nil
+		}
+		list := newList.(client.ObjectList)
+		if err := mgr.GetClient().List(ctx, list, client.InNamespace(obj.GetName())); err != nil {
+			controllerLog.Error(err, "unable to list objects inheriting Namespace annotations", "namespace", obj.GetName())
+			return nil
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			controllerLog.Error(err, "unable to read list", "kind", parentGVK.Kind)
+			return nil
+		}
+
+		requests := make([]ctrl.Request, 0, len(items))
+		for _, item := range items {
+			if itemObj, ok := item.(client.Object); ok {
+				requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(itemObj)})
+			}
+		}
+		controllerLog.Info("Namespace annotations changed, requeueing objects", "namespace", obj.GetName(), "count", len(requests))
+		return requests
+	})
+
+	addWatchedType(&corev1.Namespace{})
+	if err := r.Controller.Watch(source.Kind(mgr.GetCache(), &corev1.Namespace{}), mapNamespace, annotationsChanged); err != nil {
+		controllerLog.Error(err, "unable to watch Namespaces")
+		os.Exit(1)
+	}
+}
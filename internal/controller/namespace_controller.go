@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"context"
+	"os"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+func (r *ReconcilerHandler) reconcileNamespace(ctx context.Context, req ctrl.Request, controllerLog logr.Logger) (ctrl.Result, error) {
+	namespace := &corev1.Namespace{}
+	err, cacheMiss := r.checkCache(ctx, req.NamespacedName, namespace)
+
+	if err != nil {
+		if cacheMiss {
+			controllerLog.Info("Namespace not found, skipping", "request", req)
+			return ctrl.Result{}, nil
+		}
+		controllerLog.Error(err, "Failed to get Namespace", "request", req)
+		return ctrl.Result{}, err
+	}
+
+	policy.ApplyControllerPolicies(policy.PolicyTypeNamespace, namespace, r.policyEnv())
+
+	return ctrl.Result{}, nil
+}
+
+// WatchServiceAccounts requeues a Namespace when its default ServiceAccount
+// changes, which is usually created after the Namespace itself.
+func (r *ReconcilerHandler) WatchServiceAccounts(mgr ctrl.Manager) {
+	isDefault := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetName() == "default"
+	})
+
+	mapServiceAccount := handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		return []ctrl.Request{{NamespacedName: client.ObjectKey{Name: obj.GetNamespace()}}}
+	})
+
+	addWatchedType(&corev1.ServiceAccount{})
+	if err := r.Controller.Watch(source.Kind(mgr.GetCache(), &corev1.ServiceAccount{}), mapServiceAccount, isDefault); err != nil {
+		controllerLog.Error(err, "unable to watch ServiceAccounts")
+		os.Exit(1)
+	}
+}
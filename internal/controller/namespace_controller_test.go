@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileNamespaceCopiesImagePullSecret(t *testing.T) {
+	cfg := config.Default()
+	cfg.Policies = map[string]config.PolicyConfig{
+		"namespace-image-pull-secret": {Settings: map[string]string{"secret": "registry/regcred"}},
+	}
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "registry"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", UID: "team-a-uid"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "default"}},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "registry", Name: "regcred"},
+			Type:       corev1.SecretTypeDockerConfigJson,
+			Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")},
+		},
+	).Build()
+
+	r := &ReconcilerHandler{Client: c, PolicyType: policy.PolicyTypeNamespace}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "team-a"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	copied := &corev1.Secret{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "regcred"}, copied); err != nil {
+		t.Fatalf("copy of the image pull Secret not created: %v", err)
+	}
+	if got := copied.Labels[cfg.Labels.ParentUid]; got != "team-a-uid" {
+		t.Errorf("copy parent label = %q, want team-a-uid", got)
+	}
+
+	serviceAccount := &corev1.ServiceAccount{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "default"}, serviceAccount); err != nil {
+		t.Fatal(err)
+	}
+	if len(serviceAccount.ImagePullSecrets) != 1 || serviceAccount.ImagePullSecrets[0].Name != "regcred" {
+		t.Errorf("default ServiceAccount imagePullSecrets = %v, want [regcred]", serviceAccount.ImagePullSecrets)
+	}
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// policyPredicate filters out updates the policies of the given type don't care
+// about, such as status churn. Creates, deletes and generic events always pass.
+func policyPredicate(policyType int) predicate.Predicate {
+	return predicate.Or(
+		predicate.GenerationChangedPredicate{},
+		policyAnnotationChangedPredicate(),
+		deletionPredicate(),
+		watchedFieldsPredicate(policy.WatchedFieldsByType(policyType)),
+	)
+}
+
+// policyAnnotationChangedPredicate passes updates that touch an annotation under
+// the policy annotation prefix.
+func policyAnnotationChangedPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			return !reflect.DeepEqual(
+				policyAnnotations(e.ObjectOld.GetAnnotations()),
+				policyAnnotations(e.ObjectNew.GetAnnotations()),
+			)
+		},
+	}
+}
+
+// deletionPredicate passes updates that mark the object for deletion.
+func deletionPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			return e.ObjectOld.GetDeletionTimestamp().IsZero() && !e.ObjectNew.GetDeletionTimestamp().IsZero()
+		},
+	}
+}
+
+// watchedFieldsPredicate passes updates that change any of the given dotted field
+// paths. With no fields it never passes an update.
+func watchedFieldsPredicate(fields []string) predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if len(fields) == 0 || e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+
+			oldObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(e.ObjectOld)
+			if err != nil {
+				controllerLog.Error(err, "unable to convert old object", "object", client.ObjectKeyFromObject(e.ObjectOld))
+				return true
+			}
+			newObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(e.ObjectNew)
+			if err != nil {
+				controllerLog.Error(err, "unable to convert new object", "object", client.ObjectKeyFromObject(e.ObjectNew))
+				return true
+			}
+
+			for _, field := range fields {
+				path := strings.Split(field, ".")
+				oldValue, _, _ := unstructured.NestedFieldNoCopy(oldObj, path...)
+				newValue, _, _ := unstructured.NestedFieldNoCopy(newObj, path...)
+				if !reflect.DeepEqual(oldValue, newValue) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+func policyAnnotations(annotations map[string]string) map[string]string {
+	cfg := config.Current()
+	filtered := map[string]string{}
+	for key, value := range annotations {
+		if _, _, ok := cfg.SplitAnnotationKey(key); ok {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestPolicyPredicateServiceUpdates(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Generation: 1},
+		Spec:       corev1.ServiceSpec{ClusterIP: "10.0.0.10", Ports: []corev1.ServicePort{{Port: 80}}},
+	}
+
+	tests := []struct {
+		name   string
+		update func(service *corev1.Service)
+		want   bool
+	}{
+		{
+			name:   "port change",
+			update: func(service *corev1.Service) { service.Spec.Ports[0].Port = 8080 },
+			want:   true,
+		},
+		{
+			name:   "cluster IP change",
+			update: func(service *corev1.Service) { service.Spec.ClusterIP = "10.0.0.11" },
+			want:   true,
+		},
+		{
+			name: "policy annotation change",
+			update: func(service *corev1.Service) {
+				service.Annotations = map[string]string{"policy-control.aumer.io/gatus-generate-service": "true"}
+			},
+			want: true,
+		},
+		{
+			name: "status change",
+			update: func(service *corev1.Service) {
+				service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "192.0.2.1"}}
+			},
+		},
+		{
+			name:   "unrelated label change",
+			update: func(service *corev1.Service) { service.Labels = map[string]string{"app": "web"} },
+		},
+	}
+
+	p := policyPredicate(policy.PolicyTypeService)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := service.DeepCopy()
+			tt.update(updated)
+			if got := p.Update(event.UpdateEvent{ObjectOld: service, ObjectNew: updated}); got != tt.want {
+				t.Errorf("Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
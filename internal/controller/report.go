@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	reportLog = ctrl.Log.WithName("report")
+)
+
+// reportPageSize bounds how many Pods are listed from the API server at once.
+const reportPageSize = 500
+
+// Reporter runs the admission policies in audit mode against the existing Pods,
+// on start and then every Interval. Pods admitted before a policy was added or
+// while it was in audit mode show up in the audit log and get a Warning Event,
+// without being changed.
+type Reporter struct {
+	Client client.Client
+	// Reader lists Pods straight from the API server, so that not every Pod in
+	// the cluster has to be cached.
+	Reader   client.Reader
+	Recorder record.EventRecorder
+	Interval time.Duration
+}
+
+func NewReporter(mgr ctrl.Manager, interval time.Duration) *Reporter {
+	return &Reporter{
+		Client:   mgr.GetClient(),
+		Reader:   mgr.GetAPIReader(),
+		Recorder: mgr.GetEventRecorderFor("policy-control"),
+		Interval: interval,
+	}
+}
+
+func (r *Reporter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.report(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Reporter) report(ctx context.Context) {
+	env := policy.NewEnv(r.Client)
+	env.Recorder = r.Recorder
+
+	pods, violators := 0, 0
+	opts := []client.ListOption{client.Limit(reportPageSize)}
+	for {
+		list := &corev1.PodList{}
+		if err := r.Reader.List(ctx, list, opts...); err != nil {
+			reportLog.Error(err, "unable to list Pods")
+			return
+		}
+
+		for i := range list.Items {
+			pods++
+			if len(policy.ReportViolations(policy.PolicyTypePod, &list.Items[i], env)) > 0 {
+				violators++
+			}
+		}
+
+		if list.Continue == "" {
+			break
+		}
+		opts = []client.ListOption{client.Limit(reportPageSize), client.Continue(list.Continue)}
+	}
+	reportLog.Info("Reported existing Pods violating audited policies", "pods", pods, "violators", violators)
+}
@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func (r *ReconcilerHandler) reconcileService(ctx context.Context, req ctrl.Request, controllerLog logr.Logger) (ctrl.Result, error) {
+	service := &corev1.Service{}
+	err, cacheMiss := r.checkCache(ctx, req.NamespacedName, service)
+
+	if err != nil {
+		if cacheMiss {
+			controllerLog.Info("Service not found, skipping", "request", req)
+			return ctrl.Result{}, nil
+		}
+		controllerLog.Error(err, "Failed to get Service", "request", req)
+		return ctrl.Result{}, err
+	}
+
+	policy.ApplyControllerPolicies(policy.PolicyTypeService, service, r.policyEnv())
+
+	return ctrl.Result{}, nil
+}
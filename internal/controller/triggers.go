@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"os"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/util"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// triggerResourceTypes are the kinds that can re-reconcile a parent through the
+// controller trigger annotations.
+var triggerResourceTypes = []client.Object{
+	&corev1.ConfigMap{},
+	&corev1.Secret{},
+	&corev1.Service{},
+}
+
+// WatchTriggers watches every trigger resource type and enqueues the parent
+// recorded in its trigger annotations by generated objects, as long as the parent is of the kind this
+// controller reconciles.
+func (r *ReconcilerHandler) WatchTriggers(mgr ctrl.Manager, parentGVK schema.GroupVersionKind) {
+	hasTrigger := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := util.GetControllerTrigger(obj)
+		return ok
+	})
+
+	mapTrigger := handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		trigger, ok := util.GetControllerTrigger(obj)
+		if !ok || trigger.GroupVersionKind().GroupKind() != parentGVK.GroupKind() {
+			return nil
+		}
+
+		controllerLog.Info("Trigger changed, requeueing parent", "trigger", client.ObjectKeyFromObject(obj), "parent", trigger.ObjectKey())
+		return []ctrl.Request{{NamespacedName: trigger.ObjectKey()}}
+	})
+
+	for _, resourceType := range triggerResourceTypes {
+		addWatchedType(resourceType)
+		if err := r.Controller.Watch(source.Kind(mgr.GetCache(), resourceType), mapTrigger, hasTrigger); err != nil {
+			controllerLog.Error(err, "unable to watch trigger resource")
+			os.Exit(1)
+		}
+	}
+}
+
+// dependencyIndex indexes parents by the objects their policies depend on, as
+// returned by dependencyKey. Any number of parents can share a dependency.
+const dependencyIndex = "policy-control.aumer.io/dependencies"
+
+// WatchDependencies indexes every parent by its dependencies and watches every
+// trigger resource type, enqueueing the parents that depend on the changed
+// object.
+func (r *ReconcilerHandler) WatchDependencies(mgr ctrl.Manager, resourceType client.Object, parentGVK schema.GroupVersionKind) {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), resourceType, dependencyIndex, r.dependencyKeys); err != nil {
+		controllerLog.Error(err, "unable to index dependencies")
+		os.Exit(1)
+	}
+
+	mapDependency := handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		return r.dependentRequests(ctx, parentGVK, obj)
+	})
+
+	for _, resourceType := range triggerResourceTypes {
+		addWatchedType(resourceType)
+		if err := r.Controller.Watch(source.Kind(mgr.GetCache(), resourceType), mapDependency); err != nil {
+			controllerLog.Error(err, "unable to watch dependency resource")
+			os.Exit(1)
+		}
+	}
+}
+
+// dependencyKeys returns the index keys of every object the policies of this
+// controller depend on for parent.
+func (r *ReconcilerHandler) dependencyKeys(parent client.Object) []string {
+	var keys []string
+	for _, dependency := range policy.DependenciesByType(r.PolicyType, parent) {
+		key, err := dependencyKey(r.Client.Scheme(), dependency)
+		if err != nil {
+			controllerLog.Error(err, "unable to resolve dependency kind", "parent", client.ObjectKeyFromObject(parent))
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// dependentRequests lists the parents depending on obj through the dependency
+// index.
+func (r *ReconcilerHandler) dependentRequests(ctx context.Context, parentGVK schema.GroupVersionKind, obj client.Object) []ctrl.Request {
+	key, err := dependencyKey(r.Client.Scheme(), obj)
+	if err != nil {
+		return nil
+	}
+
+	newList, err := r.Client.Scheme().New(parentGVK.GroupVersion().WithKind(parentGVK.Kind + "List"))
+	if err != nil {
+		controllerLog.Error(err, "unable to resolve list kind", "kind", parentGVK.Kind)
+		return nil
+	}
+	list := newList.(client.ObjectList)
+	if err := r.Client.List(ctx, list, client.MatchingFields{dependencyIndex: key}); err != nil {
+		controllerLog.Error(err, "unable to list objects depending on dependency", "dependency", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		controllerLog.Error(err, "unable to read list", "kind", parentGVK.Kind)
+		return nil
+	}
+
+	requests := make([]ctrl.Request, 0, len(items))
+	for _, item := range items {
+		if itemObj, ok := item.(client.Object); ok {
+			requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(itemObj)})
+		}
+	}
+	if len(requests) > 0 {
+		controllerLog.Info("Dependency changed, requeueing parents", "dependency", key, "count", len(requests))
+	}
+	return requests
+}
+
+// dependencyKey identifies obj in the dependency index, e.g. Secret/default/tls.
+func dependencyKey(scheme *runtime.Scheme, obj client.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return "", err
+	}
+	return gvk.GroupKind().String() + "/" + client.ObjectKeyFromObject(obj).String(), nil
+}
+
+// WatchSources watches every trigger resource type and enqueues all objects of
+// the parent kind when one of the sources the policies of this controller copy
+// from changes. Sources are read per event, so config reloads apply.
+func (r *ReconcilerHandler) WatchSources(mgr ctrl.Manager, parentGVK schema.GroupVersionKind) {
+	isSource := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		gvk, err := apiutil.GVKForObject(obj, mgr.GetScheme())
+		if err != nil {
+			return false
+		}
+		for _, source := range policy.SourcesByType(r.PolicyType) {
+			sourceGVK, err := apiutil.GVKForObject(source, mgr.GetScheme())
+			if err == nil && sourceGVK == gvk && client.ObjectKeyFromObject(source) == client.ObjectKeyFromObject(obj) {
+				return true
+			}
+		}
+		return false
+	})
+
+	mapSource := handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		newList, err := mgr.GetScheme().New(parentGVK.GroupVersion().WithKind(parentGVK.Kind + "List"))
+		if err != nil {
+			controllerLog.Error(err, "unable to resolve list kind", "kind", parentGVK.Kind)
+			return nil
+		}
+		list := newList.(client.ObjectList)
+		if err := mgr.GetClient().List(ctx, list); err != nil {
+			controllerLog.Error(err, "unable to list objects copying from source", "source", client.ObjectKeyFromObject(obj))
+			return nil
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			controllerLog.Error(err, "unable to read list", "kind", parentGVK.Kind)
+			return nil
+		}
+
+		requests := make([]ctrl.Request, 0, len(items))
+		for _, item := range items {
+			if itemObj, ok := item.(client.Object); ok {
+				requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(itemObj)})
+			}
+		}
+		controllerLog.Info("Source changed, requeueing objects", "source", client.ObjectKeyFromObject(obj), "count", len(requests))
+		return requests
+	})
+
+	for _, resourceType := range triggerResourceTypes {
+		addWatchedType(resourceType)
+		if err := r.Controller.Watch(source.Kind(mgr.GetCache(), resourceType), mapSource, isSource); err != nil {
+			controllerLog.Error(err, "unable to watch source resource")
+			os.Exit(1)
+		}
+	}
+}
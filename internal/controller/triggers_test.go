@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policytest"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDependentRequestsSharedDependency(t *testing.T) {
+	r := &ReconcilerHandler{PolicyType: policy.PolicyTypeIngress}
+	r.Client = fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithIndex(&networkingv1.Ingress{}, dependencyIndex, r.dependencyKeys).
+		Build()
+
+	for _, ingress := range []*networkingv1.Ingress{
+		policytest.Ingress("default", "site", "site", "wildcard-tls"),
+		policytest.Ingress("default", "docs", "site", "wildcard-tls"),
+		policytest.Ingress("default", "other", "other", "other-tls"),
+	} {
+		if err := r.Client.Create(context.Background(), ingress); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parentGVK := schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}
+	tests := []struct {
+		name       string
+		dependency client.Object
+		want       []string
+	}{
+		{
+			name:       "shared TLS Secret",
+			dependency: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "wildcard-tls"}},
+			want:       []string{"default/docs", "default/site"},
+		},
+		{
+			name:       "shared backend Service",
+			dependency: &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "site"}},
+			want:       []string{"default/docs", "default/site"},
+		},
+		{
+			name:       "Secret of the same name as a Service",
+			dependency: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "site"}},
+		},
+		{
+			name:       "other namespace",
+			dependency: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "wildcard-tls"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, request := range r.dependentRequests(context.Background(), parentGVK, tt.dependency) {
+				got = append(got, request.String())
+			}
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("requests = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("requests = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"github.com/aumer-amr/k8s-policy-control/pkg/eval"
+	"github.com/aumer-amr/k8s-policy-control/pkg/manifest"
+	"sigs.k8s.io/yaml"
+)
+
+// evalOutput is the document printed for every evaluated object.
+type evalOutput struct {
+	Source    string          `json:"source"`
+	Object    audit.ObjectRef `json:"object"`
+	Denied    bool            `json:"denied"`
+	Decisions []evalDecision  `json:"decisions"`
+	Patch     interface{}     `json:"patch,omitempty"`
+	Mutated   interface{}     `json:"mutated,omitempty"`
+	Generated []interface{}   `json:"generated,omitempty"`
+}
+
+type evalDecision struct {
+	Policy  string `json:"policy"`
+	Mode    string `json:"mode"`
+	Outcome string `json:"outcome"`
+	Message string `json:"message,omitempty"`
+}
+
+// runEval runs the compiled-in policies against local manifests without an API
+// server. It exits with 1 if any policy denied an object.
+func runEval(args []string) int {
+	flags, opts := newCommandFlagSet("eval", "eval [flags] [file ...]\n\nReads manifests from the given files, or stdin if none or - is given.")
+	var output string
+	var configPath string
+	flags.StringVar(&output, "o", "patch", "How to print mutations: patch for a JSON patch, yaml for the mutated object.")
+	flags.StringVar(&configPath, "config", "", "Path to the config file. Defaults are used if empty.")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if output != "patch" && output != "yaml" {
+		fmt.Fprintf(os.Stderr, "unknown output %q, expected patch or yaml\n", output)
+		return 2
+	}
+	setupCommandLogger(opts)
+
+	if err := loadCommandConfig(configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	objects, err := readManifests(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	results, err := eval.Run(scheme, objects)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	denied := false
+	for i, result := range results {
+		if i > 0 {
+			fmt.Fprintln(os.Stdout, "---")
+		}
+		if err := printEvalResult(os.Stdout, result, output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		denied = denied || result.Denied()
+	}
+
+	if denied {
+		return 1
+	}
+	return 0
+}
+
+func printEvalResult(w io.Writer, result eval.Result, output string) error {
+	out := evalOutput{
+		Source: result.Source,
+		Object: audit.NewObjectRef(result.Object, scheme),
+		Denied: result.Denied(),
+	}
+	for _, decision := range result.Decisions {
+		out.Decisions = append(out.Decisions, evalDecision{
+			Policy:  decision.Policy,
+			Mode:    decision.Mode,
+			Outcome: decision.Outcome,
+			Message: decision.Message,
+		})
+	}
+
+	if output == "yaml" {
+		out.Mutated = result.Mutated
+	} else if len(result.Patch) > 0 {
+		out.Patch = result.Patch
+	}
+	for _, generated := range result.Generated {
+		out.Generated = append(out.Generated, generated)
+	}
+
+	data, err := yaml.Marshal(out)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func readManifests(paths []string) ([]manifest.Object, error) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	var objects []manifest.Object
+	for _, path := range paths {
+		read, err := manifest.ReadFile(path, scheme)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, read...)
+	}
+	return objects, nil
+}
+
+func loadCommandConfig(configPath string) error {
+	if configPath == "" {
+		config.Set(config.Default())
+		return nil
+	}
+	return config.Load(configPath)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"sigs.k8s.io/yaml"
+)
+
+// runExplain lists every annotation the policies and the controller understand,
+// or only those of the given policy.
+func runExplain(args []string) int {
+	flags, opts := newCommandFlagSet("explain", "explain [flags] [policy]\n\nLists the annotations of the given policy, e.g. ingress-generate-gatus, or of every policy if none is given.")
+	var configPath string
+	var output string
+	flags.StringVar(&configPath, "config", "", "Path to the config file, used for the annotation prefix. Defaults are used if empty.")
+	flags.StringVar(&output, "o", "text", "Output format: text, json or yaml.")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if output != "text" && output != "json" && output != "yaml" {
+		fmt.Fprintf(os.Stderr, "unknown output %q, expected text, json or yaml\n", output)
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+	setupCommandLogger(opts)
+
+	if err := loadCommandConfig(configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	owner := flags.Arg(0)
+	schemas := annotation.Explain(owner)
+	if owner != "" && len(schemas) == 0 {
+		fmt.Fprintf(os.Stderr, "no annotations known for %q\n", owner)
+		return 2
+	}
+	if schemas == nil {
+		schemas = []annotation.Schema{}
+	}
+
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(schemas); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	case "yaml":
+		out, err := yaml.Marshal(schemas)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		os.Stdout.Write(out)
+	default:
+		for i, schema := range schemas {
+			if i > 0 {
+				fmt.Fprintln(os.Stdout)
+			}
+			fmt.Fprintln(os.Stdout, schema.Owner)
+			for _, key := range schema.Keys {
+				fmt.Fprintf(os.Stdout, "  %s (%s)\n", key.Key, describeType(key))
+				fmt.Fprintf(os.Stdout, "      %s\n", key.Description)
+			}
+		}
+	}
+	return 0
+}
+
+func describeType(key annotation.Key) string {
+	description := string(key.Type)
+	if len(key.Allowed) > 0 {
+		description += ": " + strings.Join(key.Allowed, "|")
+	}
+	if key.Default != "" {
+		description += fmt.Sprintf(", default %q", key.Default)
+	}
+	if key.Inherit {
+		description += ", inheritable"
+	}
+	return description
+}
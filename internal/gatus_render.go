@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/manifest"
+	"github.com/aumer-amr/k8s-policy-control/pkg/memory"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// runGatusRender merges the Gatus endpoints of every annotated Ingress and
+// Service in a directory of rendered manifests into one Gatus config.yaml.
+func runGatusRender(args []string) int {
+	flags, opts := newCommandFlagSet("gatus-render", "gatus-render [flags] [dir|file ...]\n\nWalks the given directories, or the current one, for .yaml, .yml and .json manifests.")
+	var output string
+	var configPath string
+	var alertsPath string
+	var groupByNamespace bool
+	flags.StringVar(&output, "o", "-", "File to write the Gatus config to, - for stdout.")
+	flags.StringVar(&configPath, "config", "", "Path to the config file. Defaults are used if empty.")
+	flags.StringVar(&alertsPath, "alerts", "", "YAML file with a list of Gatus alerts added to every endpoint.")
+	flags.BoolVar(&groupByNamespace, "group-by-namespace", false, "Group endpoints without a group annotation by their namespace.")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	setupCommandLogger(opts)
+
+	if err := loadCommandConfig(configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	renderOpts := policy.GatusRenderOptions{GroupByNamespace: groupByNamespace}
+	if alertsPath != "" {
+		data, err := os.ReadFile(alertsPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if err := yaml.Unmarshal(data, &renderOpts.Alerts); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", alertsPath, err)
+			return 2
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var objects []client.Object
+	for _, path := range paths {
+		read, err := readManifestTree(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		for _, obj := range read {
+			if obj.Typed {
+				objects = append(objects, obj.Object)
+			}
+		}
+	}
+
+	// Namespaces and owners among the manifests are read for inherited
+	// annotations.
+	var stored []client.Object
+	for _, obj := range objects {
+		stored = append(stored, obj.DeepCopyObject().(client.Object))
+	}
+	store, err := memory.NewClient(scheme, memory.NewRESTMapper(scheme), stored...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	rendered := policy.RenderGatusConfig(policy.NewEnv(store), objects, renderOpts)
+	if output == "-" {
+		fmt.Fprint(os.Stdout, rendered)
+		return 0
+	}
+	if err := os.WriteFile(output, []byte(rendered), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
+
+// readManifestTree decodes a single file, or every manifest file below a
+// directory in lexical order.
+func readManifestTree(root string) ([]manifest.Object, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return manifest.ReadFile(root, scheme)
+	}
+
+	var objects []manifest.Object
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		read, err := manifest.ReadFile(path, scheme)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, read...)
+		return nil
+	})
+	return objects, err
+}
@@ -0,0 +1,65 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mirror maps a registry, or a repository prefix within one, to where it is
+// mirrored, e.g. docker.io to mirror.local/dockerhub.
+type Mirror struct {
+	From string
+	To   string
+}
+
+// ParseMirror parses a from=to rule. Either side may end in a slash.
+func ParseMirror(rule string) (Mirror, error) {
+	from, to, ok := strings.Cut(rule, "=")
+	if !ok {
+		return Mirror{}, fmt.Errorf("mirror rule %q: expected from=to", rule)
+	}
+	mirror := Mirror{
+		From: NormalizePrefix(from),
+		To:   NormalizePrefix(to),
+	}
+	for _, prefix := range []string{mirror.From, mirror.To} {
+		if prefix == "" {
+			return Mirror{}, fmt.Errorf("mirror rule %q: empty prefix", rule)
+		}
+		// A prefix has to be valid as a repository once something is appended.
+		if _, err := Parse(prefix + "/x"); err != nil {
+			return Mirror{}, fmt.Errorf("mirror rule %q: invalid prefix %q", rule, prefix)
+		}
+	}
+	return mirror, nil
+}
+
+// NormalizePrefix trims a trailing slash off a registry or repository prefix and
+// spells Docker Hub the way Parse does.
+func NormalizePrefix(prefix string) string {
+	prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "/")
+	if prefix == legacyDefaultDomain || strings.HasPrefix(prefix, legacyDefaultDomain+"/") {
+		prefix = DefaultDomain + strings.TrimPrefix(prefix, legacyDefaultDomain)
+	}
+	return prefix
+}
+
+// Rewrite moves ref to the mirror with the longest matching prefix. It reports
+// false if no mirror matches.
+func Rewrite(ref Reference, mirrors []Mirror) (Reference, bool, error) {
+	var match *Mirror
+	for i, mirror := range mirrors {
+		if ref.HasPrefix(mirror.From) && (match == nil || len(mirror.From) > len(match.From)) {
+			match = &mirrors[i]
+		}
+	}
+	if match == nil {
+		return ref, false, nil
+	}
+
+	rewritten, err := ref.WithName(match.To + strings.TrimPrefix(ref.Name(), match.From))
+	if err != nil {
+		return ref, false, err
+	}
+	return rewritten, true, nil
+}
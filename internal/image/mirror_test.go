@@ -0,0 +1,67 @@
+package image
+
+import "testing"
+
+func TestParseMirror(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    Mirror
+		wantErr bool
+	}{
+		{rule: "docker.io=mirror.local/dockerhub", want: Mirror{From: "docker.io", To: "mirror.local/dockerhub"}},
+		{rule: " index.docker.io/ = mirror.local/dockerhub/ ", want: Mirror{From: "docker.io", To: "mirror.local/dockerhub"}},
+		{rule: "ghcr.io/org=registry:5000/ghcr-org", want: Mirror{From: "ghcr.io/org", To: "registry:5000/ghcr-org"}},
+		{rule: "docker.io", wantErr: true},
+		{rule: "=mirror.local", wantErr: true},
+		{rule: "docker.io=", wantErr: true},
+		{rule: "docker.io=Mirror.local/Upper", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMirror(tt.rule)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMirror(%q) error = %v, want error %v", tt.rule, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMirror(%q) = %+v, want %+v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	mirrors := []Mirror{
+		{From: "docker.io", To: "mirror.local/dockerhub"},
+		{From: "docker.io/bitnami", To: "mirror.local/bitnami"},
+		{From: "ghcr.io/org", To: "mirror.local/ghcr-org"},
+	}
+
+	tests := []struct {
+		image   string
+		want    string
+		rewrite bool
+	}{
+		{image: "nginx", want: "mirror.local/dockerhub/library/nginx", rewrite: true},
+		{image: "nginx:1.25", want: "mirror.local/dockerhub/library/nginx:1.25", rewrite: true},
+		{image: "nginx:1.25@" + testDigest, want: "mirror.local/dockerhub/library/nginx:1.25@" + testDigest, rewrite: true},
+		{image: "bitnami/redis:7.2", want: "mirror.local/bitnami/redis:7.2", rewrite: true},
+		{image: "ghcr.io/org/app:v1", want: "mirror.local/ghcr-org/app:v1", rewrite: true},
+		{image: "ghcr.io/other/app:v1", want: "ghcr.io/other/app:v1"},
+		{image: "mirror.local/dockerhub/library/nginx", want: "mirror.local/dockerhub/library/nginx"},
+	}
+
+	for _, tt := range tests {
+		ref, err := Parse(tt.image)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.image, err)
+		}
+		got, rewritten, err := Rewrite(ref, mirrors)
+		if err != nil {
+			t.Errorf("Rewrite(%q) error = %v", tt.image, err)
+			continue
+		}
+		if rewritten != tt.rewrite || got.String() != tt.want {
+			t.Errorf("Rewrite(%q) = %q, %v, want %q, %v", tt.image, got, rewritten, tt.want, tt.rewrite)
+		}
+	}
+}
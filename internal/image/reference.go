@@ -0,0 +1,137 @@
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultDomain is the registry of references without a domain.
+	DefaultDomain = "docker.io"
+	// officialRepoPrefix is the namespace of single component Docker Hub
+	// repositories, e.g. nginx is docker.io/library/nginx.
+	officialRepoPrefix  = "library/"
+	legacyDefaultDomain = "index.docker.io"
+)
+
+var (
+	domainPattern    = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*|\[[a-fA-F0-9:]+\])(?::[0-9]+)?$`)
+	componentPattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	tagPattern       = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestPattern    = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+)
+
+// Reference is a parsed image reference such as
+// registry.example.com:5000/team/app:1.0@sha256:...
+type Reference struct {
+	// Domain is the registry host, with the port if one was given.
+	Domain string
+	// Path is the repository within the registry, e.g. library/nginx.
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// Parse parses an image reference the way container runtimes do. References
+// without a domain are on Docker Hub, and single component Docker Hub paths are
+// in the library namespace.
+func Parse(s string) (Reference, error) {
+	if s == "" {
+		return Reference{}, fmt.Errorf("empty image reference")
+	}
+	var ref Reference
+
+	name := s
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !digestPattern.MatchString(ref.Digest) {
+			return Reference{}, fmt.Errorf("image %q: invalid digest %q", s, ref.Digest)
+		}
+	}
+
+	// A colon after the last slash separates the tag, any other colon is part
+	// of the domain port.
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if !tagPattern.MatchString(ref.Tag) {
+			return Reference{}, fmt.Errorf("image %q: invalid tag %q", s, ref.Tag)
+		}
+	}
+
+	ref.Domain, ref.Path = splitDomain(name)
+	if !domainPattern.MatchString(ref.Domain) {
+		return Reference{}, fmt.Errorf("image %q: invalid registry %q", s, ref.Domain)
+	}
+	if ref.Path == "" {
+		return Reference{}, fmt.Errorf("image %q: missing repository", s)
+	}
+	for _, component := range strings.Split(ref.Path, "/") {
+		if !componentPattern.MatchString(component) {
+			return Reference{}, fmt.Errorf("image %q: invalid repository component %q", s, component)
+		}
+	}
+	return ref, nil
+}
+
+// splitDomain separates the registry from the repository path. The first
+// component is only a registry if it looks like a host.
+func splitDomain(name string) (string, string) {
+	domain, path := DefaultDomain, name
+	if i := strings.Index(name, "/"); i >= 0 {
+		first := name[:i]
+		if strings.ContainsAny(first, ".:[") || first == "localhost" || strings.ToLower(first) != first {
+			domain, path = first, name[i+1:]
+		}
+	}
+	if domain == legacyDefaultDomain {
+		domain = DefaultDomain
+	}
+	if domain == DefaultDomain && !strings.Contains(path, "/") {
+		path = officialRepoPrefix + path
+	}
+	return domain, path
+}
+
+// Name returns the fully qualified repository, e.g. docker.io/library/nginx.
+func (r Reference) Name() string {
+	return r.Domain + "/" + r.Path
+}
+
+// String returns the fully qualified reference with its tag and digest.
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// WithName returns the reference moved to another repository, keeping its tag
+// and digest.
+func (r Reference) WithName(name string) (Reference, error) {
+	moved, err := Parse(name)
+	if err != nil {
+		return Reference{}, err
+	}
+	if moved.Tag != "" || moved.Digest != "" {
+		return Reference{}, fmt.Errorf("repository %q must not have a tag or digest", name)
+	}
+	moved.Tag = r.Tag
+	moved.Digest = r.Digest
+	return moved, nil
+}
+
+// HasPrefix reports whether the repository is prefix or below it, matching
+// whole path components. The prefix is a registry with an optional path, e.g.
+// docker.io or ghcr.io/org.
+func (r Reference) HasPrefix(prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	name := r.Name()
+	return name == prefix || strings.HasPrefix(name, prefix+"/")
+}
@@ -0,0 +1,93 @@
+package image
+
+import "testing"
+
+const testDigest = "sha256:4f7ab1b8c1e5d2f3a4b5c6d7e8f90112233445566778899aabbccddeeff00112"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		image   string
+		want    Reference
+		wantErr bool
+	}{
+		{image: "nginx", want: Reference{Domain: "docker.io", Path: "library/nginx"}},
+		{image: "nginx:1.25", want: Reference{Domain: "docker.io", Path: "library/nginx", Tag: "1.25"}},
+		{image: "bitnami/redis:7.2", want: Reference{Domain: "docker.io", Path: "bitnami/redis", Tag: "7.2"}},
+		{image: "docker.io/nginx", want: Reference{Domain: "docker.io", Path: "library/nginx"}},
+		{image: "index.docker.io/library/nginx", want: Reference{Domain: "docker.io", Path: "library/nginx"}},
+		{image: "ghcr.io/org/app:v1", want: Reference{Domain: "ghcr.io", Path: "org/app", Tag: "v1"}},
+		{image: "ghcr.io/app", want: Reference{Domain: "ghcr.io", Path: "app"}},
+		{image: "localhost/app", want: Reference{Domain: "localhost", Path: "app"}},
+		{image: "localhost:5000/app:dev", want: Reference{Domain: "localhost:5000", Path: "app", Tag: "dev"}},
+		{image: "registry.example.com:5000/team/app:1.0", want: Reference{Domain: "registry.example.com:5000", Path: "team/app", Tag: "1.0"}},
+		{image: "[::1]:5000/app", want: Reference{Domain: "[::1]:5000", Path: "app"}},
+		{image: "nginx@" + testDigest, want: Reference{Domain: "docker.io", Path: "library/nginx", Digest: testDigest}},
+		{image: "nginx:1.25@" + testDigest, want: Reference{Domain: "docker.io", Path: "library/nginx", Tag: "1.25", Digest: testDigest}},
+		{image: "registry:5000/app@" + testDigest, want: Reference{Domain: "registry:5000", Path: "app", Digest: testDigest}},
+		{image: "", wantErr: true},
+		{image: "Nginx", wantErr: true},
+		{image: "nginx:", wantErr: true},
+		{image: "nginx:-bad", wantErr: true},
+		{image: "nginx@sha256:short", wantErr: true},
+		{image: "ghcr.io/", wantErr: true},
+		{image: "ghcr.io/org//app", wantErr: true},
+		{image: "bad_host:5000:x/app", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := Parse(tt.image)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, want error %v", tt.image, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferenceString(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                         "docker.io/library/nginx",
+		"nginx:1.25":                    "docker.io/library/nginx:1.25",
+		"localhost:5000/app:dev":        "localhost:5000/app:dev",
+		"ghcr.io/org/app@" + testDigest: "ghcr.io/org/app@" + testDigest,
+	}
+	for image, want := range tests {
+		ref, err := Parse(image)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", image, err)
+		}
+		if got := ref.String(); got != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", image, got, want)
+		}
+	}
+}
+
+func TestReferenceHasPrefix(t *testing.T) {
+	tests := []struct {
+		image  string
+		prefix string
+		want   bool
+	}{
+		{image: "nginx", prefix: "docker.io", want: true},
+		{image: "nginx", prefix: "docker.io/library", want: true},
+		{image: "nginx", prefix: "docker.io/library/", want: true},
+		{image: "nginx", prefix: "docker.io/library/nginx", want: true},
+		{image: "nginx", prefix: "docker.io/lib"},
+		{image: "ghcr.io/org/app", prefix: "ghcr.io/org", want: true},
+		{image: "ghcr.io/organisation/app", prefix: "ghcr.io/org"},
+		{image: "ghcr.io.evil.com/org/app", prefix: "ghcr.io"},
+	}
+
+	for _, tt := range tests {
+		ref, err := Parse(tt.image)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.image, err)
+		}
+		if got := ref.HasPrefix(tt.prefix); got != tt.want {
+			t.Errorf("Parse(%q).HasPrefix(%q) = %v, want %v", tt.image, tt.prefix, got, tt.want)
+		}
+	}
+}
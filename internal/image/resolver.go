@@ -0,0 +1,72 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Resolver looks up the digest a tag currently points at.
+type Resolver interface {
+	Resolve(ctx context.Context, ref Reference) (string, error)
+}
+
+// FileResolver resolves digests from a YAML or JSON file mapping references to
+// digests, e.g. "docker.io/library/nginx:1.25: sha256:...". Keys are parsed, so
+// nginx:1.25 works as well. The file is read on first use.
+type FileResolver struct {
+	Path string
+
+	once    sync.Once
+	digests map[string]string
+	err     error
+}
+
+func NewFileResolver(path string) *FileResolver {
+	return &FileResolver{Path: path}
+}
+
+func (r *FileResolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	r.once.Do(r.load)
+	if r.err != nil {
+		return "", r.err
+	}
+
+	ref.Digest = ""
+	digest, ok := r.digests[ref.String()]
+	if !ok {
+		return "", fmt.Errorf("%s: no digest for %s", r.Path, ref)
+	}
+	return digest, nil
+}
+
+func (r *FileResolver) load() {
+	data, err := os.ReadFile(r.Path)
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	var entries map[string]string
+	if err := yaml.UnmarshalStrict(data, &entries); err != nil {
+		r.err = fmt.Errorf("%s: %w", r.Path, err)
+		return
+	}
+
+	r.digests = make(map[string]string, len(entries))
+	for key, digest := range entries {
+		ref, err := Parse(key)
+		if err != nil {
+			r.err = fmt.Errorf("%s: %w", r.Path, err)
+			return
+		}
+		if !digestPattern.MatchString(digest) {
+			r.err = fmt.Errorf("%s: %s: invalid digest %q", r.Path, key, digest)
+			return
+		}
+		r.digests[ref.String()] = digest
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/lint"
+)
+
+// runLint checks every policy annotation in the given manifests against the
+// known annotations. It exits with 1 if any error was found.
+func runLint(args []string) int {
+	flags, opts := newCommandFlagSet("lint", "lint [flags] [dir|file ...]\n\nReads manifests from the given files and directories, or stdin if none or - is given.")
+	var configPath string
+	var output string
+	flags.StringVar(&configPath, "config", "", "Path to the config file. Defaults are used if empty.")
+	flags.StringVar(&output, "o", "text", "Output format: text or json.")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if output != "text" && output != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output %q, expected text or json\n", output)
+		return 2
+	}
+	setupCommandLogger(opts)
+
+	if err := loadCommandConfig(configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	var findings []lint.Finding
+	for _, path := range paths {
+		found, err := lintPath(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		findings = append(findings, found...)
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, finding := range findings {
+			if err := encoder.Encode(finding); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+		}
+	} else {
+		for _, finding := range findings {
+			fmt.Fprintln(os.Stdout, finding.String())
+		}
+	}
+
+	if lint.HasErrors(findings) {
+		return 1
+	}
+	return 0
+}
+
+func lintPath(path string) ([]lint.Finding, error) {
+	if path == "-" {
+		return lint.File(path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return lint.File(path)
+	}
+
+	var findings []lint.Finding
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		found, err := lint.File(file)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+		return nil
+	})
+	return findings, err
+}
@@ -0,0 +1,227 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Finding is a problem with a single annotation.
+type Finding struct {
+	Source     string `json:"source,omitempty"`
+	Line       int    `json:"line,omitempty"`
+	Object     string `json:"object,omitempty"`
+	Key        string `json:"key"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+func (f Finding) String() string {
+	var b strings.Builder
+	if f.Source != "" {
+		b.WriteString(f.Source)
+		if f.Line > 0 {
+			fmt.Fprintf(&b, ":%d", f.Line)
+		}
+		b.WriteString(": ")
+	}
+	fmt.Fprintf(&b, "%s: %s", f.Severity, f.Message)
+	if f.Object != "" {
+		fmt.Fprintf(&b, " on %s", f.Object)
+	}
+	if f.Suggestion != "" {
+		fmt.Fprintf(&b, ", did you mean %q?", f.Suggestion)
+	}
+	return b.String()
+}
+
+// HasErrors reports whether any of the findings is an error.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Annotations checks every annotation under the configured prefix against the
+// registry of known annotations. Other annotations are ignored.
+func Annotations(annotations map[string]string) []Finding {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var findings []Finding
+	for _, key := range keys {
+		if finding, ok := lintAnnotation(key, annotations[key]); ok {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+func lintAnnotation(key string, value string) (Finding, bool) {
+	cfg := config.Current()
+	name, prefix, ok := cfg.SplitAnnotationKey(key)
+	if !ok {
+		return Finding{}, false
+	}
+
+	known, ok := annotation.Lookup(name)
+	if !ok {
+		finding := Finding{
+			Key:      key,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("unknown annotation %q", key),
+		}
+		if suggestion := suggest(name); suggestion != "" {
+			finding.Suggestion = cfg.AnnotationKey(suggestion)
+		}
+		return finding, true
+	}
+
+	if err := known.Check(value); err != nil {
+		return Finding{
+			Key:      key,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("invalid value for %q: %s", key, err.Error()),
+		}, true
+	}
+
+	if prefix.Deprecated {
+		return Finding{
+			Key:      key,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("annotation %q uses the deprecated prefix %s, use %q instead", key, prefix.Prefix, cfg.AnnotationKey(name)),
+		}, true
+	}
+	return Finding{}, false
+}
+
+// File lints every object in a YAML file, "-" reads stdin.
+func File(path string) ([]Finding, error) {
+	if path == "-" {
+		return Reader(os.Stdin, "<stdin>")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Reader(file, path)
+}
+
+// Reader lints every annotations block in a stream of YAML documents, including
+// those of nested templates, and reports the line of each offending key.
+func Reader(r io.Reader, source string) ([]Finding, error) {
+	decoder := yaml.NewDecoder(r)
+
+	var findings []Finding
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return findings, nil
+		}
+		if err != nil {
+			return findings, fmt.Errorf("%s: %w", source, err)
+		}
+
+		for _, root := range document.Content {
+			findings = append(findings, lintNode(root, source, objectName(root))...)
+		}
+	}
+}
+
+// lintNode walks the YAML tree looking for metadata.annotations mappings.
+func lintNode(node *yaml.Node, source string, object string) []Finding {
+	var findings []Finding
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		if name := objectName(node); name != "" {
+			object = name
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "metadata" && value.Kind == yaml.MappingNode {
+				findings = append(findings, lintMetadata(value, source, object)...)
+			}
+			findings = append(findings, lintNode(value, source, object)...)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			findings = append(findings, lintNode(item, source, object)...)
+		}
+	}
+	return findings
+}
+
+func lintMetadata(metadata *yaml.Node, source string, object string) []Finding {
+	annotations := mappingValue(metadata, "annotations")
+	if annotations == nil || annotations.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var findings []Finding
+	for i := 0; i+1 < len(annotations.Content); i += 2 {
+		key, value := annotations.Content[i], annotations.Content[i+1]
+		if finding, ok := lintAnnotation(key.Value, value.Value); ok {
+			finding.Source = source
+			finding.Line = key.Line
+			finding.Object = object
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// objectName describes a mapping that looks like a Kubernetes object, e.g.
+// "Ingress default/cyberchef".
+func objectName(node *yaml.Node) string {
+	kind := mappingValue(node, "kind")
+	metadata := mappingValue(node, "metadata")
+	if kind == nil || metadata == nil {
+		return ""
+	}
+
+	name := ""
+	if value := mappingValue(metadata, "name"); value != nil {
+		name = value.Value
+	}
+	if namespace := mappingValue(metadata, "namespace"); namespace != nil && namespace.Value != "" {
+		name = namespace.Value + "/" + name
+	}
+	return kind.Value + " " + name
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	// Registers the annotations of every policy.
+	_ "github.com/aumer-amr/k8s-policy-control/pkg/policy"
+)
+
+func TestAnnotations(t *testing.T) {
+	config.Set(config.Default())
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	findings := Annotations(map[string]string{
+		"policy-control.aumer.io/gatus-name":     "site",
+		"policy-control.aumer.io/gatus-nme":      "site",
+		"policy-control.aumer.io/gatus-protocol": "ftp",
+		"k8s-ycl.bjw-s.dev/keep-limit":           "true",
+		"k8s-ycl.bjw-s.dev/keep-limits":          "true",
+		"app.kubernetes.io/name":                 "web",
+	})
+
+	want := []Finding{
+		{
+			Key:      "k8s-ycl.bjw-s.dev/keep-limit",
+			Severity: SeverityWarning,
+			Message:  `annotation "k8s-ycl.bjw-s.dev/keep-limit" uses the deprecated prefix k8s-ycl.bjw-s.dev/, use "policy-control.aumer.io/keep-limit" instead`,
+		},
+		{
+			Key:        "k8s-ycl.bjw-s.dev/keep-limits",
+			Severity:   SeverityError,
+			Message:    `unknown annotation "k8s-ycl.bjw-s.dev/keep-limits"`,
+			Suggestion: "policy-control.aumer.io/keep-limit",
+		},
+		{
+			Key:        "policy-control.aumer.io/gatus-nme",
+			Severity:   SeverityError,
+			Message:    `unknown annotation "policy-control.aumer.io/gatus-nme"`,
+			Suggestion: "policy-control.aumer.io/gatus-name",
+		},
+		{
+			Key:      "policy-control.aumer.io/gatus-protocol",
+			Severity: SeverityError,
+		},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("findings = %v, want %d", findings, len(want))
+	}
+	for i, finding := range findings {
+		if finding.Key != want[i].Key || finding.Severity != want[i].Severity || finding.Suggestion != want[i].Suggestion {
+			t.Errorf("finding %d = %+v, want %+v", i, finding, want[i])
+		}
+		if want[i].Message != "" && finding.Message != want[i].Message {
+			t.Errorf("finding %d message = %q, want %q", i, finding.Message, want[i].Message)
+		}
+	}
+	if !HasErrors(findings) {
+		t.Error("HasErrors() = false, want true")
+	}
+}
+
+func TestReader(t *testing.T) {
+	config.Set(config.Default())
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	manifests := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  namespace: default
+  name: web
+  annotations:
+    k8s-ycl.bjw-s.dev/keep-limit: "true"
+spec:
+  template:
+    metadata:
+      annotations:
+        policy-control.aumer.io/injcet: log-shipper
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  annotations:
+    policy-control.aumer.io/gatus-generate-service: "true"
+`
+	findings, err := Reader(strings.NewReader(manifests), "web.yaml")
+	if err != nil {
+		t.Fatalf("Reader() error = %v", err)
+	}
+
+	want := []string{
+		`web.yaml:7: warning: annotation "k8s-ycl.bjw-s.dev/keep-limit" uses the deprecated prefix k8s-ycl.bjw-s.dev/, use "policy-control.aumer.io/keep-limit" instead on Deployment default/web`,
+		`web.yaml:12: error: unknown annotation "policy-control.aumer.io/injcet" on Deployment default/web, did you mean "policy-control.aumer.io/inject"?`,
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("findings = %v, want %d", findings, len(want))
+	}
+	for i, finding := range findings {
+		if got := finding.String(); got != want[i] {
+			t.Errorf("finding %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
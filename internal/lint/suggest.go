@@ -0,0 +1,51 @@
+package lint
+
+import (
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+)
+
+// suggest returns the known annotation name closest to name, or "" if none is
+// close enough to be a likely typo.
+func suggest(name string) string {
+	maxDistance := len(name) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	best := ""
+	bestDistance := maxDistance + 1
+	for _, key := range annotation.Known() {
+		if distance := levenshtein(name, key.Name); distance < bestDistance {
+			best, bestDistance = key.Name, distance
+		}
+	}
+	return best
+}
+
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
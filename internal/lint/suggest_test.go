@@ -0,0 +1,42 @@
+package lint
+
+import "testing"
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "gatus-nme", want: "gatus-name"},
+		{name: "keep-limits", want: "keep-limit"},
+		{name: "injcet", want: "inject"},
+		{name: "default-memroy-request", want: "default-memory-request"},
+		{name: "completely-unrelated"},
+		{name: "x"},
+	}
+
+	for _, tt := range tests {
+		if got := suggest(tt.name); got != tt.want {
+			t.Errorf("suggest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "inject", want: 6},
+		{a: "inject", b: "inject"},
+		{a: "inject", b: "injcet", want: 2},
+		{a: "keep-limit", b: "keep-limits", want: 1},
+		{a: "gatus-nme", b: "gatus-name", want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
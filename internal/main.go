@@ -17,15 +17,23 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 
 	controller "github.com/aumer-amr/k8s-policy-control/internal/controller"
-	"github.com/aumer-amr/k8s-policy-control/internal/policy"
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -48,10 +56,34 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	var metricsAddr string
 	var probeAddr string
+	var configPath string
+	var auditLogPath string
+	var auditLogMaxSize int64
+	var auditLogMaxBackups int
+	var enableWebhooks bool
+	var webhookPort int
+	var webhookCertDir string
+	var admissionCaptureDir string
+	var reconcileTimeout time.Duration
+	var reportInterval time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&configPath, "config", "", "Path to the config file, reloaded on change. Defaults are used if empty.")
+	flag.StringVar(&auditLogPath, "audit-log", "", "Where to write the JSON lines audit log of policy decisions: a file path, or - for stdout. Disabled if empty.")
+	flag.Int64Var(&auditLogMaxSize, "audit-log-max-size", 100, "Size in megabytes after which the audit log file is rotated.")
+	flag.IntVar(&auditLogMaxBackups, "audit-log-max-backups", 5, "Number of rotated audit log files to keep.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the admission webhooks.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the webhook server binds to.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs"), "The directory containing the webhook tls.crt and tls.key.")
+	flag.StringVar(&admissionCaptureDir, "admission-capture-dir", "", "Directory to capture admission requests and responses to, with Secret data redacted, for the replay command. Disabled if empty.")
+	flag.DurationVar(&reconcileTimeout, "reconcile-timeout", 5*time.Minute, "How long a single reconcile may run before the liveness probe fails.")
+	flag.DurationVar(&reportInterval, "report-interval", time.Hour, "How often existing Pods are checked against the admission policies in audit mode. Disabled if 0.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -60,21 +92,38 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	setupConfig(configPath)
+	setupAuditLog(auditLogPath, auditLogMaxSize*1024*1024, auditLogMaxBackups)
+
+	options := ctrl.Options{
 		Scheme:                 scheme,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         false,
 		Metrics: server.Options{
 			BindAddress: metricsAddr,
+			ExtraHandlers: map[string]http.Handler{
+				"/annotations": annotation.Handler(),
+			},
 		},
-	})
+	}
+	if enableWebhooks {
+		options.WebhookServer = newWebhookServer(webhookPort, webhookCertDir)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	setupProbeEndpoints(mgr)
+	setupProbeEndpoints(mgr, reconcileTimeout)
+	if enableWebhooks {
+		setupWebhooks(mgr, admissionCaptureDir)
+		setupWebhookProbes(mgr, webhookCertDir)
+	}
+	setupConfigWatch(mgr, configPath)
 	setupControllers(mgr)
+	setupReporter(mgr, reportInterval)
 
 	policy.RegisterPolicies()
 
@@ -85,16 +134,95 @@ func main() {
 	}
 }
 
+func setupConfig(configPath string) {
+	if configPath == "" {
+		config.Set(config.Default())
+		setupLog.Info("no config file given, using defaults")
+		return
+	}
+
+	if err := config.Load(configPath); err != nil {
+		setupLog.Error(err, "unable to load config")
+		os.Exit(1)
+	}
+}
+
+func setupConfigWatch(mgr ctrl.Manager, configPath string) {
+	if configPath == "" {
+		return
+	}
+
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		return config.Watch(ctx, configPath)
+	})); err != nil {
+		setupLog.Error(err, "unable to watch config")
+		os.Exit(1)
+	}
+}
+
+func setupAuditLog(auditLogPath string, maxSize int64, maxBackups int) {
+	if auditLogPath == "" {
+		return
+	}
+
+	sink, err := audit.Open(auditLogPath, maxSize, maxBackups)
+	if err != nil {
+		setupLog.Error(err, "unable to open audit log")
+		os.Exit(1)
+	}
+	audit.SetSink(sink)
+	setupLog.Info("writing audit log", "destination", auditLogPath)
+}
+
 func setupControllers(mgr manager.Manager) {
 	controller.New(mgr, policy.PolicyTypeIngress)
+	controller.New(mgr, policy.PolicyTypeService)
+	controller.New(mgr, policy.PolicyTypeNamespace)
+}
+
+func setupReporter(mgr manager.Manager, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	if err := mgr.Add(controller.NewReporter(mgr, interval)); err != nil {
+		setupLog.Error(err, "unable to set up reporter")
+		os.Exit(1)
+	}
 }
 
-func setupProbeEndpoints(mgr ctrl.Manager) {
+func setupProbeEndpoints(mgr ctrl.Manager, reconcileTimeout time.Duration) {
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		panic(fmt.Errorf("unable to add healthz check: %w", err))
 	}
+	if err := mgr.AddHealthzCheck("reconcile-progress", controller.ReconcileProgressCheck(reconcileTimeout)); err != nil {
+		panic(fmt.Errorf("unable to add reconcile-progress check: %w", err))
+	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		panic(fmt.Errorf("unable to add readyz check: %w", err))
 	}
+	if err := mgr.AddReadyzCheck("cache-synced", controller.CacheSyncedCheck(mgr.GetCache())); err != nil {
+		panic(fmt.Errorf("unable to add cache-synced check: %w", err))
+	}
+	if err := mgr.AddReadyzCheck("config-loaded", configLoadedCheck); err != nil {
+		panic(fmt.Errorf("unable to add config-loaded check: %w", err))
+	}
 	setupLog.Info("added healthz and readyz check")
 }
+
+func setupWebhookProbes(mgr ctrl.Manager, certDir string) {
+	if err := mgr.AddReadyzCheck("webhook-started", mgr.GetWebhookServer().StartedChecker()); err != nil {
+		panic(fmt.Errorf("unable to add webhook-started check: %w", err))
+	}
+	if err := mgr.AddReadyzCheck("webhook-cert", webhookCertChecker(certDir)); err != nil {
+		panic(fmt.Errorf("unable to add webhook-cert check: %w", err))
+	}
+	setupLog.Info("added webhook readyz checks")
+}
+
+func configLoadedCheck(_ *http.Request) error {
+	if !config.Loaded() {
+		return fmt.Errorf("config not loaded")
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package pss
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// appArmorAnnotationPrefix is how AppArmor profiles are set before the
+// securityContext field.
+const appArmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+var (
+	baselineCapabilities = map[corev1.Capability]bool{
+		"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true, "KILL": true, "MKNOD": true,
+		"NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+	}
+	seLinuxTypes = map[string]bool{"": true, "container_t": true, "container_init_t": true, "container_kvm_t": true}
+	safeSysctls  = map[string]bool{
+		"kernel.shm_rmid_forced": true, "net.ipv4.ip_local_port_range": true, "net.ipv4.ip_unprivileged_port_start": true,
+		"net.ipv4.tcp_syncookies": true, "net.ipv4.ping_group_range": true, "net.ipv4.ip_local_reserved_ports": true,
+		"net.ipv4.tcp_keepalive_time": true, "net.ipv4.tcp_fin_timeout": true, "net.ipv4.tcp_keepalive_intvl": true,
+		"net.ipv4.tcp_keepalive_probes": true,
+	}
+)
+
+var checks = []check{
+	{id: "hostNamespaces", level: LevelBaseline, run: checkHostNamespaces},
+	{id: "privileged", level: LevelBaseline, run: checkPrivileged},
+	{id: "capabilities_baseline", level: LevelBaseline, run: checkCapabilitiesBaseline},
+	{id: "hostPathVolumes", level: LevelBaseline, run: checkHostPathVolumes},
+	{id: "hostPorts", level: LevelBaseline, run: checkHostPorts},
+	{id: "appArmorProfile", level: LevelBaseline, run: checkAppArmorProfile},
+	{id: "seLinuxOptions", level: LevelBaseline, run: checkSELinuxOptions},
+	{id: "procMount", level: LevelBaseline, run: checkProcMount},
+	{id: "seccompProfile_baseline", level: LevelBaseline, run: checkSeccompProfileBaseline},
+	{id: "sysctls", level: LevelBaseline, run: checkSysctls},
+	{id: "windowsHostProcess", level: LevelBaseline, run: checkWindowsHostProcess},
+	{id: "restrictedVolumes", level: LevelRestricted, run: checkRestrictedVolumes},
+	{id: "allowPrivilegeEscalation", level: LevelRestricted, run: checkAllowPrivilegeEscalation},
+	{id: "runAsNonRoot", level: LevelRestricted, run: checkRunAsNonRoot},
+	{id: "runAsUser", level: LevelRestricted, run: checkRunAsUser},
+	{id: "seccompProfile_restricted", level: LevelRestricted, run: checkSeccompProfileRestricted},
+	{id: "capabilities_restricted", level: LevelRestricted, run: checkCapabilitiesRestricted},
+}
+
+func podViolation(format string, args ...interface{}) Violation {
+	return Violation{Message: fmt.Sprintf(format, args...)}
+}
+
+func containerViolation(c container, format string, args ...interface{}) Violation {
+	return Violation{Container: c.name, Message: fmt.Sprintf(format, args...)}
+}
+
+func checkHostNamespaces(pod *corev1.Pod) []Violation {
+	var violations []Violation
+	if pod.Spec.HostNetwork {
+		violations = append(violations, podViolation("hostNetwork must not be true"))
+	}
+	if pod.Spec.HostPID {
+		violations = append(violations, podViolation("hostPID must not be true"))
+	}
+	if pod.Spec.HostIPC {
+		violations = append(violations, podViolation("hostIPC must not be true"))
+	}
+	return violations
+}
+
+func checkPrivileged(pod *corev1.Pod) []Violation {
+	var violations []Violation
+	for _, c := range containers(pod) {
+		if sc := c.securityContext; sc != nil && sc.Privileged != nil && *sc.Privileged {
+			violations = append(violations, containerViolation(c, "must not set securityContext.privileged=true"))
+		}
+	}
+	return violations
+}
+
+func checkCapabilitiesBaseline(pod *corev1.Pod) []Violation {
+	var violations []Violation
+	for _, c := range containers(pod) {
+		if c.securityContext == nil || c.securityContext.Capabilities == nil {
+			continue
+		}
+		var forbidden []string
+		for _, capability := range c.securityContext.Capabilities.Add {
+			if !baselineCapabilities[capability] {
+				forbidden = append(forbidden, string(capability))
+			}
+		}
+		if len(forbidden) > 0 {
+			violations = append(violations, containerViolation(c, "must not add capabilities %s", strings.Join(forbidden, ", ")))
+		}
+	}
+	return violations
+}
+
+func checkHostPathVolumes(pod *corev1.Pod) []Violation {
+	var violations []Violation
+	for _, volume := range pod.Spec.Volumes {
+		if volume.HostPath != nil {
+			violations = append(violations, podViolation("volume %s must not be a hostPath", volume.Name))
+		}
+	}
+	return violations
+}
+
+func checkHostPorts(pod *corev1.Pod) []Violation {
+	var violations []Violation
+	for _, c := range containers(pod) {
+		for _, port := range c.ports {
+			if port.HostPort != 0 {
+				violations = append(violations, containerViolation(c, "must not use hostPort %d", port.HostPort))
+			}
+		}
+	}
+	return violations
+}
+
+func checkAppArmorProfile(pod *corev1.Pod) []Violation {
+	var keys []string
+	for key := range pod.Annotations {
+		if strings.HasPrefix(key, appArmorAnnotationPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var violations []Violation
+	for _, key := range keys {
+		profile := pod.Annotations[key]
+		if profile != "runtime/default" && !strings.HasPrefix(profile, "localhost/") {
+			violations = append(violations, Violation{
+				Container: strings.TrimPrefix(key, appArmorAnnotationPrefix),
+				Message:   fmt.Sprintf("must not use AppArmor profile %q", profile),
+			})
+		}
+	}
+	return violations
+}
+
+func checkSELinuxOptions(pod *corev1.Pod) []Violation {
+	var violations []Violation
+	check := func(options *corev1.SELinuxOptions) []string {
+		if options == nil {
+			return nil
+		}
+		var problems []string
+		if !seLinuxTypes[options.Type] {
+			problems = append(problems, fmt.Sprintf("seLinuxOptions.type %q", options.Type))
+		}
+		if options.User != "" {
+			problems = append(problems, "seLinuxOptions.user")
+		}
+		if options.Role != "" {
+			problems = append(problems, "seLinuxOptions.role")
+		}
+		return problems
+	}
+
+	if pod.Spec.SecurityContext != nil {
+		if problems := check(pod.Spec.SecurityContext.SELinuxOptions); len(problems) > 0 {
+			violations = append(violations, podViolation("must not set %s", strings.Join(problems, ", ")))
+		}
+	}
+	for _, c := range containers(pod) {
+		if c.securityContext == nil {
+			continue
+		}
+		if problems := check(c.securityContext.SELinuxOptions); len(problems) > 0 {
+			violations = append(violations, containerViolation(c, "must not set %s", strings.Join(problems, ", ")))
+		}
+	}
+	return violations
+}
+
+func checkProcMount(pod *corev1.Pod) []Violation {
+	var violations []Violation
+	for _, c := range containers(pod) {
+		if sc := c.securityContext; sc != nil && sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+			violations = append(violations, containerViolation(c, "must not set procMount %s", *sc.ProcMount))
+		}
+	}
+	return violations
+}
+
+func checkSeccompProfileBaseline(pod *corev1.Pod) []Violation {
+	var violations []Violation
+	if sc := pod.Spec.SecurityContext; sc != nil && sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		violations = append(violations, podViolation("must not set seccompProfile.type Unconfined"))
+	}
+	for _, c := range containers(pod) {
+		if sc := c.securityContext; sc != nil && sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			violations = append(violations, containerViolation(c, "must not set seccompProfile.type Unconfined"))
+		}
+	}
+	return violations
+}
+
+func checkSysctls(pod *corev1.Pod) []Violation {
+	if pod.Spec.SecurityContext == nil {
+		return nil
+	}
+	var forbidden []string
+	for _, sysctl := range pod.Spec.SecurityContext.Sysctls {
+		if !safeSysctls[sysctl.Name] {
+			forbidden = append(forbidden, sysctl.Name)
+		}
+	}
+	if len(forbidden) == 0 {
+		return nil
+	}
+	return []Violation{podViolation("must not set sysctls %s", strings.Join(forbidden, ", "))}
+}
+
+func checkWindowsHostProcess(pod *corev1.Pod) []Violation {
+	hostProcess := func(options *corev1.WindowsSecurityContextOptions) bool {
+		return options != nil && options.HostProcess != nil && *options.HostProcess
+	}
+
+	var violations []Violation
+	if sc := pod.Spec.SecurityContext; sc != nil && hostProcess(sc.WindowsOptions) {
+		violations = append(violations, podViolation("must not set windowsOptions.hostProcess=true"))
+	}
+	for _, c := range containers(pod) {
+		if c.securityContext != nil && hostProcess(c.securityContext.WindowsOptions) {
+			violations = append(violations, containerViolation(c, "must not set windowsOptions.hostProcess=true"))
+		}
+	}
+	return violations
+}
+
+func checkRestrictedVolumes(pod *corev1.Pod) []Violation {
+	var violations []Violation
+	for _, volume := range pod.Spec.Volumes {
+		source := volume.VolumeSource
+		switch {
+		case source.ConfigMap != nil, source.CSI != nil, source.DownwardAPI != nil, source.EmptyDir != nil,
+			source.Ephemeral != nil, source.PersistentVolumeClaim != nil, source.Projected != nil, source.Secret != nil:
+		default:
+			violations = append(violations, podViolation("volume %s must be a configMap, csi, downwardAPI, emptyDir, ephemeral, persistentVolumeClaim, projected or secret volume", volume.Name))
+		}
+	}
+	return violations
+}
+
+func checkAllowPrivilegeEscalation(pod *corev1.Pod) []Violation {
+	if isWindows(pod) {
+		return nil
+	}
+	var violations []Violation
+	for _, c := range containers(pod) {
+		if sc := c.securityContext; sc == nil || sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			violations = append(violations, containerViolation(c, "must set securityContext.allowPrivilegeEscalation=false"))
+		}
+	}
+	return violations
+}
+
+func checkRunAsNonRoot(pod *corev1.Pod) []Violation {
+	var podRunAsNonRoot *bool
+	if pod.Spec.SecurityContext != nil {
+		podRunAsNonRoot = pod.Spec.SecurityContext.RunAsNonRoot
+	}
+
+	var violations []Violation
+	if podRunAsNonRoot != nil && !*podRunAsNonRoot {
+		violations = append(violations, podViolation("must not set securityContext.runAsNonRoot=false"))
+	}
+	for _, c := range containers(pod) {
+		var runAsNonRoot *bool
+		if c.securityContext != nil {
+			runAsNonRoot = c.securityContext.RunAsNonRoot
+		}
+		switch {
+		case runAsNonRoot != nil && !*runAsNonRoot:
+			violations = append(violations, containerViolation(c, "must not set securityContext.runAsNonRoot=false"))
+		case runAsNonRoot == nil && (podRunAsNonRoot == nil || !*podRunAsNonRoot):
+			violations = append(violations, containerViolation(c, "must set securityContext.runAsNonRoot=true, on the container or the Pod"))
+		}
+	}
+	return violations
+}
+
+func checkRunAsUser(pod *corev1.Pod) []Violation {
+	var violations []Violation
+	if sc := pod.Spec.SecurityContext; sc != nil && sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+		violations = append(violations, podViolation("must not set securityContext.runAsUser=0"))
+	}
+	for _, c := range containers(pod) {
+		if sc := c.securityContext; sc != nil && sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			violations = append(violations, containerViolation(c, "must not set securityContext.runAsUser=0"))
+		}
+	}
+	return violations
+}
+
+func checkSeccompProfileRestricted(pod *corev1.Pod) []Violation {
+	allowed := func(profile *corev1.SeccompProfile) bool {
+		return profile.Type == corev1.SeccompProfileTypeRuntimeDefault || profile.Type == corev1.SeccompProfileTypeLocalhost
+	}
+
+	var podProfile *corev1.SeccompProfile
+	if pod.Spec.SecurityContext != nil {
+		podProfile = pod.Spec.SecurityContext.SeccompProfile
+	}
+
+	var violations []Violation
+	if podProfile != nil && !allowed(podProfile) {
+		violations = append(violations, podViolation("must set seccompProfile.type to RuntimeDefault or Localhost"))
+	}
+	for _, c := range containers(pod) {
+		var profile *corev1.SeccompProfile
+		if c.securityContext != nil {
+			profile = c.securityContext.SeccompProfile
+		}
+		switch {
+		case profile != nil && !allowed(profile):
+			violations = append(violations, containerViolation(c, "must set seccompProfile.type to RuntimeDefault or Localhost"))
+		case profile == nil && podProfile == nil:
+			violations = append(violations, containerViolation(c, "must set seccompProfile.type to RuntimeDefault or Localhost, on the container or the Pod"))
+		}
+	}
+	return violations
+}
+
+func checkCapabilitiesRestricted(pod *corev1.Pod) []Violation {
+	if isWindows(pod) {
+		return nil
+	}
+	var violations []Violation
+	for _, c := range containers(pod) {
+		var capabilities *corev1.Capabilities
+		if c.securityContext != nil {
+			capabilities = c.securityContext.Capabilities
+		}
+
+		dropsAll := false
+		if capabilities != nil {
+			for _, capability := range capabilities.Drop {
+				if capability == "ALL" {
+					dropsAll = true
+				}
+			}
+		}
+		if !dropsAll {
+			violations = append(violations, containerViolation(c, "must drop capability ALL"))
+		}
+
+		if capabilities == nil {
+			continue
+		}
+		var forbidden []string
+		for _, capability := range capabilities.Add {
+			if capability != "NET_BIND_SERVICE" {
+				forbidden = append(forbidden, string(capability))
+			}
+		}
+		if len(forbidden) > 0 {
+			violations = append(violations, containerViolation(c, "must only add capability NET_BIND_SERVICE, not %s", strings.Join(forbidden, ", ")))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,164 @@
+package pss
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Level is a Pod Security Standards level. Every level includes the checks of
+// the levels below it.
+type Level string
+
+const (
+	LevelPrivileged Level = "privileged"
+	LevelBaseline   Level = "baseline"
+	LevelRestricted Level = "restricted"
+)
+
+// Levels lists the levels from least to most restrictive.
+var Levels = []Level{LevelPrivileged, LevelBaseline, LevelRestricted}
+
+func ParseLevel(value string) (Level, error) {
+	for _, level := range Levels {
+		if Level(strings.ToLower(strings.TrimSpace(value))) == level {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("unknown Pod Security Standards level %q, expected privileged, baseline or restricted", value)
+}
+
+func (l Level) includes(other Level) bool {
+	return l.rank() >= other.rank()
+}
+
+func (l Level) rank() int {
+	for i, level := range Levels {
+		if level == l {
+			return i
+		}
+	}
+	return -1
+}
+
+// Violation is a single failed check. Container is empty for violations of the
+// Pod as a whole.
+type Violation struct {
+	Check     string
+	Level     Level
+	Container string
+	Message   string
+}
+
+func (v Violation) String() string {
+	if v.Container == "" {
+		return v.Check + ": " + v.Message
+	}
+	return v.Check + ": container " + v.Container + " " + v.Message
+}
+
+// Exemption skips a check, for a single container if Container is set or for
+// the whole Pod otherwise.
+type Exemption struct {
+	Check     string
+	Container string
+}
+
+// ParseExemption parses check or check:container.
+func ParseExemption(value string) (Exemption, error) {
+	check, container, _ := strings.Cut(strings.TrimSpace(value), ":")
+	if !IsCheck(check) {
+		return Exemption{}, fmt.Errorf("exemption %q: unknown check %q, expected one of %s", value, check, strings.Join(CheckIDs(), ", "))
+	}
+	return Exemption{Check: check, Container: container}, nil
+}
+
+func (e Exemption) covers(v Violation) bool {
+	return e.Check == v.Check && (e.Container == "" || e.Container == v.Container)
+}
+
+// check is a single Pod Security Standards control, identified by the same ID
+// as in the built-in Pod Security admission.
+type check struct {
+	id    string
+	level Level
+	run   func(pod *corev1.Pod) []Violation
+}
+
+// CheckIDs returns the ID of every check in sorted order.
+func CheckIDs() []string {
+	ids := make([]string, 0, len(checks))
+	for _, c := range checks {
+		ids = append(ids, c.id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func IsCheck(id string) bool {
+	for _, c := range checks {
+		if c.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate runs every check up to level against pod and returns the violations
+// that aren't exempted, ordered by check ID.
+func Evaluate(pod *corev1.Pod, level Level, exemptions []Exemption) []Violation {
+	var violations []Violation
+	for _, c := range checks {
+		if !level.includes(c.level) {
+			continue
+		}
+		for _, violation := range c.run(pod) {
+			violation.Check = c.id
+			violation.Level = c.level
+			if !exempted(violation, exemptions) {
+				violations = append(violations, violation)
+			}
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Check < violations[j].Check
+	})
+	return violations
+}
+
+func exempted(violation Violation, exemptions []Exemption) bool {
+	for _, exemption := range exemptions {
+		if exemption.covers(violation) {
+			return true
+		}
+	}
+	return false
+}
+
+// container is what the checks read of a container, init container or
+// ephemeral container.
+type container struct {
+	name            string
+	securityContext *corev1.SecurityContext
+	ports           []corev1.ContainerPort
+}
+
+func containers(pod *corev1.Pod) []container {
+	var all []container
+	for _, c := range pod.Spec.InitContainers {
+		all = append(all, container{name: c.Name, securityContext: c.SecurityContext, ports: c.Ports})
+	}
+	for _, c := range pod.Spec.Containers {
+		all = append(all, container{name: c.Name, securityContext: c.SecurityContext, ports: c.Ports})
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		all = append(all, container{name: c.Name, securityContext: c.SecurityContext, ports: c.Ports})
+	}
+	return all
+}
+
+func isWindows(pod *corev1.Pod) bool {
+	return pod.Spec.OS != nil && pod.Spec.OS.Name == corev1.Windows
+}
@@ -0,0 +1,267 @@
+package pss
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// restrictedPod returns a Pod passing every check.
+func restrictedPod() *corev1.Pod {
+	runAsNonRoot, allowPrivilegeEscalation := true, false
+	return &corev1.Pod{
+		Spec: corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsNonRoot:   &runAsNonRoot,
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+			Containers: []corev1.Container{{
+				Name: "web",
+				SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}, Add: []corev1.Capability{"NET_BIND_SERVICE"}},
+				},
+			}},
+			Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	root := int64(0)
+	privileged := true
+	unmasked := corev1.UnmaskedProcMount
+
+	tests := []struct {
+		name       string
+		mutate     func(pod *corev1.Pod)
+		level      Level
+		exemptions []Exemption
+		want       []string
+	}{
+		{
+			name:  "restricted Pod",
+			level: LevelRestricted,
+		},
+		{
+			name:   "privileged level checks nothing",
+			mutate: func(pod *corev1.Pod) { pod.Spec.HostNetwork = true },
+			level:  LevelPrivileged,
+		},
+		{
+			name: "host namespaces",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.HostNetwork = true
+				pod.Spec.HostPID = true
+			},
+			level: LevelBaseline,
+			want:  []string{"hostNamespaces: hostNetwork must not be true", "hostNamespaces: hostPID must not be true"},
+		},
+		{
+			name:   "privileged container",
+			mutate: func(pod *corev1.Pod) { pod.Spec.Containers[0].SecurityContext.Privileged = &privileged },
+			level:  LevelBaseline,
+			want:   []string{"privileged: container web must not set securityContext.privileged=true"},
+		},
+		{
+			name: "capabilities",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"CHOWN", "SYS_ADMIN"}
+			},
+			level: LevelRestricted,
+			want: []string{
+				"capabilities_baseline: container web must not add capabilities SYS_ADMIN",
+				"capabilities_restricted: container web must only add capability NET_BIND_SERVICE, not CHOWN, SYS_ADMIN",
+			},
+		},
+		{
+			name: "baseline capabilities at baseline",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.Containers[0].SecurityContext.Capabilities = &corev1.Capabilities{Add: []corev1.Capability{"CHOWN"}}
+			},
+			level: LevelBaseline,
+		},
+		{
+			name: "hostPath volume",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{Name: "host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}}})
+			},
+			level: LevelRestricted,
+			want: []string{
+				"hostPathVolumes: volume host must not be a hostPath",
+				"restrictedVolumes: volume host must be a configMap, csi, downwardAPI, emptyDir, ephemeral, persistentVolumeClaim, projected or secret volume",
+			},
+		},
+		{
+			name: "host port",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 80, HostPort: 8080}}
+			},
+			level: LevelBaseline,
+			want:  []string{"hostPorts: container web must not use hostPort 8080"},
+		},
+		{
+			name: "AppArmor profile",
+			mutate: func(pod *corev1.Pod) {
+				pod.Annotations = map[string]string{appArmorAnnotationPrefix + "web": "unconfined"}
+			},
+			level: LevelBaseline,
+			want:  []string{`appArmorProfile: container web must not use AppArmor profile "unconfined"`},
+		},
+		{
+			name: "SELinux options",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.Containers[0].SecurityContext.SELinuxOptions = &corev1.SELinuxOptions{Type: "spc_t", User: "root"}
+			},
+			level: LevelBaseline,
+			want:  []string{`seLinuxOptions: container web must not set seLinuxOptions.type "spc_t", seLinuxOptions.user`},
+		},
+		{
+			name:   "proc mount",
+			mutate: func(pod *corev1.Pod) { pod.Spec.Containers[0].SecurityContext.ProcMount = &unmasked },
+			level:  LevelBaseline,
+			want:   []string{"procMount: container web must not set procMount Unmasked"},
+		},
+		{
+			name: "unconfined seccomp",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.SecurityContext.SeccompProfile.Type = corev1.SeccompProfileTypeUnconfined
+			},
+			level: LevelRestricted,
+			want: []string{
+				"seccompProfile_baseline: must not set seccompProfile.type Unconfined",
+				"seccompProfile_restricted: must set seccompProfile.type to RuntimeDefault or Localhost",
+			},
+		},
+		{
+			name: "unsafe sysctl",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.SecurityContext.Sysctls = []corev1.Sysctl{{Name: "net.ipv4.tcp_syncookies", Value: "1"}, {Name: "kernel.msgmax", Value: "1"}}
+			},
+			level: LevelBaseline,
+			want:  []string{"sysctls: must not set sysctls kernel.msgmax"},
+		},
+		{
+			name: "restricted fields unset",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.SecurityContext = nil
+				pod.Spec.Containers[0].SecurityContext = nil
+			},
+			level: LevelRestricted,
+			want: []string{
+				"allowPrivilegeEscalation: container web must set securityContext.allowPrivilegeEscalation=false",
+				"capabilities_restricted: container web must drop capability ALL",
+				"runAsNonRoot: container web must set securityContext.runAsNonRoot=true, on the container or the Pod",
+				"seccompProfile_restricted: container web must set seccompProfile.type to RuntimeDefault or Localhost, on the container or the Pod",
+			},
+		},
+		{
+			name:   "restricted fields unset at baseline",
+			mutate: func(pod *corev1.Pod) { pod.Spec.Containers[0].SecurityContext = nil },
+			level:  LevelBaseline,
+		},
+		{
+			name:   "root user",
+			mutate: func(pod *corev1.Pod) { pod.Spec.Containers[0].SecurityContext.RunAsUser = &root },
+			level:  LevelRestricted,
+			want:   []string{"runAsUser: container web must not set securityContext.runAsUser=0"},
+		},
+		{
+			name: "init and ephemeral containers",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.InitContainers = []corev1.Container{{Name: "init", SecurityContext: &corev1.SecurityContext{Privileged: &privileged}}}
+				pod.Spec.EphemeralContainers = []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+					Name: "debug", SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+				}}}
+			},
+			level: LevelBaseline,
+			want: []string{
+				"privileged: container init must not set securityContext.privileged=true",
+				"privileged: container debug must not set securityContext.privileged=true",
+			},
+		},
+		{
+			name: "windows Pods skip the Linux only checks",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.OS = &corev1.PodOS{Name: corev1.Windows}
+				pod.Spec.Containers[0].SecurityContext = nil
+			},
+			level: LevelRestricted,
+		},
+		{
+			name: "exempt check",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.HostNetwork = true
+				pod.Spec.Containers[0].SecurityContext.RunAsUser = &root
+			},
+			level:      LevelRestricted,
+			exemptions: []Exemption{{Check: "hostNamespaces"}},
+			want:       []string{"runAsUser: container web must not set securityContext.runAsUser=0"},
+		},
+		{
+			name: "exempt check for a container",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.Containers = append(pod.Spec.Containers, *pod.Spec.Containers[0].DeepCopy())
+				pod.Spec.Containers[1].Name = "sidecar"
+				pod.Spec.Containers[0].SecurityContext.RunAsUser = &root
+				pod.Spec.Containers[1].SecurityContext.RunAsUser = &root
+			},
+			level:      LevelRestricted,
+			exemptions: []Exemption{{Check: "runAsUser", Container: "sidecar"}},
+			want:       []string{"runAsUser: container web must not set securityContext.runAsUser=0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := restrictedPod()
+			if tt.mutate != nil {
+				tt.mutate(pod)
+			}
+
+			var got []string
+			for _, violation := range Evaluate(pod, tt.level, tt.exemptions) {
+				got = append(got, violation.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for value, want := range map[string]Level{"privileged": LevelPrivileged, " Baseline ": LevelBaseline, "RESTRICTED": LevelRestricted} {
+		if got, err := ParseLevel(value); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("strict"); err == nil {
+		t.Errorf("ParseLevel(strict) error = nil, want an error")
+	}
+}
+
+func TestParseExemption(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Exemption
+		wantErr bool
+	}{
+		{value: "runAsUser", want: Exemption{Check: "runAsUser"}},
+		{value: " hostPorts:web ", want: Exemption{Check: "hostPorts", Container: "web"}},
+		{value: "hostPort", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseExemption(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseExemption(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseExemption(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
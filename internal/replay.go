@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/suite"
+	"github.com/aumer-amr/k8s-policy-control/internal/webhooks"
+	"github.com/aumer-amr/k8s-policy-control/pkg/memory"
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+)
+
+// replayResponse is the part of an admission response that replay compares.
+type replayResponse struct {
+	Allowed  bool          `json:"allowed"`
+	Code     int32         `json:"code,omitempty"`
+	Reason   string        `json:"reason,omitempty"`
+	Message  string        `json:"message,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+	Patch    []interface{} `json:"patch,omitempty"`
+}
+
+// runReplay feeds captured admission requests through the current handlers and
+// diffs each new response against the captured one. It exits with 1 if any
+// response changed.
+func runReplay(args []string) int {
+	flags, opts := newCommandFlagSet("replay", "replay [flags] dir|file ...\n\nReplays the captures written by the manager with --admission-capture-dir.")
+	var configPath string
+	var contextPath string
+	var verbose bool
+	flags.StringVar(&configPath, "config", "", "Path to the config file. Defaults are used if empty.")
+	flags.StringVar(&contextPath, "context", "", "Directory or file of manifests, such as Namespaces, that handlers can read as cluster state.")
+	flags.BoolVar(&verbose, "v", false, "Also list unchanged responses.")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	setupCommandLogger(opts)
+
+	if err := loadCommandConfig(configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var stored []client.Object
+	if contextPath != "" {
+		read, err := readManifestTree(contextPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		for _, obj := range read {
+			if obj.Typed {
+				stored = append(stored, obj.Object)
+			}
+		}
+	}
+	store, err := memory.NewClient(scheme, memory.NewRESTMapper(scheme), stored...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	handlers := webhooks.Handlers(store, nil)
+
+	var files []string
+	for _, path := range flags.Args() {
+		found, err := findCaptures(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		files = append(files, found...)
+	}
+
+	changed := 0
+	for _, file := range files {
+		capture, err := webhooks.ReadCapture(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		handler, ok := handlers[capture.Path]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s: no handler for %s\n", file, capture.Path)
+			return 2
+		}
+
+		req := admission.Request{AdmissionRequest: *capture.Request}
+		resp := handler.Handle(context.Background(), req)
+		if err := resp.Complete(req); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			return 2
+		}
+		// Replayed Secrets only ever hold redacted data, so the new response is
+		// redacted too before comparing.
+		replayed := webhooks.Capture{Request: capture.Request, Response: &resp.AdmissionResponse}
+		webhooks.Redact(&replayed)
+
+		expected, err := renderReplayResponse(capture.Response)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			return 2
+		}
+		actual, err := renderReplayResponse(replayed.Response)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			return 2
+		}
+
+		request := fmt.Sprintf("%s %s %s/%s", capture.Path, capture.Request.Operation, capture.Request.Namespace, capture.Request.Name)
+		if expected == actual {
+			if verbose {
+				fmt.Fprintf(os.Stdout, "--- SAME: %s (%s)\n", file, request)
+			}
+			continue
+		}
+		changed++
+		fmt.Fprintf(os.Stdout, "--- CHANGED: %s (%s)\n", file, request)
+		fmt.Fprintf(os.Stdout, "    %s\n", strings.ReplaceAll(strings.TrimSuffix(suite.Diff(expected, actual), "\n"), "\n", "\n    "))
+	}
+
+	if changed > 0 {
+		fmt.Fprintf(os.Stdout, "CHANGED: %d of %d responses differ\n", changed, len(files))
+		return 1
+	}
+	fmt.Fprintf(os.Stdout, "ok: %d responses unchanged\n", len(files))
+	return 0
+}
+
+func renderReplayResponse(resp *admissionv1.AdmissionResponse) (string, error) {
+	out := replayResponse{
+		Allowed:  resp.Allowed,
+		Warnings: resp.Warnings,
+	}
+	if resp.Result != nil {
+		out.Code = resp.Result.Code
+		out.Reason = string(resp.Result.Reason)
+		out.Message = resp.Result.Message
+	}
+	if len(resp.Patch) > 0 {
+		if err := json.Unmarshal(resp.Patch, &out.Patch); err != nil {
+			return "", err
+		}
+		// Operation order is not significant for the patches handlers produce.
+		sort.SliceStable(out.Patch, func(i, j int) bool {
+			a, _ := json.Marshal(out.Patch[i])
+			b, _ := json.Marshal(out.Patch[j])
+			return string(a) < string(b)
+		})
+	}
+
+	data, err := yaml.Marshal(out)
+	return string(data), err
+}
+
+// findCaptures returns path if it is a file, or every .json file below it in
+// lexical order.
+func findCaptures(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.HasSuffix(file, ".json") {
+			files = append(files, file)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,91 @@
+package suite
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// matchObject compares expected as a subset of actual and describes every
+// mismatch by its field path.
+func matchObject(expected json.RawMessage, actual interface{}) []string {
+	var want interface{}
+	if err := json.Unmarshal(expected, &want); err != nil {
+		return []string{err.Error()}
+	}
+
+	data, err := json.Marshal(actual)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var got interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		return []string{err.Error()}
+	}
+
+	return matchValue("", want, got)
+}
+
+func matchValue(path string, want interface{}, got interface{}) []string {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		gotMap, ok := got.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %s", displayPath(path), describe(got))}
+		}
+
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var mismatches []string
+		for _, key := range keys {
+			gotValue, ok := gotMap[key]
+			if !ok {
+				mismatches = append(mismatches, fmt.Sprintf("%s: missing", displayPath(path+"."+key)))
+				continue
+			}
+			mismatches = append(mismatches, matchValue(path+"."+key, want[key], gotValue)...)
+		}
+		return mismatches
+
+	case []interface{}:
+		gotList, ok := got.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected a list, got %s", displayPath(path), describe(got))}
+		}
+		if len(want) != len(gotList) {
+			return []string{fmt.Sprintf("%s: expected %d items, got %d", displayPath(path), len(want), len(gotList))}
+		}
+
+		var mismatches []string
+		for i := range want {
+			mismatches = append(mismatches, matchValue(fmt.Sprintf("%s[%d]", path, i), want[i], gotList[i])...)
+		}
+		return mismatches
+
+	default:
+		if !reflect.DeepEqual(want, got) {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", displayPath(path), describe(want), describe(got))}
+		}
+		return nil
+	}
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path[1:]
+}
+
+func describe(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -0,0 +1,317 @@
+package suite
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"github.com/aumer-amr/k8s-policy-control/pkg/eval"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Options tune a suite run.
+type Options struct {
+	// Filter only runs tests whose name matches.
+	Filter *regexp.Regexp
+	// Update rewrites golden files with the actual output instead of comparing.
+	Update bool
+}
+
+// Result is the outcome of a single test.
+type Result struct {
+	Suite    string
+	Test     string
+	Failures []string
+}
+
+func (r Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Run runs every test of the suite with the suite config active. The config that
+// was active before is restored afterwards.
+func Run(scheme *runtime.Scheme, suite *Suite, opts Options) ([]Result, error) {
+	cfg := config.Default()
+	if len(suite.Config) > 0 {
+		var err error
+		// The config is YAML, which the JSON the suite was converted to is.
+		if cfg, err = config.Parse(suite.Config); err != nil {
+			return nil, fmt.Errorf("%s: config: %w", suite.Path, err)
+		}
+	}
+	previous := config.Current()
+	config.Set(cfg)
+	defer config.Set(previous)
+
+	var results []Result
+	for _, test := range suite.Tests {
+		if opts.Filter != nil && !opts.Filter.MatchString(test.Name) {
+			continue
+		}
+		result, err := runTest(scheme, suite, test, opts)
+		if err != nil {
+			return results, fmt.Errorf("%s: %s: %w", suite.Path, test.Name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func runTest(scheme *runtime.Scheme, suite *Suite, test Test, opts Options) (Result, error) {
+	result := Result{Suite: suite.Name, Test: test.Name}
+	source := suite.Path + ": " + test.Name
+
+	contextObjects, err := decodeObjects(scheme, source, test.Context...)
+	if err != nil {
+		return result, err
+	}
+	objects, err := decodeObjects(scheme, source, test.Object)
+	if err != nil {
+		return result, err
+	}
+	if len(objects) != 1 {
+		return result, fmt.Errorf("object: expected a single object, got %d", len(objects))
+	}
+
+	evaluated, err := eval.RunWithContext(scheme, contextObjects, objects)
+	if err != nil {
+		return result, err
+	}
+	if len(evaluated) == 0 {
+		return result, fmt.Errorf("object: no policy type exists for %s", objects[0].GetObjectKind().GroupVersionKind().Kind)
+	}
+	actual := evaluated[0]
+
+	fail := func(format string, args ...interface{}) {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+	}
+
+	expect := test.Expect
+	if expect.Denied != nil && *expect.Denied != actual.Denied() {
+		fail("denied: expected %t, got %t", *expect.Denied, actual.Denied())
+	}
+
+	if expect.Patch != nil {
+		expected, err := normalizePatch(*expect.Patch)
+		if err != nil {
+			return result, fmt.Errorf("expect.patch: %w", err)
+		}
+		actualPatch := make([]json.RawMessage, 0, len(actual.Patch))
+		for _, operation := range actual.Patch {
+			data, err := json.Marshal(operation)
+			if err != nil {
+				return result, err
+			}
+			actualPatch = append(actualPatch, data)
+		}
+		got, err := normalizePatch(actualPatch)
+		if err != nil {
+			return result, err
+		}
+		if !reflect.DeepEqual(expected, got) {
+			fail("patch:\n%s", Diff(strings.Join(expected, "\n"), strings.Join(got, "\n")))
+		}
+	}
+
+	if len(expect.Mutated) > 0 {
+		for _, mismatch := range matchObject(expect.Mutated, actual.Mutated) {
+			fail("mutated: %s", mismatch)
+		}
+	}
+
+	if expect.Generated != nil {
+		checkGenerated(*expect.Generated, actual.Generated, fail)
+	}
+
+	for _, decision := range expect.Decisions {
+		checkDecision(decision, actual, fail)
+	}
+
+	for _, golden := range expect.Golden {
+		if err := checkGolden(suite, golden, actual.Generated, opts.Update, fail); err != nil {
+			return result, fmt.Errorf("expect.golden %s: %w", golden.File, err)
+		}
+	}
+
+	return result, nil
+}
+
+// normalizePatch sorts the operations so that the order the patch was computed
+// in doesn't matter.
+func normalizePatch(operations []json.RawMessage) ([]string, error) {
+	normalized := make([]string, 0, len(operations))
+	for _, operation := range operations {
+		var decoded interface{}
+		if err := json.Unmarshal(operation, &decoded); err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(decoded)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, string(data))
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func checkGenerated(expected []json.RawMessage, generated []client.Object, fail func(string, ...interface{})) {
+	if len(expected) == 0 && len(generated) > 0 {
+		for _, obj := range generated {
+			fail("generated: unexpected %s", objectName(obj))
+		}
+		return
+	}
+
+	for _, raw := range expected {
+		var want map[string]interface{}
+		if err := json.Unmarshal(raw, &want); err != nil {
+			fail("generated: %v", err)
+			continue
+		}
+		name := nestedString(want, "kind") + "/" + joinName(nestedString(want, "metadata", "namespace"), nestedString(want, "metadata", "name"))
+
+		obj := findObject(generated, name)
+		if obj == nil {
+			fail("generated: %s not generated", name)
+			continue
+		}
+		for _, mismatch := range matchObject(raw, obj) {
+			fail("generated %s: %s", name, mismatch)
+		}
+	}
+}
+
+func checkDecision(expected Decision, actual eval.Result, fail func(string, ...interface{})) {
+	for _, decision := range actual.Decisions {
+		if decision.Policy != expected.Policy && policyKey(decision.Policy) != expected.Policy {
+			continue
+		}
+		if expected.Outcome != "" && decision.Outcome != expected.Outcome {
+			fail("decision %s: expected outcome %s, got %s (%s)", expected.Policy, expected.Outcome, decision.Outcome, decision.Message)
+		}
+		if expected.Message != "" && !strings.Contains(decision.Message, expected.Message) {
+			fail("decision %s: expected message containing %q, got %q", expected.Policy, expected.Message, decision.Message)
+		}
+		return
+	}
+	fail("decision %s: policy didn't run", expected.Policy)
+}
+
+// policyKey turns a policy name into its key without needing the policy itself.
+func policyKey(name string) string {
+	for _, p := range policy.AllPolicies() {
+		if p.Name() == name {
+			return policy.Key(p)
+		}
+	}
+	return name
+}
+
+func checkGolden(suite *Suite, golden Golden, generated []client.Object, update bool, fail func(string, ...interface{})) error {
+	obj := findObject(generated, golden.Object)
+	if obj == nil {
+		fail("golden %s: %s not generated", golden.File, golden.Object)
+		return nil
+	}
+
+	actual, err := goldenContent(obj, golden.Path)
+	if err != nil {
+		return err
+	}
+
+	path := golden.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(suite.Path), path)
+	}
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, []byte(actual), 0o644)
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if string(expected) != actual {
+		fail("golden %s:\n%s", golden.File, Diff(string(expected), actual))
+	}
+	return nil
+}
+
+// goldenContent returns the field at pointer, a string as is and anything else
+// as YAML.
+func goldenContent(obj client.Object, pointer string) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", err
+	}
+
+	if pointer != "" {
+		for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			fields, ok := value.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("path %s: not an object at %q", pointer, token)
+			}
+			if value, ok = fields[token]; !ok {
+				return "", fmt.Errorf("path %s: no field %q", pointer, token)
+			}
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	out, err := yaml.Marshal(value)
+	return string(out), err
+}
+
+func findObject(objects []client.Object, name string) client.Object {
+	for _, obj := range objects {
+		if objectName(obj) == name {
+			return obj
+		}
+	}
+	return nil
+}
+
+func objectName(obj client.Object) string {
+	return obj.GetObjectKind().GroupVersionKind().Kind + "/" + joinName(obj.GetNamespace(), obj.GetName())
+}
+
+func joinName(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+func nestedString(obj map[string]interface{}, fields ...string) string {
+	var value interface{} = obj
+	for _, field := range fields {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[field]
+	}
+	s, _ := value.(string)
+	return s
+}
@@ -0,0 +1,106 @@
+// Package suite runs declarative policy test suites. A suite is a YAML file
+// listing objects to run the compiled-in policies against, together with the
+// objects they can read and what the policies are expected to do.
+package suite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/manifest"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// Suite is a single suite file.
+type Suite struct {
+	Name string `json:"name"`
+	// Config is the config file the suite runs with, the defaults if empty.
+	Config json.RawMessage `json:"config,omitempty"`
+	Tests  []Test          `json:"tests"`
+
+	// Path is the file the suite was loaded from. Golden files are relative to
+	// its directory.
+	Path string `json:"-"`
+}
+
+// Test runs the policies against Object with Context loaded into the store.
+type Test struct {
+	Name    string            `json:"name"`
+	Context []json.RawMessage `json:"context,omitempty"`
+	Object  json.RawMessage   `json:"object"`
+	Expect  Expect            `json:"expect"`
+}
+
+// Expect lists what to check, anything left out isn't checked. Objects are
+// compared as subsets: every field given has to match, others are ignored.
+type Expect struct {
+	Denied *bool `json:"denied,omitempty"`
+	// Patch is the exact JSON patch, in any order. An empty list expects no
+	// mutation.
+	Patch *[]json.RawMessage `json:"patch,omitempty"`
+	// Mutated is compared against the object after all policies ran.
+	Mutated json.RawMessage `json:"mutated,omitempty"`
+	// Generated are matched by kind, namespace and name. An empty list expects
+	// nothing to be generated.
+	Generated *[]json.RawMessage `json:"generated,omitempty"`
+	Decisions []Decision         `json:"decisions,omitempty"`
+	Golden    []Golden           `json:"golden,omitempty"`
+}
+
+// Decision matches the decision of a policy, by name or key. Message only has
+// to be contained in the actual message.
+type Decision struct {
+	Policy  string `json:"policy"`
+	Outcome string `json:"outcome,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Golden compares a field of a generated object, or the whole object as YAML,
+// against the content of File.
+type Golden struct {
+	// Object is Kind/namespace/name, or Kind/name for cluster scoped objects.
+	Object string `json:"object"`
+	// Path is a JSON pointer into the object, e.g. /data/config.yaml. A string
+	// field is compared as is, anything else as YAML.
+	Path string `json:"path,omitempty"`
+	File string `json:"file"`
+}
+
+// Load reads a suite file.
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	suite := &Suite{}
+	if err := yaml.UnmarshalStrict(data, suite); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	suite.Path = path
+	if suite.Name == "" {
+		suite.Name = path
+	}
+	for i, test := range suite.Tests {
+		if len(test.Object) == 0 {
+			return nil, fmt.Errorf("%s: tests[%d] %q: object is required", path, i, test.Name)
+		}
+	}
+	return suite, nil
+}
+
+// decodeObjects decodes inline objects the way the eval command decodes files.
+func decodeObjects(scheme *runtime.Scheme, source string, raw ...json.RawMessage) ([]manifest.Object, error) {
+	var objects []manifest.Object
+	for _, data := range raw {
+		decoded, err := manifest.Decode(bytes.NewReader(data), source, scheme)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
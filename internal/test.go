@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/suite"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+)
+
+// runTest runs policy test suites against the compiled-in policies. It exits
+// with 1 if any test failed.
+func runTest(args []string) int {
+	flags, opts := newCommandFlagSet("test", "test [flags] [dir|file ...]\n\nRuns the given suite files, or every *_test.yaml file below the given directories or the current one.")
+	var run string
+	var update bool
+	var verbose bool
+	flags.StringVar(&run, "run", "", "Only run tests whose name matches this regular expression.")
+	flags.BoolVar(&update, "update", false, "Rewrite golden files with the actual output.")
+	flags.BoolVar(&verbose, "v", false, "Also list passing tests.")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	setupCommandLogger(opts)
+	config.Set(config.Default())
+
+	suiteOpts := suite.Options{Update: update}
+	if run != "" {
+		filter, err := regexp.Compile(run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-run: %v\n", err)
+			return 2
+		}
+		suiteOpts.Filter = filter
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var files []string
+	for _, path := range paths {
+		found, err := findSuites(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no test suites found")
+		return 2
+	}
+
+	failed := 0
+	total := 0
+	for _, file := range files {
+		loaded, err := suite.Load(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+
+		results, err := suite.Run(scheme, loaded, suiteOpts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+
+		for _, result := range results {
+			total++
+			if result.Passed() {
+				if verbose {
+					fmt.Fprintf(os.Stdout, "--- PASS: %s/%s\n", result.Suite, result.Test)
+				}
+				continue
+			}
+
+			failed++
+			fmt.Fprintf(os.Stdout, "--- FAIL: %s/%s\n", result.Suite, result.Test)
+			for _, failure := range result.Failures {
+				fmt.Fprintf(os.Stdout, "    %s\n", strings.ReplaceAll(strings.TrimSuffix(failure, "\n"), "\n", "\n    "))
+			}
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stdout, "FAIL: %d of %d tests failed\n", failed, total)
+		return 1
+	}
+	fmt.Fprintf(os.Stdout, "ok: %d tests passed\n", total)
+	return 0
+}
+
+// findSuites returns path if it is a file, or every *_test.yaml and *_test.yml
+// file below it in lexical order.
+func findSuites(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && (strings.HasSuffix(file, "_test.yaml") || strings.HasSuffix(file, "_test.yml")) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -1,5 +0,0 @@
-package main
-
-const (
-	KeepLimitsAnnotation = "k8s-ycl.bjw-s.dev/keep-limit"
-)
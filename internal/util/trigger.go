@@ -0,0 +1,115 @@
+package util
+
+import (
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// The controller trigger annotations are written under the canonical prefix and
+// read under any accepted one.
+var (
+	ControllerTriggerUid       = controllerTriggerKey("controller-trigger-uid")
+	ControllerTriggerGroup     = controllerTriggerKey("controller-trigger-group")
+	ControllerTriggerKind      = controllerTriggerKey("controller-trigger-kind")
+	ControllerTriggerVersion   = controllerTriggerKey("controller-trigger-version")
+	ControllerTriggerNamespace = controllerTriggerKey("controller-trigger-namespace")
+	ControllerTriggerName      = controllerTriggerKey("controller-trigger-name")
+)
+
+func controllerTriggerKey(name string) annotation.Key {
+	return annotation.Key{
+		Name:        name,
+		Type:        annotation.TypeString,
+		Description: "Set by the controller on generated and dependent objects, points at the object to reconcile when this one changes.",
+	}
+}
+
+// ControllerTrigger points at the parent object that has to be reconciled again
+// when the object carrying the trigger annotations changes.
+type ControllerTrigger struct {
+	Uid       string
+	Group     string
+	Version   string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (t ControllerTrigger) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: t.Group, Version: t.Version, Kind: t.Kind}
+}
+
+func (t ControllerTrigger) ObjectKey() client.ObjectKey {
+	return client.ObjectKey{Namespace: t.Namespace, Name: t.Name}
+}
+
+// NewControllerTrigger builds the trigger for the given parent, resolving its kind
+// through the scheme since typed objects from the cache have no TypeMeta.
+func NewControllerTrigger(parent client.Object, scheme *runtime.Scheme) (ControllerTrigger, error) {
+	gvk, err := apiutil.GVKForObject(parent, scheme)
+	if err != nil {
+		return ControllerTrigger{}, err
+	}
+
+	return ControllerTrigger{
+		Uid:       string(parent.GetUID()),
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: parent.GetNamespace(),
+		Name:      parent.GetName(),
+	}, nil
+}
+
+// SetControllerTrigger records the parent on obj. It returns false if obj already
+// pointed at the same parent.
+func SetControllerTrigger(obj metav1.Object, trigger ControllerTrigger) bool {
+	if current, ok := GetControllerTrigger(obj); ok && current == trigger {
+		return false
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ControllerTriggerUid.FullName()] = trigger.Uid
+	annotations[ControllerTriggerGroup.FullName()] = trigger.Group
+	annotations[ControllerTriggerVersion.FullName()] = trigger.Version
+	annotations[ControllerTriggerKind.FullName()] = trigger.Kind
+	annotations[ControllerTriggerNamespace.FullName()] = trigger.Namespace
+	annotations[ControllerTriggerName.FullName()] = trigger.Name
+	obj.SetAnnotations(annotations)
+	return true
+}
+
+func GetControllerTrigger(obj metav1.Object) (ControllerTrigger, bool) {
+	annotations := obj.GetAnnotations()
+	trigger := ControllerTrigger{
+		Uid:       ControllerTriggerUid.String(annotations),
+		Group:     ControllerTriggerGroup.String(annotations),
+		Version:   ControllerTriggerVersion.String(annotations),
+		Kind:      ControllerTriggerKind.String(annotations),
+		Namespace: ControllerTriggerNamespace.String(annotations),
+		Name:      ControllerTriggerName.String(annotations),
+	}
+
+	if trigger.Kind == "" || trigger.Version == "" || trigger.Name == "" {
+		return ControllerTrigger{}, false
+	}
+	return trigger, true
+}
+
+func init() {
+	annotation.Register(annotation.FrameworkOwner,
+		ControllerTriggerUid,
+		ControllerTriggerGroup,
+		ControllerTriggerKind,
+		ControllerTriggerVersion,
+		ControllerTriggerNamespace,
+		ControllerTriggerName,
+	)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/webhooks"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	webhookCertName = "tls.crt"
+	webhookKeyName  = "tls.key"
+)
+
+// setupWebhooks registers every admission handler. If captureDir is set, each
+// request and response is also written there for the replay command.
+func setupWebhooks(mgr ctrl.Manager, captureDir string) {
+	handlers := webhooks.Handlers(mgr.GetClient(), mgr.GetEventRecorderFor("policy-control"))
+	for _, path := range webhooks.Paths(handlers) {
+		handler := handlers[path]
+		if captureDir != "" {
+			handler = &webhooks.CaptureHandler{Path: path, Dir: captureDir, Handler: handler}
+		}
+		mgr.GetWebhookServer().Register(path, &webhook.Admission{Handler: handler})
+		setupLog.Info("registered webhook", "path", path, "capture", captureDir != "")
+	}
+}
+
+func newWebhookServer(port int, certDir string) webhook.Server {
+	return webhook.NewServer(webhook.Options{
+		Port:     port,
+		CertDir:  certDir,
+		CertName: webhookCertName,
+		KeyName:  webhookKeyName,
+	})
+}
+
+// webhookCertChecker is ready while the serving certificate in certDir exists and
+// is within its validity period.
+func webhookCertChecker(certDir string) healthz.Checker {
+	certPath := filepath.Join(certDir, webhookCertName)
+
+	return func(_ *http.Request) error {
+		data, err := os.ReadFile(certPath)
+		if err != nil {
+			return fmt.Errorf("unable to read webhook certificate: %w", err)
+		}
+
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return fmt.Errorf("no PEM data in %s", certPath)
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("unable to parse webhook certificate: %w", err)
+		}
+
+		now := time.Now()
+		if now.Before(cert.NotBefore) {
+			return fmt.Errorf("webhook certificate not valid before %s", cert.NotBefore)
+		}
+		if now.After(cert.NotAfter) {
+			return fmt.Errorf("webhook certificate expired at %s", cert.NotAfter)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,205 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Redacted replaces the values of Secret data in captures.
+const Redacted = "REDACTED"
+
+// Capture is an admission request and the response it got, as written to disk.
+type Capture struct {
+	Path     string                         `json:"path"`
+	Time     time.Time                      `json:"time"`
+	Request  *admissionv1.AdmissionRequest  `json:"request"`
+	Response *admissionv1.AdmissionResponse `json:"response"`
+}
+
+// CaptureHandler writes every request and response of Handler to a file in Dir.
+// Secret data is redacted before it is written. Failing to write a capture is
+// logged and doesn't affect the response.
+type CaptureHandler struct {
+	Path    string
+	Dir     string
+	Handler admission.Handler
+
+	mu sync.Mutex
+	n  int
+}
+
+func (h *CaptureHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	resp := h.Handler.Handle(ctx, req)
+
+	// Complete fills in the patch the way the webhook server sends it. It works
+	// on a copy so the server can still complete the original.
+	completed := resp
+	completed.AdmissionResponse = *resp.AdmissionResponse.DeepCopy()
+	if err := completed.Complete(req); err != nil {
+		webhooksLog.Error(err, "unable to capture admission response", "path", h.Path, "uid", req.UID)
+		return resp
+	}
+
+	capture := Capture{
+		Path:     h.Path,
+		Time:     time.Now().UTC(),
+		Request:  req.AdmissionRequest.DeepCopy(),
+		Response: &completed.AdmissionResponse,
+	}
+	if err := h.write(capture); err != nil {
+		webhooksLog.Error(err, "unable to capture admission request", "path", h.Path, "uid", req.UID)
+	}
+	return resp
+}
+
+func (h *CaptureHandler) write(capture Capture) error {
+	Redact(&capture)
+	data, err := json.MarshalIndent(capture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.n++
+	name := fmt.Sprintf("%s-%06d-%s.json", capture.Time.Format("20060102T150405Z"), h.n, strings.Trim(strings.ReplaceAll(h.Path, "/", "-"), "-"))
+	h.mu.Unlock()
+
+	if err := os.MkdirAll(h.Dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(h.Dir, name), data, 0o600)
+}
+
+// ReadCapture reads a capture written by CaptureHandler.
+func ReadCapture(path string) (Capture, error) {
+	var capture Capture
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return capture, err
+	}
+	if err := json.Unmarshal(data, &capture); err != nil {
+		return capture, fmt.Errorf("%s: %w", path, err)
+	}
+	if capture.Request == nil || capture.Response == nil {
+		return capture, fmt.Errorf("%s: not an admission capture", path)
+	}
+	return capture, nil
+}
+
+// Redact replaces the values of data and stringData in Secrets with Redacted,
+// in the admitted objects, in Secrets nested in the items of a List, and in the
+// patch of the response to a Secret. Keys are kept so that policies looking for
+// them behave the same on replay.
+func Redact(capture *Capture) {
+	if capture.Request == nil {
+		return
+	}
+	secret := capture.Request.Kind.Group == "" && capture.Request.Kind.Kind == "Secret"
+	capture.Request.Object = redactObject(capture.Request.Object, secret)
+	capture.Request.OldObject = redactObject(capture.Request.OldObject, secret)
+
+	if !secret {
+		return
+	}
+	if capture.Response == nil || len(capture.Response.Patch) == 0 {
+		return
+	}
+	var patch []map[string]interface{}
+	if err := json.Unmarshal(capture.Response.Patch, &patch); err != nil {
+		capture.Response.Patch = nil
+		return
+	}
+	for _, op := range patch {
+		path, _ := op["path"].(string)
+		value, ok := op["value"]
+		if !ok {
+			continue
+		}
+		switch {
+		case path == "/data" || path == "/stringData":
+			op["value"] = redactValues(value, path == "/data")
+		case strings.HasPrefix(path, "/data/"):
+			op["value"] = redactedData
+		case strings.HasPrefix(path, "/stringData/"):
+			op["value"] = Redacted
+		}
+	}
+	capture.Response.Patch, _ = json.Marshal(patch)
+}
+
+var redactedData = base64.StdEncoding.EncodeToString([]byte(Redacted))
+
+// redactObject redacts raw, which is a Secret itself when secret is set. Objects
+// without a Secret in them are returned as they are, and Secrets that can't be
+// read are dropped.
+func redactObject(raw runtime.RawExtension, secret bool) runtime.RawExtension {
+	if len(raw.Raw) == 0 {
+		return raw
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw.Raw, &obj); err != nil {
+		if secret {
+			return runtime.RawExtension{}
+		}
+		return raw
+	}
+	if !redactSecrets(obj, secret) {
+		return raw
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return runtime.RawExtension{}
+	}
+	return runtime.RawExtension{Raw: data}
+}
+
+// redactSecrets redacts obj when it is a Secret, and every Secret in its items,
+// and reports whether anything was redacted.
+func redactSecrets(obj map[string]interface{}, secret bool) bool {
+	redacted := false
+	if secret || (obj["apiVersion"] == "v1" && obj["kind"] == "Secret") {
+		for _, field := range []string{"data", "stringData"} {
+			if values, ok := obj[field]; ok {
+				obj[field] = redactValues(values, field == "data")
+				redacted = true
+			}
+		}
+	}
+	if items, ok := obj["items"].([]interface{}); ok {
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				redacted = redactSecrets(m, false) || redacted
+			}
+		}
+	}
+	return redacted
+}
+
+// redactValues replaces every value of a data or stringData map. Data values
+// stay valid base64 so the Secret still decodes.
+func redactValues(values interface{}, encoded bool) interface{} {
+	m, ok := values.(map[string]interface{})
+	if !ok {
+		return values
+	}
+	redacted := make(map[string]interface{}, len(m))
+	for key := range m {
+		if encoded {
+			redacted[key] = redactedData
+		} else {
+			redacted[key] = Redacted
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,170 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// secretValues are the values the Secrets of the tests hold, none of them may
+// survive in a capture.
+var secretValues = []string{"old-password", "new-password", "new-token", "patched-token", "list-password"}
+
+func rawObject(t *testing.T, obj interface{}) runtime.RawExtension {
+	t.Helper()
+	data, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	return runtime.RawExtension{Raw: data}
+}
+
+func newSecret(values map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "credentials"},
+		Data:       map[string][]byte{},
+		StringData: map[string]string{},
+	}
+	for key, value := range values {
+		secret.Data[key] = []byte(value)
+		secret.StringData[key+"-string"] = value
+	}
+	return secret
+}
+
+// patchHandler patches the admitted Secret with an extra data key.
+type patchHandler struct{}
+
+func (patchHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	secret := &corev1.Secret{}
+	if err := json.Unmarshal(req.Object.Raw, secret); err != nil {
+		return admission.Errored(1, err)
+	}
+	secret.Data["patched"] = []byte("patched-token")
+	secret.StringData = map[string]string{"patched-string": "patched-token"}
+	patched, err := json.Marshal(secret)
+	if err != nil {
+		return admission.Errored(1, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, patched)
+}
+
+func TestCaptureHandlerRedactsSecrets(t *testing.T) {
+	secretKind := metav1.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	list := &corev1.List{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "List"},
+		Items: []runtime.RawExtension{
+			rawObject(t, newSecret(map[string]string{"password": "list-password"})),
+			rawObject(t, &corev1.ConfigMap{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "settings"},
+				Data:       map[string]string{"level": "debug"},
+			}),
+		},
+	}
+
+	tests := []struct {
+		name    string
+		request admissionv1.AdmissionRequest
+		handler admission.Handler
+		want    []string
+	}{
+		{
+			name: "data and stringData",
+			request: admissionv1.AdmissionRequest{
+				Kind:      secretKind,
+				Operation: admissionv1.Create,
+				Object:    rawObject(t, newSecret(map[string]string{"password": "new-password", "token": "new-token"})),
+			},
+			handler: patchHandler{},
+			want:    []string{`"password"`, `"token-string"`, `/data/patched`},
+		},
+		{
+			name: "oldObject",
+			request: admissionv1.AdmissionRequest{
+				Kind:      secretKind,
+				Operation: admissionv1.Update,
+				Object:    rawObject(t, newSecret(map[string]string{"password": "new-password"})),
+				OldObject: rawObject(t, newSecret(map[string]string{"password": "old-password"})),
+			},
+			handler: patchHandler{},
+			want:    []string{`"password-string"`},
+		},
+		{
+			name: "Secret nested in a List",
+			request: admissionv1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "List"},
+				Operation: admissionv1.Create,
+				Object:    rawObject(t, list),
+			},
+			handler: admission.HandlerFunc(func(ctx context.Context, req admission.Request) admission.Response {
+				return admission.Allowed("")
+			}),
+			want: []string{`"password"`, `"debug"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			h := &CaptureHandler{Path: "/mutate-v1-secret", Dir: dir, Handler: tt.handler}
+			tt.request.UID = "uid"
+			h.Handle(context.Background(), admission.Request{AdmissionRequest: tt.request})
+
+			files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+			if err != nil || len(files) != 1 {
+				t.Fatalf("captures = %v (%v), want one", files, err)
+			}
+			data, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			capture, err := ReadCapture(files[0])
+			if err != nil {
+				t.Fatalf("ReadCapture() error = %v", err)
+			}
+
+			// The patch is serialized as base64 inside the capture, so the objects
+			// and the patch are checked as read back as well.
+			serialized := []string{string(data), string(capture.Request.Object.Raw), string(capture.Request.OldObject.Raw), string(capture.Response.Patch)}
+			for _, s := range serialized {
+				for _, value := range secretValues {
+					if strings.Contains(s, value) || strings.Contains(s, base64.StdEncoding.EncodeToString([]byte(value))) {
+						t.Errorf("capture contains the Secret value %q:\n%s", value, s)
+					}
+				}
+			}
+			joined := strings.Join(serialized, "\n")
+			for _, want := range tt.want {
+				if !strings.Contains(joined, want) {
+					t.Errorf("capture doesn't contain %s:\n%s", want, joined)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactDropsUnreadableSecrets(t *testing.T) {
+	capture := Capture{
+		Request: &admissionv1.AdmissionRequest{
+			Kind:   metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+			Object: runtime.RawExtension{Raw: []byte(`{"data": {"password": "bmV3LXBhc3N3b3Jk"`)},
+		},
+		Response: &admissionv1.AdmissionResponse{Patch: []byte(`[{"op": "add", "path": "/data/token", "value": "bmV3LXRva2Vu"`)},
+	}
+	Redact(&capture)
+	if len(capture.Request.Object.Raw) != 0 || len(capture.Response.Patch) != 0 {
+		t.Errorf("object = %s, patch = %s, want both dropped", capture.Request.Object.Raw, capture.Response.Patch)
+	}
+}
@@ -0,0 +1,30 @@
+package webhooks
+
+import (
+	"sort"
+
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Handlers returns every admission handler by the path it is served on. The
+// manager and the replay command both build them here, so a replay runs exactly
+// what the manager would.
+func Handlers(c client.Client, recorder record.EventRecorder) map[string]admission.Handler {
+	return map[string]admission.Handler{
+		LintPath:          &LintHandler{},
+		PrefixRewritePath: &PrefixRewriteHandler{},
+		PolicyPath:        &PolicyHandler{Client: c, Recorder: recorder},
+	}
+}
+
+// Paths returns the paths of handlers in sorted order.
+func Paths(handlers map[string]admission.Handler) []string {
+	paths := make([]string, 0, len(handlers))
+	for path := range handlers {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
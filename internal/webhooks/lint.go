@@ -0,0 +1,53 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/lint"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	LintPath = "/validate-annotations"
+)
+
+var (
+	webhooksLog = ctrl.Log.WithName("webhooks")
+)
+
+// LintHandler validates the policy annotations of any object. Findings are
+// returned as admission warnings, and reject the object if the config asks to.
+type LintHandler struct{}
+
+func (h *LintHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if len(req.Object.Raw) == 0 {
+		return admission.Allowed("")
+	}
+
+	obj := metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	findings := lint.Annotations(obj.GetAnnotations())
+	if len(findings) == 0 {
+		return admission.Allowed("")
+	}
+
+	warnings := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		warnings = append(warnings, finding.String())
+	}
+	webhooksLog.Info("annotation lint findings", "kind", req.Kind.Kind, "namespace", req.Namespace, "name", req.Name, "findings", warnings)
+
+	if config.Current().Lint.Deny && lint.HasErrors(findings) {
+		return admission.Denied(strings.Join(warnings, "; ")).WithWarnings(warnings...)
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
@@ -0,0 +1,104 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	PolicyPath = "/mutate-policies"
+)
+
+// PolicyHandler runs the admission policies for the type of the admitted object.
+// Mutations are returned as a patch, and a denial rejects the object unless the
+// policy is in audit mode.
+type PolicyHandler struct {
+	Client client.Client
+	// Recorder is nil when replaying, Events are dropped then.
+	Recorder record.EventRecorder
+}
+
+func (h *PolicyHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if len(req.Object.Raw) == 0 {
+		return admission.Allowed("")
+	}
+
+	gvk := schema.GroupVersionKind(req.Kind)
+	obj, err := h.Client.Scheme().New(gvk)
+	if err != nil {
+		return admission.Allowed("")
+	}
+	policyType := policy.TypeForObject(obj)
+	if policyType == policy.PolicyTypeUnknown {
+		return admission.Allowed("")
+	}
+
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	accessor := obj.(client.Object)
+	if accessor.GetNamespace() == "" {
+		accessor.SetNamespace(req.Namespace)
+	}
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	env := policy.NewEnv(h.Client)
+	if req.DryRun != nil && *req.DryRun {
+		env.Client = client.NewDryRunClient(h.Client)
+	}
+	env.Recorder = h.Recorder
+	env.RequestUID = string(req.UID)
+	env.UserInfo = req.UserInfo.DeepCopy()
+	env.Operation = string(req.Operation)
+	if len(req.OldObject.Raw) > 0 {
+		oldObj, err := h.Client.Scheme().New(gvk)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if err := json.Unmarshal(req.OldObject.Raw, oldObj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		env.OldObject = oldObj
+	}
+
+	decisions, err := policy.ApplyAdmissionPolicies(policyType, obj, env)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	var denials []string
+	var warnings []string
+	for _, decision := range decisions {
+		switch {
+		case decision.Outcome == audit.OutcomeDenied:
+			denials = append(denials, decision.Policy+": "+decision.Message)
+		case decision.Outcome == audit.OutcomeAudited && decision.Message != "":
+			warnings = append(warnings, decision.Policy+" (audit): "+decision.Message)
+		}
+	}
+	if len(denials) > 0 {
+		webhooksLog.Info("policies denied object", "kind", req.Kind.Kind, "namespace", req.Namespace, "name", req.Name, "denials", denials)
+		return admission.Denied(strings.Join(denials, "; ")).WithWarnings(warnings...)
+	}
+
+	mutated, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if string(mutated) == string(original) {
+		return admission.Allowed("").WithWarnings(warnings...)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, mutated).WithWarnings(warnings...)
+}
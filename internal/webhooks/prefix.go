@@ -0,0 +1,47 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"gomodules.xyz/jsonpatch/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	PrefixRewritePath = "/mutate-annotation-prefixes"
+)
+
+// PrefixRewriteHandler moves annotations under a deprecated prefix to the
+// canonical prefix, if the config turns rewriting on. Otherwise it only warns.
+type PrefixRewriteHandler struct{}
+
+func (h *PrefixRewriteHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if len(req.Object.Raw) == 0 {
+		return admission.Allowed("")
+	}
+
+	obj := metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	rewritten, moved := annotation.RewriteDeprecated(obj.GetAnnotations())
+	if len(moved) == 0 {
+		return admission.Allowed("")
+	}
+
+	cfg := config.Current()
+	if !cfg.RewriteDeprecatedPrefixes {
+		return admission.Allowed("").WithWarnings("annotations use a deprecated prefix, move them to " + cfg.AnnotationPrefix + ": " + strings.Join(moved, ", "))
+	}
+
+	webhooksLog.Info("rewriting deprecated annotation prefixes", "kind", req.Kind.Kind, "namespace", req.Namespace, "name", req.Name, "annotations", moved)
+	return admission.Patched("rewrote deprecated annotation prefixes", jsonpatch.NewOperation("replace", "/metadata/annotations", rewritten)).
+		WithWarnings("moved annotations to " + cfg.AnnotationPrefix + ": " + strings.Join(moved, ", "))
+}
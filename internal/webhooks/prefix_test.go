@@ -0,0 +1,84 @@
+package webhooks
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestPrefixRewriteHandler(t *testing.T) {
+	annotations := map[string]string{
+		"k8s-ycl.bjw-s.dev/keep-limit":       "true",
+		"k8s-ycl.bjw-s.dev/gatus-name":       "legacy",
+		"policy-control.aumer.io/gatus-name": "canonical",
+	}
+
+	tests := []struct {
+		name        string
+		rewrite     bool
+		annotations map[string]string
+		wantPatch   map[string]string
+		wantWarning string
+	}{
+		{
+			name:        "nothing deprecated",
+			annotations: map[string]string{"policy-control.aumer.io/keep-limit": "true"},
+		},
+		{
+			name:        "warns without rewriting",
+			annotations: annotations,
+			wantWarning: "annotations use a deprecated prefix, move them to policy-control.aumer.io/: k8s-ycl.bjw-s.dev/gatus-name, k8s-ycl.bjw-s.dev/keep-limit",
+		},
+		{
+			name:        "rewrites to the canonical prefix",
+			rewrite:     true,
+			annotations: annotations,
+			wantPatch:   map[string]string{"policy-control.aumer.io/keep-limit": "true", "policy-control.aumer.io/gatus-name": "canonical"},
+			wantWarning: "moved annotations to policy-control.aumer.io/: k8s-ycl.bjw-s.dev/gatus-name, k8s-ycl.bjw-s.dev/keep-limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.RewriteDeprecatedPrefixes = tt.rewrite
+			config.Set(cfg)
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			configMap := &corev1.ConfigMap{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "settings", Annotations: tt.annotations},
+			}
+			resp := (&PrefixRewriteHandler{}).Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Kind:   metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+				Object: rawObject(t, configMap),
+			}})
+
+			if !resp.Allowed {
+				t.Fatalf("response = %+v, want allowed", resp.Result)
+			}
+			if got := strings.Join(resp.Warnings, "\n"); got != tt.wantWarning {
+				t.Errorf("warnings = %q, want %q", got, tt.wantWarning)
+			}
+			if tt.wantPatch == nil {
+				if len(resp.Patches) > 0 {
+					t.Errorf("patches = %v, want none", resp.Patches)
+				}
+				return
+			}
+			if len(resp.Patches) != 1 || resp.Patches[0].Operation != "replace" || resp.Patches[0].Path != "/metadata/annotations" {
+				t.Fatalf("patches = %v, want a single replace of /metadata/annotations", resp.Patches)
+			}
+			if got, ok := resp.Patches[0].Value.(map[string]string); !ok || !reflect.DeepEqual(got, tt.wantPatch) {
+				t.Errorf("annotations = %v, want %v", resp.Patches[0].Value, tt.wantPatch)
+			}
+		})
+	}
+}
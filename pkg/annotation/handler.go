@@ -0,0 +1,22 @@
+package annotation
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler serves the output of Explain as JSON. The owner query parameter limits
+// it to a single owner.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		schemas := Explain(r.URL.Query().Get("owner"))
+		if schemas == nil {
+			schemas = []Schema{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(schemas); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,327 @@
+package annotation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// Level is where in the inheritance hierarchy a value was found.
+type Level string
+
+const (
+	LevelObject    Level = "object"
+	LevelOwner     Level = "owner"
+	LevelNamespace Level = "namespace"
+	LevelCluster   Level = "cluster"
+)
+
+// Layer is one level of the hierarchy. Layers are searched in the order they are
+// passed to NewParser, the first one setting a key wins.
+type Layer struct {
+	Level Level
+	// Source names the object the annotations came from, e.g. Namespace/default.
+	Source      string
+	Annotations map[string]string
+}
+
+// Origin records where an effective value came from.
+type Origin struct {
+	Level  Level
+	Source string
+}
+
+func (o Origin) String() string {
+	if o.Source == "" {
+		return string(o.Level)
+	}
+	return string(o.Level) + " " + o.Source
+}
+
+// ParseError is a value that doesn't parse as the type of its key.
+type ParseError struct {
+	Key    string
+	Value  string
+	Origin Origin
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Origin.Level != "" && e.Origin.Level != LevelObject {
+		return fmt.Sprintf("annotation %s (inherited from %s): %v", e.Key, e.Origin, e.Err)
+	}
+	return fmt.Sprintf("annotation %s: %v", e.Key, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Errors holds one ParseError per key that failed to parse.
+type Errors []*ParseError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Parser reads typed annotation values off an object. A value that fails to
+// parse is recorded and the default is returned in its place, so a policy can
+// read everything first and decide what to do with Err afterwards.
+type Parser struct {
+	layers  []Layer
+	origins map[string]Origin
+	errs    Errors
+}
+
+// NewParser reads the annotations of a single object, nothing is inherited.
+func NewParser(annotations map[string]string) *Parser {
+	return NewLayeredParser(Layer{Level: LevelObject, Annotations: annotations})
+}
+
+// NewLayeredParser reads through layers, most specific first. Only keys marked
+// Inherit are looked up past the object layer.
+func NewLayeredParser(layers ...Layer) *Parser {
+	return &Parser{layers: layers, origins: map[string]Origin{}}
+}
+
+// Origin returns where the value of k was read from, if it was set at all.
+func (p *Parser) Origin(k Key) (Origin, bool) {
+	origin, ok := p.origins[k.FullName()]
+	return origin, ok
+}
+
+// Inherited returns the origin of every value read so far that didn't come from
+// the object itself, keyed by full annotation key.
+func (p *Parser) Inherited() map[string]string {
+	inherited := map[string]string{}
+	for key, origin := range p.origins {
+		if origin.Level != LevelObject {
+			inherited[key] = origin.String()
+		}
+	}
+	return inherited
+}
+
+// Err returns the parse errors so far as Errors, or nil if there are none.
+func (p *Parser) Err() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return p.errs
+}
+
+func (p *Parser) fail(k Key, value string, err error) {
+	for _, existing := range p.errs {
+		if existing.Key == k.FullName() {
+			return
+		}
+	}
+	p.errs = append(p.errs, &ParseError{Key: k.FullName(), Value: value, Origin: p.origins[k.FullName()], Err: err})
+}
+
+// raw returns the annotation value from the first layer setting it, or fallback
+// and false if none does.
+func (p *Parser) raw(k Key, fallback string) (string, bool) {
+	for _, layer := range p.layers {
+		if layer.Level != LevelObject && !k.Inherit {
+			continue
+		}
+		if val, ok := k.Value(layer.Annotations); ok {
+			p.origins[k.FullName()] = Origin{Level: layer.Level, Source: layer.Source}
+			return val, true
+		}
+	}
+	return fallback, false
+}
+
+// String returns the annotation value or fallback. Enum values are checked
+// against the allowed values.
+func (p *Parser) String(k Key, fallback string) string {
+	val, set := p.raw(k, fallback)
+	if set && k.Type == TypeEnum {
+		if err := k.Check(val); err != nil {
+			p.fail(k, val, err)
+			return fallback
+		}
+	}
+	return val
+}
+
+// Bool returns the annotation value, or the key default if it isn't set. The
+// second result reports whether the annotation was set to a valid value.
+func (p *Parser) Bool(k Key) (bool, bool) {
+	fallback, _ := ParseBool(k.Default)
+	val, set := p.raw(k, "")
+	if !set {
+		return fallback, false
+	}
+	b, err := ParseBool(val)
+	if err != nil {
+		p.fail(k, val, err)
+		return fallback, false
+	}
+	return b, true
+}
+
+func (p *Parser) Int(k Key, fallback int) int {
+	val, set := p.raw(k, "")
+	if !set {
+		return fallback
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil {
+		p.fail(k, val, fmt.Errorf("expected an integer, got %q", val))
+		return fallback
+	}
+	return i
+}
+
+func (p *Parser) Duration(k Key, fallback time.Duration) time.Duration {
+	val, set := p.raw(k, "")
+	if !set {
+		return fallback
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(val))
+	if err != nil {
+		p.fail(k, val, fmt.Errorf("expected a duration such as 30s or 5m, got %q", val))
+		return fallback
+	}
+	return d
+}
+
+func (p *Parser) Float(k Key, fallback float64) float64 {
+	val, set := p.raw(k, "")
+	if !set {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+	if err != nil {
+		p.fail(k, val, fmt.Errorf("expected a number such as 1.5, got %q", val))
+		return fallback
+	}
+	return f
+}
+
+// Quantity parses a resource quantity such as 100m or 1Gi. The fallback is
+// returned as is if the annotation isn't set or fails to parse.
+func (p *Parser) Quantity(k Key, fallback resource.Quantity) resource.Quantity {
+	val, set := p.raw(k, "")
+	if !set {
+		return fallback
+	}
+	q, err := resource.ParseQuantity(strings.TrimSpace(val))
+	if err != nil {
+		p.fail(k, val, fmt.Errorf("expected a quantity such as 100m or 1Gi, got %q", val))
+		return fallback
+	}
+	return q
+}
+
+// List returns the items of the annotation, or of the key default if it isn't
+// set or is empty. Keys without a default return nil for an empty annotation,
+// which callers can tell apart from an unset one with Origin. See SplitList for
+// the format.
+func (p *Parser) List(k Key) []string {
+	val, set := p.raw(k, k.Default)
+	if strings.TrimSpace(val) == "" {
+		val = k.Default
+	}
+	if val == "" {
+		return nil
+	}
+	items, err := SplitList(val)
+	if err != nil {
+		if set {
+			p.fail(k, val, err)
+		}
+		items, _ = SplitList(k.Default)
+	}
+	return items
+}
+
+// Decode unmarshals a JSON or YAML annotation value into out. It reports
+// whether the annotation was set and decoded.
+func (p *Parser) Decode(k Key, out interface{}) bool {
+	val, set := p.raw(k, "")
+	if !set {
+		return false
+	}
+	if err := yaml.UnmarshalStrict([]byte(val), out); err != nil {
+		p.fail(k, val, fmt.Errorf("expected JSON or YAML: %w", err))
+		return false
+	}
+	return true
+}
+
+// ParseBool accepts true/false, yes/no and 1/0, ignoring case.
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "1":
+		return true, nil
+	case "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected true/false, yes/no or 1/0, got %q", value)
+}
+
+// SplitList splits a comma separated list and trims every item. A comma or
+// backslash inside an item is escaped with a backslash, e.g. a\,b is the
+// single item "a,b". Empty items are rejected.
+func SplitList(value string) ([]string, error) {
+	var items []string
+	var item strings.Builder
+	escaped := false
+
+	add := func() error {
+		trimmed := strings.TrimSpace(item.String())
+		if trimmed == "" {
+			return fmt.Errorf("empty item in list %q", value)
+		}
+		items = append(items, trimmed)
+		item.Reset()
+		return nil
+	}
+
+	for _, r := range value {
+		switch {
+		case escaped:
+			item.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			if err := add(); err != nil {
+				return nil, err
+			}
+		default:
+			item.WriteRune(r)
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in list %q", value)
+	}
+	if err := add(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ClusterLayer returns the annotation defaults of the current config as the last
+// layer of the hierarchy.
+func ClusterLayer() Layer {
+	cfg := config.Current()
+	annotations := make(map[string]string, len(cfg.AnnotationDefaults))
+	for name, value := range cfg.AnnotationDefaults {
+		annotations[cfg.AnnotationKey(name)] = value
+	}
+	return Layer{Level: LevelCluster, Source: "config", Annotations: annotations}
+}
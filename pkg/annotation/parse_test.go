@@ -0,0 +1,209 @@
+package annotation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{value: "true", want: true},
+		{value: "True", want: true},
+		{value: " YES ", want: true},
+		{value: "1", want: true},
+		{value: "false"},
+		{value: "No"},
+		{value: "0"},
+		{value: "", wantErr: true},
+		{value: "on", wantErr: true},
+		{value: "2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseBool(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBool(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBool(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "a", want: []string{"a"}},
+		{value: "a, b ,c", want: []string{"a", "b", "c"}},
+		{value: `a\,b, c`, want: []string{"a,b", "c"}},
+		{value: `[BODY].name == any(a\, b), [STATUS] == 200`, want: []string{"[BODY].name == any(a, b)", "[STATUS] == 200"}},
+		{value: `a\\, b`, want: []string{`a\`, "b"}},
+		{value: `a\b`, want: []string{"ab"}},
+		{value: "a,,b", wantErr: true},
+		{value: "a,", wantErr: true},
+		{value: " ", wantErr: true},
+		{value: `a\`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := SplitList(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitList(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitList(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParserTypedValues(t *testing.T) {
+	var (
+		boolKey     = Key{Name: "test-bool", Type: TypeBool, Default: "true"}
+		intKey      = Key{Name: "test-int", Type: TypeInt}
+		durationKey = Key{Name: "test-duration", Type: TypeDuration}
+		quantityKey = Key{Name: "test-quantity", Type: TypeQuantity}
+		enumKey     = Key{Name: "test-enum", Type: TypeEnum, Allowed: []string{"http", "https"}}
+		listKey     = Key{Name: "test-list", Type: TypeList, Default: "x, y"}
+		decodeKey   = Key{Name: "test-decode", Type: TypeJSON}
+	)
+
+	t.Run("valid values", func(t *testing.T) {
+		p := NewParser(map[string]string{
+			boolKey.FullName():     "no",
+			intKey.FullName():      " 3 ",
+			durationKey.FullName(): "90s",
+			quantityKey.FullName(): "512Mi",
+			enumKey.FullName():     "http",
+			listKey.FullName():     `a\,b, c`,
+			decodeKey.FullName():   `{"name": "web", "port": 80}`,
+		})
+
+		if b, set := p.Bool(boolKey); b || !set {
+			t.Errorf("Bool() = %v, %v, want false, true", b, set)
+		}
+		if i := p.Int(intKey, 1); i != 3 {
+			t.Errorf("Int() = %d, want 3", i)
+		}
+		if d := p.Duration(durationKey, time.Second); d != 90*time.Second {
+			t.Errorf("Duration() = %s, want 1m30s", d)
+		}
+		if q := p.Quantity(quantityKey, resource.Quantity{}); q.Cmp(resource.MustParse("512Mi")) != 0 {
+			t.Errorf("Quantity() = %s, want 512Mi", q.String())
+		}
+		if s := p.String(enumKey, "https"); s != "http" {
+			t.Errorf("String() = %q, want http", s)
+		}
+		if l := p.List(listKey); !reflect.DeepEqual(l, []string{"a,b", "c"}) {
+			t.Errorf("List() = %q, want [a,b c]", l)
+		}
+		var decoded struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		}
+		if !p.Decode(decodeKey, &decoded) || decoded.Name != "web" || decoded.Port != 80 {
+			t.Errorf("Decode() = %+v, want {web 80}", decoded)
+		}
+		if err := p.Err(); err != nil {
+			t.Errorf("Err() = %v, want nil", err)
+		}
+	})
+
+	t.Run("defaults when unset", func(t *testing.T) {
+		p := NewParser(nil)
+
+		if b, set := p.Bool(boolKey); !b || set {
+			t.Errorf("Bool() = %v, %v, want the default true, false", b, set)
+		}
+		if i := p.Int(intKey, 7); i != 7 {
+			t.Errorf("Int() = %d, want 7", i)
+		}
+		if l := p.List(listKey); !reflect.DeepEqual(l, []string{"x", "y"}) {
+			t.Errorf("List() = %q, want the default [x y]", l)
+		}
+		if err := p.Err(); err != nil {
+			t.Errorf("Err() = %v, want nil", err)
+		}
+	})
+
+	t.Run("empty list", func(t *testing.T) {
+		noDefaultKey := Key{Name: "test-list-no-default", Type: TypeList}
+		p := NewParser(map[string]string{listKey.FullName(): "", noDefaultKey.FullName(): " "})
+
+		if l := p.List(listKey); !reflect.DeepEqual(l, []string{"x", "y"}) {
+			t.Errorf("List() = %q, want the default [x y]", l)
+		}
+		if l := p.List(noDefaultKey); l != nil {
+			t.Errorf("List() without a default = %q, want nil", l)
+		}
+		if _, set := p.Origin(noDefaultKey); !set {
+			t.Error("Origin() of an empty annotation reports it unset")
+		}
+		if err := p.Err(); err != nil {
+			t.Errorf("Err() = %v, want nil", err)
+		}
+	})
+
+	t.Run("invalid values fall back and report one error per key", func(t *testing.T) {
+		p := NewParser(map[string]string{
+			boolKey.FullName():     "maybe",
+			intKey.FullName():      "three",
+			durationKey.FullName(): "5 minutes",
+			quantityKey.FullName(): "lots",
+			enumKey.FullName():     "ftp",
+			listKey.FullName():     "a,,b",
+			decodeKey.FullName():   `{"unknown": true}`,
+		})
+
+		if b, set := p.Bool(boolKey); !b || set {
+			t.Errorf("Bool() = %v, %v, want the default true, false", b, set)
+		}
+		p.Bool(boolKey)
+		if i := p.Int(intKey, 1); i != 1 {
+			t.Errorf("Int() = %d, want the fallback 1", i)
+		}
+		if d := p.Duration(durationKey, time.Second); d != time.Second {
+			t.Errorf("Duration() = %s, want the fallback 1s", d)
+		}
+		p.Quantity(quantityKey, resource.Quantity{})
+		if s := p.String(enumKey, "https"); s != "https" {
+			t.Errorf("String() = %q, want the fallback https", s)
+		}
+		if l := p.List(listKey); !reflect.DeepEqual(l, []string{"x", "y"}) {
+			t.Errorf("List() = %q, want the default [x y]", l)
+		}
+		var decoded struct {
+			Name string `json:"name"`
+		}
+		if p.Decode(decodeKey, &decoded) {
+			t.Error("Decode() of an unknown field = true, want false")
+		}
+
+		var errs Errors
+		if !errors.As(p.Err(), &errs) {
+			t.Fatalf("Err() = %v, want Errors", p.Err())
+		}
+		keys := map[string]bool{}
+		for _, err := range errs {
+			if keys[err.Key] {
+				t.Errorf("key %s reported twice", err.Key)
+			}
+			keys[err.Key] = true
+		}
+		if len(errs) != 7 {
+			t.Errorf("Err() has %d errors, want 7: %v", len(errs), errs)
+		}
+	})
+}
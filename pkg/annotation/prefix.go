@@ -0,0 +1,47 @@
+package annotation
+
+import (
+	"sort"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+)
+
+// Deprecated returns the keys in annotations that use a deprecated prefix,
+// sorted.
+func Deprecated(annotations map[string]string) []string {
+	cfg := config.Current()
+
+	var keys []string
+	for key := range annotations {
+		if _, prefix, ok := cfg.SplitAnnotationKey(key); ok && prefix.Deprecated {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RewriteDeprecated returns a copy of annotations with every key under a
+// deprecated prefix moved to the canonical prefix, keeping the value of the
+// prefix with the highest precedence. It also returns the keys that were moved.
+func RewriteDeprecated(annotations map[string]string) (map[string]string, []string) {
+	deprecated := Deprecated(annotations)
+	if len(deprecated) == 0 {
+		return annotations, nil
+	}
+
+	cfg := config.Current()
+	rewritten := make(map[string]string, len(annotations))
+	for key, value := range annotations {
+		rewritten[key] = value
+	}
+	for _, key := range deprecated {
+		name, _, _ := cfg.SplitAnnotationKey(key)
+		canonical := cfg.AnnotationKey(name)
+		// Value picks the prefix with the highest precedence, which is the
+		// canonical one if it is set.
+		rewritten[canonical], _ = Key{Name: name}.Value(annotations)
+		delete(rewritten, key)
+	}
+	return rewritten, deprecated
+}
@@ -0,0 +1,152 @@
+package annotation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+)
+
+// setPrefixes accepts team.example.com/ besides the canonical prefix, ahead of
+// the deprecated legacy prefix.
+func setPrefixes(t *testing.T) {
+	cfg := config.Default()
+	cfg.AnnotationPrefixes = []config.AnnotationPrefix{
+		{Prefix: "team.example.com/"},
+		{Prefix: config.LegacyAnnotationPrefix, Deprecated: true},
+	}
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+}
+
+func TestKeyFindPrecedence(t *testing.T) {
+	setPrefixes(t)
+	key := Key{Name: "gatus-name"}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantValue   string
+		wantKey     string
+		wantOk      bool
+	}{
+		{
+			name:        "not set",
+			annotations: map[string]string{"other.example.com/gatus-name": "other"},
+		},
+		{
+			name:        "legacy prefix",
+			annotations: map[string]string{"k8s-ycl.bjw-s.dev/gatus-name": "legacy"},
+			wantValue:   "legacy",
+			wantKey:     "k8s-ycl.bjw-s.dev/gatus-name",
+			wantOk:      true,
+		},
+		{
+			name:        "additional prefix before legacy",
+			annotations: map[string]string{"k8s-ycl.bjw-s.dev/gatus-name": "legacy", "team.example.com/gatus-name": "team"},
+			wantValue:   "team",
+			wantKey:     "team.example.com/gatus-name",
+			wantOk:      true,
+		},
+		{
+			name: "canonical prefix first",
+			annotations: map[string]string{
+				"k8s-ycl.bjw-s.dev/gatus-name":       "legacy",
+				"team.example.com/gatus-name":        "team",
+				"policy-control.aumer.io/gatus-name": "canonical",
+			},
+			wantValue: "canonical",
+			wantKey:   "policy-control.aumer.io/gatus-name",
+			wantOk:    true,
+		},
+		{
+			name:        "empty canonical value wins",
+			annotations: map[string]string{"k8s-ycl.bjw-s.dev/gatus-name": "legacy", "policy-control.aumer.io/gatus-name": ""},
+			wantKey:     "policy-control.aumer.io/gatus-name",
+			wantOk:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, fullKey, ok := key.Find(tt.annotations)
+			if value != tt.wantValue || fullKey != tt.wantKey || ok != tt.wantOk {
+				t.Errorf("Find() = %q, %q, %v, want %q, %q, %v", value, fullKey, ok, tt.wantValue, tt.wantKey, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	setPrefixes(t)
+	annotations := map[string]string{
+		"k8s-ycl.bjw-s.dev/keep-limit":       "true",
+		"k8s-ycl.bjw-s.dev/gatus-name":       "legacy",
+		"team.example.com/gatus-group":       "team",
+		"policy-control.aumer.io/gatus-path": "/",
+		"app.kubernetes.io/name":             "web",
+	}
+
+	want := []string{"k8s-ycl.bjw-s.dev/gatus-name", "k8s-ycl.bjw-s.dev/keep-limit"}
+	if got := Deprecated(annotations); !reflect.DeepEqual(got, want) {
+		t.Errorf("Deprecated() = %q, want %q", got, want)
+	}
+}
+
+func TestRewriteDeprecated(t *testing.T) {
+	setPrefixes(t)
+
+	t.Run("nothing deprecated", func(t *testing.T) {
+		annotations := map[string]string{"team.example.com/gatus-group": "team"}
+		rewritten, moved := RewriteDeprecated(annotations)
+		if moved != nil || !reflect.DeepEqual(rewritten, annotations) {
+			t.Errorf("RewriteDeprecated() = %v, %q, want the annotations unchanged", rewritten, moved)
+		}
+	})
+
+	t.Run("moves to the canonical prefix", func(t *testing.T) {
+		annotations := map[string]string{
+			"k8s-ycl.bjw-s.dev/keep-limit":       "true",
+			"k8s-ycl.bjw-s.dev/gatus-name":       "legacy",
+			"team.example.com/gatus-group":       "team",
+			"policy-control.aumer.io/gatus-name": "canonical",
+			"app.kubernetes.io/name":             "web",
+		}
+		original := map[string]string{}
+		for key, value := range annotations {
+			original[key] = value
+		}
+
+		rewritten, moved := RewriteDeprecated(annotations)
+		want := map[string]string{
+			"policy-control.aumer.io/keep-limit": "true",
+			"policy-control.aumer.io/gatus-name": "canonical",
+			"team.example.com/gatus-group":       "team",
+			"app.kubernetes.io/name":             "web",
+		}
+		if !reflect.DeepEqual(rewritten, want) {
+			t.Errorf("RewriteDeprecated() = %v, want %v", rewritten, want)
+		}
+		if wantMoved := []string{"k8s-ycl.bjw-s.dev/gatus-name", "k8s-ycl.bjw-s.dev/keep-limit"}; !reflect.DeepEqual(moved, wantMoved) {
+			t.Errorf("moved = %q, want %q", moved, wantMoved)
+		}
+		if !reflect.DeepEqual(annotations, original) {
+			t.Errorf("RewriteDeprecated() changed its input to %v", annotations)
+		}
+	})
+
+	t.Run("additional prefix is kept over the legacy one", func(t *testing.T) {
+		rewritten, _ := RewriteDeprecated(map[string]string{
+			"k8s-ycl.bjw-s.dev/gatus-group": "legacy",
+			"team.example.com/gatus-group":  "team",
+		})
+		// The team.example.com/ annotation isn't deprecated, so it stays too.
+		want := map[string]string{
+			"policy-control.aumer.io/gatus-group": "team",
+			"team.example.com/gatus-group":        "team",
+		}
+		if !reflect.DeepEqual(rewritten, want) {
+			t.Errorf("RewriteDeprecated() = %v, want %v", rewritten, want)
+		}
+	})
+}
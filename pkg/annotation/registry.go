@@ -0,0 +1,254 @@
+package annotation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Type is the kind of value an annotation holds.
+type Type string
+
+const (
+	TypeString Type = "string"
+	// TypeBool accepts true/false, yes/no and 1/0.
+	TypeBool Type = "bool"
+	// TypeList is a comma separated list, see SplitList.
+	TypeList Type = "list"
+	// TypeEnum only accepts one of the allowed values.
+	TypeEnum     Type = "enum"
+	TypeInt      Type = "int"
+	TypeDuration Type = "duration"
+	// TypeQuantity is a resource quantity such as 100m or 1Gi.
+	TypeQuantity Type = "quantity"
+	// TypeFloat is a decimal number such as 1.5.
+	TypeFloat Type = "float"
+	// TypeJSON is a JSON or YAML value.
+	TypeJSON Type = "json"
+)
+
+// FrameworkOwner owns the annotations the controller itself reads or writes,
+// as opposed to a single policy.
+const FrameworkOwner = "framework"
+
+// Key describes an annotation, without the annotation prefix. It is the single
+// source of truth for parsing the annotation as well as for documenting it.
+type Key struct {
+	Name string `json:"name"`
+	// Key is the full annotation key, only filled in by Explain.
+	Key     string   `json:"key,omitempty"`
+	Type    Type     `json:"type"`
+	Default string   `json:"default,omitempty"`
+	Allowed []string `json:"allowed,omitempty"`
+	// Inherit lets the value come from the controlling owner, the Namespace or
+	// the cluster defaults when the object doesn't set it.
+	Inherit bool `json:"inherit,omitempty"`
+	// Description is shown by explain. Defaults that depend on the object or the
+	// config can't be put in Default and are described here instead.
+	Description string `json:"description"`
+}
+
+// Schema is the set of annotations one owner, usually a policy, understands.
+type Schema struct {
+	Owner string `json:"owner"`
+	Keys  []Key  `json:"keys"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Key{}
+	owners     = map[string][]string{}
+)
+
+// Register adds keys to the schema of owner. A key can be shared by several
+// owners, registering the same name again replaces its definition.
+func Register(owner string, keys ...Key) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, key := range keys {
+		if !contains(owners[owner], key.Name) {
+			owners[owner] = append(owners[owner], key.Name)
+		}
+		registry[key.Name] = key
+	}
+}
+
+func Lookup(name string) (Key, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	key, ok := registry[name]
+	return key, ok
+}
+
+// Known returns every registered key sorted by name.
+func Known() []Key {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	keys := make([]Key, 0, len(registry))
+	for _, key := range registry {
+		keys = append(keys, key)
+	}
+	sortKeys(keys)
+	return keys
+}
+
+// Schemas returns the schema of every owner sorted by owner, with keys sorted
+// by name.
+func Schemas() []Schema {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	schemas := make([]Schema, 0, len(owners))
+	for owner, names := range owners {
+		schema := Schema{Owner: owner}
+		for _, name := range names {
+			schema.Keys = append(schema.Keys, registry[name])
+		}
+		sortKeys(schema.Keys)
+		schemas = append(schemas, schema)
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Owner < schemas[j].Owner
+	})
+	return schemas
+}
+
+// Explain returns the schemas of owner, or of every owner if owner is empty, with
+// the full annotation keys filled in.
+func Explain(owner string) []Schema {
+	var schemas []Schema
+	for _, schema := range Schemas() {
+		if owner != "" && schema.Owner != owner {
+			continue
+		}
+		for i := range schema.Keys {
+			schema.Keys[i].Key = schema.Keys[i].FullName()
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas
+}
+
+// FullName returns the annotation key under the canonical prefix.
+func (k Key) FullName() string {
+	return config.Current().AnnotationKey(k.Name)
+}
+
+// Value returns the raw annotation value and whether it is set.
+func (k Key) Value(annotations map[string]string) (string, bool) {
+	val, _, ok := k.Find(annotations)
+	return val, ok
+}
+
+// Find returns the value and full key of the annotation under the accepted
+// prefix with the highest precedence.
+func (k Key) Find(annotations map[string]string) (value string, fullKey string, ok bool) {
+	for _, prefix := range config.Current().Prefixes() {
+		fullKey := prefix.Prefix + k.Name
+		if val, ok := annotations[fullKey]; ok {
+			return val, fullKey, true
+		}
+	}
+	return "", "", false
+}
+
+// String returns the annotation value, or the default if it isn't set. Use a
+// Parser for anything but plain strings.
+func (k Key) String(annotations map[string]string) string {
+	return k.StringOr(annotations, k.Default)
+}
+
+// StringOr returns the annotation value, or fallback if it isn't set. Use it for
+// defaults that depend on the object or config.
+func (k Key) StringOr(annotations map[string]string, fallback string) string {
+	if val, ok := k.Value(annotations); ok {
+		return val
+	}
+	return fallback
+}
+
+// Check returns an error if value doesn't parse as the key's type.
+func (k Key) Check(value string) error {
+	p := NewParser(map[string]string{k.FullName(): value})
+	switch k.Type {
+	case TypeBool:
+		p.Bool(k)
+	case TypeList:
+		p.List(k)
+	case TypeEnum:
+		for _, allowed := range k.Allowed {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected one of %s, got %q", strings.Join(k.Allowed, ", "), value)
+	case TypeInt:
+		p.Int(k, 0)
+	case TypeDuration:
+		p.Duration(k, 0)
+	case TypeQuantity:
+		p.Quantity(k, resource.Quantity{})
+	case TypeFloat:
+		p.Float(k, 0)
+	case TypeJSON:
+		var out interface{}
+		p.Decode(k, &out)
+	}
+	if len(p.errs) > 0 {
+		return p.errs[0].Err
+	}
+	return nil
+}
+
+// validateDefaults checks the cluster wide annotation defaults against the
+// registered keys.
+func validateDefaults(cfg *config.Config) error {
+	names := make([]string, 0, len(cfg.AnnotationDefaults))
+	for name := range cfg.AnnotationDefaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		value := cfg.AnnotationDefaults[name]
+		key, ok := Lookup(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("annotationDefaults.%s: unknown annotation", name))
+			continue
+		}
+		if !key.Inherit {
+			errs = append(errs, fmt.Errorf("annotationDefaults.%s: annotation can't be inherited", name))
+			continue
+		}
+		if err := key.Check(value); err != nil {
+			errs = append(errs, fmt.Errorf("annotationDefaults.%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func init() {
+	config.AddValidator(validateDefaults)
+}
+
+func sortKeys(keys []Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	OutcomeApplied = "applied"
+	OutcomeSkipped = "skipped"
+	OutcomeAudited = "audited"
+	OutcomeDenied  = "denied"
+	OutcomeError   = "error"
+)
+
+var (
+	auditLog = ctrl.Log.WithName("audit")
+	sink     io.Writer
+	sinkMu   sync.Mutex
+)
+
+// Entry is a single policy decision, written as one JSON line.
+type Entry struct {
+	Timestamp  time.Time                  `json:"timestamp"`
+	RequestUID string                     `json:"requestUID,omitempty"`
+	UserInfo   *authenticationv1.UserInfo `json:"userInfo,omitempty"`
+	Object     ObjectRef                  `json:"object"`
+	Policy     string                     `json:"policy"`
+	Mode       string                     `json:"mode"`
+	Outcome    string                     `json:"outcome"`
+	Message    string                     `json:"message,omitempty"`
+	Patch      json.RawMessage            `json:"patch,omitempty"`
+	Generated  []GeneratedObject          `json:"generated,omitempty"`
+}
+
+type ObjectRef struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+}
+
+// SetSink directs all following entries to w. A nil writer disables the audit
+// log.
+func SetSink(w io.Writer) {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	sink = w
+}
+
+// Open returns the sink for the given destination: "-" or "stdout" for stdout,
+// anything else is a file rotated once it grows beyond maxSize bytes.
+func Open(destination string, maxSize int64, maxBackups int) (io.Writer, error) {
+	if destination == "-" || destination == "stdout" {
+		return os.Stdout, nil
+	}
+	return NewRotatingFile(destination, maxSize, maxBackups)
+}
+
+func Enabled() bool {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	return sink != nil
+}
+
+// Record writes the entry to the sink, stamping the time if it's unset.
+func Record(entry Entry) {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+
+	if sink == nil {
+		return
+	}
+
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		auditLog.Error(err, "unable to marshal audit entry", "policy", entry.Policy)
+		return
+	}
+
+	if _, err := sink.Write(append(line, '\n')); err != nil {
+		auditLog.Error(err, "unable to write audit entry", "policy", entry.Policy)
+	}
+}
@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// GeneratedObject is an object a policy wrote while making a decision, with a
+// digest of its content.
+type GeneratedObject struct {
+	Action string    `json:"action"`
+	Object ObjectRef `json:"object"`
+	Digest string    `json:"digest,omitempty"`
+}
+
+// RecordingClient passes every call through to the wrapped client and remembers
+// the objects that were successfully created, updated, patched or deleted.
+type RecordingClient struct {
+	client.Client
+
+	mu        sync.Mutex
+	generated []GeneratedObject
+}
+
+func NewRecordingClient(c client.Client) *RecordingClient {
+	return &RecordingClient{Client: c}
+}
+
+func (c *RecordingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.Client.Create(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.record("create", obj)
+	return nil
+}
+
+func (c *RecordingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.Client.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.record("update", obj)
+	return nil
+}
+
+func (c *RecordingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.Client.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	c.record("patch", obj)
+	return nil
+}
+
+func (c *RecordingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.Client.Delete(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.record("delete", obj)
+	return nil
+}
+
+// Generated returns the recorded writes and forgets them.
+func (c *RecordingClient) Generated() []GeneratedObject {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	generated := c.generated
+	c.generated = nil
+	return generated
+}
+
+func (c *RecordingClient) record(action string, obj client.Object) {
+	generated := GeneratedObject{
+		Action: action,
+		Object: NewObjectRef(obj, c.Scheme()),
+	}
+	if action != "delete" {
+		generated.Digest = Digest(obj)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generated = append(c.generated, generated)
+}
+
+// NewObjectRef describes obj, resolving its kind through the scheme if the
+// object has no TypeMeta.
+func NewObjectRef(obj runtime.Object, scheme *runtime.Scheme) ObjectRef {
+	ref := ObjectRef{}
+
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() && scheme != nil {
+		gvk, _ = apiutil.GVKForObject(obj, scheme)
+	}
+	ref.APIVersion, ref.Kind = gvk.ToAPIVersionAndKind()
+
+	if accessor, err := meta.Accessor(obj); err == nil {
+		ref.Namespace = accessor.GetNamespace()
+		ref.Name = accessor.GetName()
+		ref.UID = string(accessor.GetUID())
+	}
+	return ref
+}
+
+// Digest returns the sha256 of the object content, leaving out status and the
+// metadata the API server fills in, so that the same generated object always has
+// the same digest.
+func Digest(obj runtime.Object) string {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		auditLog.Error(err, "unable to convert object for digest")
+		return ""
+	}
+
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		for _, field := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "managedFields"} {
+			delete(metadata, field)
+		}
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		auditLog.Error(err, "unable to marshal object for digest")
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
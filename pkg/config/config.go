@@ -0,0 +1,302 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	DefaultAnnotationPrefix = "policy-control.aumer.io/"
+	// LegacyAnnotationPrefix is the prefix of the upstream k8s-ycl project.
+	LegacyAnnotationPrefix = "k8s-ycl.bjw-s.dev/"
+
+	// ModeEnforce applies policy decisions.
+	ModeEnforce = "enforce"
+	// ModeAudit only records what a policy would have done.
+	ModeAudit = "audit"
+)
+
+var (
+	configLog  = ctrl.Log.WithName("config")
+	current    atomic.Pointer[Config]
+	loaded     atomic.Bool
+	validators []func(*Config) error
+	validateMu sync.Mutex
+)
+
+// Config holds the global defaults and per-policy settings. Anything left out of
+// the file keeps its default.
+type Config struct {
+	// AnnotationPrefix is the canonical prefix. It takes precedence over
+	// AnnotationPrefixes and is the one the controller writes.
+	AnnotationPrefix string `yaml:"annotationPrefix"`
+	// AnnotationPrefixes are also accepted, in order of precedence.
+	AnnotationPrefixes []AnnotationPrefix `yaml:"annotationPrefixes"`
+	// RewriteDeprecatedPrefixes moves annotations under a deprecated prefix to
+	// the canonical prefix at admission.
+	RewriteDeprecatedPrefixes bool `yaml:"rewriteDeprecatedPrefixes"`
+
+	Labels          Labels                  `yaml:"labels"`
+	OutputNamespace string                  `yaml:"outputNamespace"`
+	Policies        map[string]PolicyConfig `yaml:"policies"`
+	Lint            LintConfig              `yaml:"lint"`
+	// AnnotationDefaults are cluster wide values for inheritable annotations,
+	// keyed by annotation name without the prefix, e.g. "gatus-generate".
+	AnnotationDefaults map[string]string `yaml:"annotationDefaults"`
+}
+
+// AnnotationPrefix is an accepted annotation prefix besides the canonical one.
+type AnnotationPrefix struct {
+	Prefix string `yaml:"prefix"`
+	// Deprecated prefixes still work, but objects using them get a warning.
+	Deprecated bool `yaml:"deprecated"`
+}
+
+// LintConfig controls the annotation lint webhook.
+type LintConfig struct {
+	// Deny rejects objects with unknown or invalid policy annotations instead of
+	// only warning about them.
+	Deny bool `yaml:"deny"`
+}
+
+// Labels are the label keys put on objects generated by policies.
+type Labels struct {
+	ManagedBy      string `yaml:"managedBy"`
+	ManagedByValue string `yaml:"managedByValue"`
+	ParentUid      string `yaml:"parentUid"`
+}
+
+// PolicyConfig holds the settings of a single policy, keyed by policy.Key.
+type PolicyConfig struct {
+	Enabled         *bool             `yaml:"enabled"`
+	Mode            string            `yaml:"mode"`
+	OutputNamespace string            `yaml:"outputNamespace"`
+	Settings        map[string]string `yaml:"settings"`
+}
+
+func Default() *Config {
+	return &Config{
+		AnnotationPrefix: DefaultAnnotationPrefix,
+		AnnotationPrefixes: []AnnotationPrefix{
+			{Prefix: LegacyAnnotationPrefix, Deprecated: true},
+		},
+		Labels: Labels{
+			ManagedBy:      "app.kubernetes.io/managed-by",
+			ManagedByValue: "policy-control.aumer.io",
+			ParentUid:      DefaultAnnotationPrefix + "parent-uid",
+		},
+		Policies: map[string]PolicyConfig{},
+	}
+}
+
+// Current returns the last good config, or the defaults if none was loaded.
+func Current() *Config {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+	return Default()
+}
+
+// Loaded reports whether a config has been accepted. Running without a config
+// file counts as loaded once Set was called with the defaults.
+func Loaded() bool {
+	return loaded.Load()
+}
+
+func Set(cfg *Config) {
+	current.Store(cfg)
+	loaded.Store(true)
+}
+
+// AddValidator registers an extra check run against every config before it is
+// accepted, for settings the config package doesn't know about.
+func AddValidator(validator func(*Config) error) {
+	validateMu.Lock()
+	defer validateMu.Unlock()
+	validators = append(validators, validator)
+}
+
+// Load reads, validates and activates the config at path. On error the current
+// config is left in place.
+func Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	Set(cfg)
+	configLog.Info("loaded config", "path", path)
+	return nil
+}
+
+func Parse(data []byte) (*Config, error) {
+	cfg := Default()
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if cfg.Policies == nil {
+		cfg.Policies = map[string]PolicyConfig{}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	if err := validatePrefix("annotationPrefix", c.AnnotationPrefix); err != nil {
+		errs = append(errs, err)
+	}
+	seen := map[string]bool{c.AnnotationPrefix: true}
+	for i, prefix := range c.AnnotationPrefixes {
+		field := fmt.Sprintf("annotationPrefixes[%d]", i)
+		if err := validatePrefix(field, prefix.Prefix); err != nil {
+			errs = append(errs, err)
+		} else if seen[prefix.Prefix] {
+			errs = append(errs, fmt.Errorf("%s %q: duplicate prefix", field, prefix.Prefix))
+		}
+		seen[prefix.Prefix] = true
+	}
+
+	if msgs := validation.IsQualifiedName(c.Labels.ManagedBy); len(msgs) > 0 {
+		errs = append(errs, fmt.Errorf("labels.managedBy %q: %s", c.Labels.ManagedBy, strings.Join(msgs, ", ")))
+	}
+	if msgs := validation.IsQualifiedName(c.Labels.ParentUid); len(msgs) > 0 {
+		errs = append(errs, fmt.Errorf("labels.parentUid %q: %s", c.Labels.ParentUid, strings.Join(msgs, ", ")))
+	}
+	if msgs := validation.IsValidLabelValue(c.Labels.ManagedByValue); len(msgs) > 0 {
+		errs = append(errs, fmt.Errorf("labels.managedByValue %q: %s", c.Labels.ManagedByValue, strings.Join(msgs, ", ")))
+	}
+
+	if err := validateNamespace("outputNamespace", c.OutputNamespace); err != nil {
+		errs = append(errs, err)
+	}
+	for _, name := range c.PolicyKeys() {
+		if err := validateNamespace("policies."+name+".outputNamespace", c.Policies[name].OutputNamespace); err != nil {
+			errs = append(errs, err)
+		}
+		if mode := c.Policies[name].Mode; mode != "" && mode != ModeEnforce && mode != ModeAudit {
+			errs = append(errs, fmt.Errorf("policies.%s.mode %q: expected %s or %s", name, mode, ModeEnforce, ModeAudit))
+		}
+	}
+
+	validateMu.Lock()
+	defer validateMu.Unlock()
+	for _, validator := range validators {
+		if err := validator(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// PolicyKeys returns the configured policy keys in sorted order.
+func (c *Config) PolicyKeys() []string {
+	keys := make([]string, 0, len(c.Policies))
+	for key := range c.Policies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AnnotationKey returns the full annotation key for a policy annotation name,
+// e.g. "gatus-name", under the canonical prefix.
+func (c *Config) AnnotationKey(name string) string {
+	return c.AnnotationPrefix + name
+}
+
+// Prefixes returns every accepted prefix in order of precedence, the canonical
+// prefix first.
+func (c *Config) Prefixes() []AnnotationPrefix {
+	return append([]AnnotationPrefix{{Prefix: c.AnnotationPrefix}}, c.AnnotationPrefixes...)
+}
+
+// SplitAnnotationKey returns the annotation name and prefix of key, if key is
+// under any accepted prefix.
+func (c *Config) SplitAnnotationKey(key string) (name string, prefix AnnotationPrefix, ok bool) {
+	for _, prefix := range c.Prefixes() {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return strings.TrimPrefix(key, prefix.Prefix), prefix, true
+		}
+	}
+	return "", AnnotationPrefix{}, false
+}
+
+func validatePrefix(field string, prefix string) error {
+	if !strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("%s %q must end with /", field, prefix)
+	}
+	if msgs := validation.IsDNS1123Subdomain(strings.TrimSuffix(prefix, "/")); len(msgs) > 0 {
+		return fmt.Errorf("%s %q: %s", field, prefix, strings.Join(msgs, ", "))
+	}
+	return nil
+}
+
+func (c *Config) Policy(key string) PolicyConfig {
+	return c.Policies[key]
+}
+
+// PolicyOutputNamespace returns where a policy should put generated objects, or
+// the fallback if neither the policy nor the global config set one.
+func (c *Config) PolicyOutputNamespace(key string, fallback string) string {
+	if namespace := c.Policy(key).OutputNamespace; namespace != "" {
+		return namespace
+	}
+	if c.OutputNamespace != "" {
+		return c.OutputNamespace
+	}
+	return fallback
+}
+
+func (p PolicyConfig) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+func (p PolicyConfig) PolicyMode() string {
+	if p.Mode == "" {
+		return ModeEnforce
+	}
+	return p.Mode
+}
+
+func (p PolicyConfig) Setting(key string, defaultValue string) string {
+	if val, ok := p.Settings[key]; ok && val != "" {
+		return val
+	}
+	return defaultValue
+}
+
+func validateNamespace(field string, namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	if msgs := validation.IsDNS1123Label(namespace); len(msgs) > 0 {
+		return fmt.Errorf("%s %q: %s", field, namespace, strings.Join(msgs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr []string
+		check   func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "empty file keeps the defaults",
+			check: func(t *testing.T, cfg *Config) {
+				if !reflect.DeepEqual(cfg, Default()) {
+					t.Errorf("Parse() = %+v, want the defaults", cfg)
+				}
+			},
+		},
+		{
+			name: "policies and prefixes",
+			data: `
+annotationPrefix: example.com/
+annotationPrefixes:
+- prefix: team.example.com/
+- prefix: k8s-ycl.bjw-s.dev/
+  deprecated: true
+outputNamespace: monitoring
+policies:
+  ingress-generate-gatus:
+    mode: audit
+    settings:
+      interval: 5m
+`,
+			check: func(t *testing.T, cfg *Config) {
+				wantPrefixes := []AnnotationPrefix{{Prefix: "example.com/"}, {Prefix: "team.example.com/"}, {Prefix: LegacyAnnotationPrefix, Deprecated: true}}
+				if got := cfg.Prefixes(); !reflect.DeepEqual(got, wantPrefixes) {
+					t.Errorf("Prefixes() = %v, want %v", got, wantPrefixes)
+				}
+				policy := cfg.Policy("ingress-generate-gatus")
+				if policy.PolicyMode() != ModeAudit || policy.Setting("interval", "1m") != "5m" || !policy.IsEnabled() {
+					t.Errorf("policy = %+v, want enabled in audit mode with interval 5m", policy)
+				}
+				if cfg.Labels != Default().Labels {
+					t.Errorf("labels = %+v, want the defaults", cfg.Labels)
+				}
+			},
+		},
+		{
+			name: "policies section without entries",
+			data: "policies:\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Policies == nil {
+					t.Error("Policies = nil, want an empty map")
+				}
+			},
+		},
+		{
+			name:    "unknown field",
+			data:    "annotationPrefx: example.com/\n",
+			wantErr: []string{"field annotationPrefx not found"},
+		},
+		{
+			name:    "prefix without slash",
+			data:    "annotationPrefix: example.com\n",
+			wantErr: []string{`annotationPrefix "example.com" must end with /`},
+		},
+		{
+			name:    "duplicate prefix",
+			data:    "annotationPrefixes:\n- prefix: policy-control.aumer.io/\n",
+			wantErr: []string{`annotationPrefixes[0] "policy-control.aumer.io/": duplicate prefix`},
+		},
+		{
+			name:    "invalid mode",
+			data:    "policies:\n  pod-inject:\n    mode: dry-run\n",
+			wantErr: []string{`policies.pod-inject.mode "dry-run": expected enforce or audit`},
+		},
+		{
+			name:    "every error is reported",
+			data:    "outputNamespace: Monitoring\nlabels:\n  managedBy: -managed\n",
+			wantErr: []string{`outputNamespace "Monitoring"`, `labels.managedBy "-managed"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tt.data))
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("Parse() error = nil, want %q", tt.wantErr)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Parse() error = %v, want it to contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadKeepsLastGoodConfig(t *testing.T) {
+	t.Cleanup(func() { Set(Default()) })
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	if err := os.WriteFile(path, []byte("outputNamespace: monitoring\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !Loaded() || Current().OutputNamespace != "monitoring" {
+		t.Fatalf("Current().OutputNamespace = %q, want monitoring", Current().OutputNamespace)
+	}
+
+	if err := os.WriteFile(path, []byte("outputNamespace: Monitoring\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Load() error = %v, want it to name %s", err, path)
+	}
+	if Current().OutputNamespace != "monitoring" {
+		t.Errorf("Current().OutputNamespace = %q, want the last good monitoring", Current().OutputNamespace)
+	}
+}
+
+func TestPolicyOutputNamespace(t *testing.T) {
+	cfg := Default()
+	cfg.OutputNamespace = "monitoring"
+	cfg.Policies["service-generate-gatus"] = PolicyConfig{OutputNamespace: "gatus"}
+
+	if got := cfg.PolicyOutputNamespace("service-generate-gatus", "default"); got != "gatus" {
+		t.Errorf("policy output namespace = %q, want gatus", got)
+	}
+	if got := cfg.PolicyOutputNamespace("ingress-generate-gatus", "default"); got != "monitoring" {
+		t.Errorf("global output namespace = %q, want monitoring", got)
+	}
+	cfg.OutputNamespace = ""
+	if got := cfg.PolicyOutputNamespace("ingress-generate-gatus", "default"); got != "default" {
+		t.Errorf("fallback output namespace = %q, want default", got)
+	}
+}
+
+func TestSplitAnnotationKey(t *testing.T) {
+	cfg := Default()
+	cfg.AnnotationPrefixes = append([]AnnotationPrefix{{Prefix: "team.example.com/"}}, cfg.AnnotationPrefixes...)
+
+	tests := []struct {
+		key        string
+		wantName   string
+		wantPrefix AnnotationPrefix
+		wantOk     bool
+	}{
+		{key: "policy-control.aumer.io/keep-limit", wantName: "keep-limit", wantPrefix: AnnotationPrefix{Prefix: DefaultAnnotationPrefix}, wantOk: true},
+		{key: "team.example.com/keep-limit", wantName: "keep-limit", wantPrefix: AnnotationPrefix{Prefix: "team.example.com/"}, wantOk: true},
+		{key: "k8s-ycl.bjw-s.dev/keep-limit", wantName: "keep-limit", wantPrefix: AnnotationPrefix{Prefix: LegacyAnnotationPrefix, Deprecated: true}, wantOk: true},
+		{key: "app.kubernetes.io/name"},
+	}
+
+	for _, tt := range tests {
+		name, prefix, ok := cfg.SplitAnnotationKey(tt.key)
+		if name != tt.wantName || prefix != tt.wantPrefix || ok != tt.wantOk {
+			t.Errorf("SplitAnnotationKey(%q) = %q, %+v, %v, want %q, %+v, %v", tt.key, name, prefix, ok, tt.wantName, tt.wantPrefix, tt.wantOk)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch reloads the config at path whenever it changes, until ctx is done. The
+// directory is watched rather than the file so that ConfigMap mounts, which swap
+// a symlink, are picked up too. Invalid configs are logged and skipped.
+func Watch(ctx context.Context, path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	path = filepath.Clean(path)
+	dir := filepath.Dir(path)
+	if err := watcher.Add(dir); err != nil {
+		return err
+	}
+
+	configLog.Info("watching config for changes", "path", path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !isConfigEvent(event, path) {
+				continue
+			}
+			if err := Load(path); err != nil {
+				configLog.Error(err, "keeping last good config")
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			configLog.Error(err, "error watching config")
+		}
+	}
+}
+
+func isConfigEvent(event fsnotify.Event, path string) bool {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+		return false
+	}
+
+	name := filepath.Clean(event.Name)
+	return name == path || filepath.Base(name) == "..data"
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestIsConfigEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		event fsnotify.Event
+		want  bool
+	}{
+		{name: "write", event: fsnotify.Event{Name: "/etc/policy/config.yaml", Op: fsnotify.Write}, want: true},
+		{name: "create", event: fsnotify.Event{Name: "/etc/policy/config.yaml", Op: fsnotify.Create}, want: true},
+		{name: "ConfigMap symlink swap", event: fsnotify.Event{Name: "/etc/policy/..data", Op: fsnotify.Create}, want: true},
+		{name: "chmod", event: fsnotify.Event{Name: "/etc/policy/config.yaml", Op: fsnotify.Chmod}},
+		{name: "remove", event: fsnotify.Event{Name: "/etc/policy/config.yaml", Op: fsnotify.Remove}},
+		{name: "other file", event: fsnotify.Event{Name: "/etc/policy/other.yaml", Op: fsnotify.Write}},
+	}
+
+	for _, tt := range tests {
+		if got := isConfigEvent(tt.event, "/etc/policy/config.yaml"); got != tt.want {
+			t.Errorf("%s: isConfigEvent() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// watch starts Watch on path and stops it when the test ends.
+func watch(t *testing.T, path string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Watch(ctx, path) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Watch() error = %v", err)
+		}
+		Set(Default())
+	})
+}
+
+// eventually rewrites the config with write until Current reports namespace as
+// the output namespace, so that events sent before the watcher was added don't
+// make the test flaky.
+func eventually(t *testing.T, namespace string, write func()) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		write()
+		time.Sleep(50 * time.Millisecond)
+		if Current().OutputNamespace == namespace {
+			return
+		}
+	}
+	t.Fatalf("Current().OutputNamespace = %q, want %q", Current().OutputNamespace, namespace)
+}
+
+func writeFile(t *testing.T, path string, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchReloadsConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "outputNamespace: monitoring\n")
+	if err := Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	watch(t, path)
+
+	eventually(t, "gatus", func() { writeFile(t, path, "outputNamespace: gatus\n") })
+
+	writeFile(t, path, "outputNamespace: Invalid\n")
+	time.Sleep(200 * time.Millisecond)
+	if Current().OutputNamespace != "gatus" {
+		t.Errorf("Current().OutputNamespace = %q after an invalid config, want the last good gatus", Current().OutputNamespace)
+	}
+}
+
+func TestWatchReloadsConfigMapMount(t *testing.T) {
+	// A ConfigMap mount points config.yaml at ..data/config.yaml and swaps the
+	// ..data symlink to a new timestamped directory on every update.
+	dir := t.TempDir()
+	revision := 0
+	update := func(data string) {
+		revision++
+		target := filepath.Join(dir, fmt.Sprintf("..rev%d", revision))
+		if err := os.Mkdir(target, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(target, "config.yaml"), data)
+		tmp := filepath.Join(dir, "..data_tmp")
+		if err := os.Symlink(filepath.Base(target), tmp); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	update("outputNamespace: monitoring\n")
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.Symlink(filepath.Join("..data", "config.yaml"), path); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	watch(t, path)
+
+	eventually(t, "gatus", func() { update("outputNamespace: gatus\n") })
+}
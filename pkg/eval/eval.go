@@ -0,0 +1,161 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/manifest"
+	"github.com/aumer-amr/k8s-policy-control/pkg/memory"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"gomodules.xyz/jsonpatch/v2"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Result is what running the policies against a single object produced.
+type Result struct {
+	Source    string
+	Object    client.Object
+	Mutated   client.Object
+	Patch     []jsonpatch.JsonPatchOperation
+	Generated []client.Object
+	Decisions []audit.Entry
+}
+
+// Denied reports whether any policy rejected the object.
+func (r Result) Denied() bool {
+	return policy.Denied(r.Decisions)
+}
+
+// Run evaluates the policies against every object a policy type exists for.
+// All other objects, such as ConfigMaps or Secrets, are only loaded as context
+// that policies can read. Kinds the scheme doesn't know are ignored.
+func Run(scheme *runtime.Scheme, objects []manifest.Object) ([]Result, error) {
+	return RunWithContext(scheme, nil, objects)
+}
+
+// RunWithContext is Run with extra objects that are only loaded into the store,
+// never evaluated themselves.
+func RunWithContext(scheme *runtime.Scheme, contextObjects []manifest.Object, objects []manifest.Object) ([]Result, error) {
+	var typed []client.Object
+	for _, obj := range append(append([]manifest.Object{}, contextObjects...), objects...) {
+		if obj.Typed {
+			typed = append(typed, obj.DeepCopyObject().(client.Object))
+		}
+	}
+
+	store, err := memory.NewClient(scheme, memory.NewRESTMapper(scheme), typed...)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, obj := range objects {
+		if !obj.Typed {
+			continue
+		}
+
+		policyType := policy.TypeForObject(obj.Object)
+		if policyType == policy.PolicyTypeUnknown {
+			continue
+		}
+
+		result, err := runObject(store, policyType, obj)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func runObject(store *memory.Client, policyType int, obj manifest.Object) (Result, error) {
+	result, err := Apply(policy.NewEnv(store), store, obj.Object, func(mutated client.Object, env *policy.Env) ([]audit.Entry, error) {
+		return policy.ApplyPoliciesByType(policyType, mutated, env)
+	})
+	result.Source = obj.Source
+	return result, err
+}
+
+// Apply calls apply with the stored copy of obj and collects the patch and the
+// objects written to the store. The env has to read from and write to store.
+func Apply(env *policy.Env, store *memory.Client, obj client.Object, apply func(client.Object, *policy.Env) ([]audit.Entry, error)) (Result, error) {
+	ctx := context.Background()
+
+	// Run against the stored copy so the object carries the same UID as what
+	// policies read back from the store.
+	mutated := obj.DeepCopyObject().(client.Object)
+	if err := store.Get(ctx, client.ObjectKeyFromObject(mutated), mutated); err != nil {
+		return Result{}, err
+	}
+	original := mutated.DeepCopyObject().(client.Object)
+
+	result := Result{
+		Object:  original,
+		Mutated: mutated,
+	}
+
+	decisions, err := apply(mutated, env)
+	result.Decisions = decisions
+	if err != nil {
+		return result, err
+	}
+
+	result.Patch, err = createPatch(original, mutated)
+	if err != nil {
+		return result, err
+	}
+	if len(result.Patch) > 0 {
+		if err := store.Update(ctx, mutated.DeepCopyObject().(client.Object)); err != nil {
+			return result, err
+		}
+	}
+
+	result.Generated, err = generatedObjects(store, decisions)
+	return result, err
+}
+
+func createPatch(original client.Object, mutated client.Object) ([]jsonpatch.JsonPatchOperation, error) {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+	mutatedJSON, err := json.Marshal(mutated)
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.CreatePatch(originalJSON, mutatedJSON)
+}
+
+// generatedObjects reads back every object the decisions report as written and
+// still present in the store.
+func generatedObjects(store *memory.Client, decisions []audit.Entry) ([]client.Object, error) {
+	var generated []client.Object
+	seen := map[audit.ObjectRef]bool{}
+
+	for _, decision := range decisions {
+		for _, written := range decision.Generated {
+			ref := written.Object
+			ref.UID = ""
+			if written.Action == "delete" || seen[ref] {
+				continue
+			}
+			seen[ref] = true
+
+			gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+			obj, err := store.Scheme().New(gvk)
+			if err != nil {
+				return nil, err
+			}
+			clientObj := obj.(client.Object)
+			if err := store.Get(context.Background(), client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, clientObj); err != nil {
+				continue
+			}
+			clientObj.GetObjectKind().SetGroupVersionKind(gvk)
+			generated = append(generated, clientObj)
+		}
+	}
+	return generated, nil
+}
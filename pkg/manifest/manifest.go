@@ -0,0 +1,119 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Object is a decoded manifest together with where it was read from.
+type Object struct {
+	client.Object
+	Source string
+	// Typed is false for kinds the scheme doesn't know, which are decoded as
+	// unstructured.
+	Typed bool
+}
+
+// ReadFile decodes every object in a YAML or JSON file, "-" reads stdin.
+func ReadFile(path string, scheme *runtime.Scheme) ([]Object, error) {
+	if path == "-" {
+		return Decode(os.Stdin, "<stdin>", scheme)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Decode(file, path, scheme)
+}
+
+// Decode reads a stream of YAML documents or JSON objects. Lists are flattened
+// into their items and empty documents are skipped.
+func Decode(r io.Reader, source string, scheme *runtime.Scheme) ([]Object, error) {
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+
+	var objects []Object
+	for document := 1; ; document++ {
+		data, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+
+		data, err = utilyaml.ToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %w", source, document, err)
+		}
+		if len(bytes.TrimSpace(data)) == 0 || bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+			continue
+		}
+
+		decoded, err := decodeDocument(data, decoder, scheme)
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %w", source, document, err)
+		}
+		for _, obj := range decoded {
+			obj.Source = source
+			objects = append(objects, obj)
+		}
+	}
+}
+
+func decodeDocument(data []byte, decoder runtime.Decoder, scheme *runtime.Scheme) ([]Object, error) {
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+
+	if u.IsList() {
+		list, err := u.ToList()
+		if err != nil {
+			return nil, err
+		}
+
+		var objects []Object
+		for _, item := range list.Items {
+			itemData, err := item.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			decoded, err := decodeDocument(itemData, decoder, scheme)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, decoded...)
+		}
+		return objects, nil
+	}
+
+	gvk := u.GroupVersionKind()
+	if !scheme.Recognizes(gvk) {
+		return []Object{{Object: u}}, nil
+	}
+
+	obj, _, err := decoder.Decode(data, &gvk, nil)
+	if err != nil {
+		return nil, err
+	}
+	typed, ok := obj.(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an object", gvk.Kind)
+	}
+	typed.GetObjectKind().SetGroupVersionKind(gvk)
+	return []Object{{Object: typed, Typed: true}}, nil
+}
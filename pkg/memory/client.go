@@ -0,0 +1,468 @@
+package memory
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+type objectKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// Client is a client.Client backed by an in-memory object store, for running
+// policies without an API server. Objects are stored as typed copies, so every
+// kind used has to be registered in the scheme, and the scope of each kind is
+// read from the RESTMapper. Writes with the DryRunAll option are checked but not
+// stored, as the API server does.
+type Client struct {
+	scheme *runtime.Scheme
+	mapper meta.RESTMapper
+
+	mu              sync.RWMutex
+	objects         map[objectKey]client.Object
+	resourceVersion int
+}
+
+var _ client.Client = &Client{}
+
+func NewClient(scheme *runtime.Scheme, mapper meta.RESTMapper, objects ...client.Object) (*Client, error) {
+	c := &Client{
+		scheme:  scheme,
+		mapper:  mapper,
+		objects: map[objectKey]client.Object{},
+	}
+	for _, obj := range objects {
+		if err := c.Create(context.Background(), obj); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Objects returns a copy of every stored object, sorted by kind, namespace and
+// name.
+func (c *Client) Objects() []client.Object {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]objectKey, 0, len(c.objects))
+	for key := range c.objects {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].gvk.String() != keys[j].gvk.String() {
+			return keys[i].gvk.String() < keys[j].gvk.String()
+		}
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	objects := make([]client.Object, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, c.objects[key].DeepCopyObject().(client.Object))
+	}
+	return objects
+}
+
+func (c *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stored, ok := c.objects[objectKey{gvk: gvk, namespace: key.Namespace, name: key.Name}]
+	if !ok {
+		return apierrors.NewNotFound(c.groupResource(gvk), key.Name)
+	}
+	return c.copyInto(stored, obj, gvk)
+}
+
+func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listGVK, err := c.GroupVersionKindFor(list)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(listGVK.Kind, "List") {
+		return fmt.Errorf("%s is not a list kind", listGVK.Kind)
+	}
+	gvk := listGVK.GroupVersion().WithKind(strings.TrimSuffix(listGVK.Kind, "List"))
+
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var items []runtime.Object
+	for key, stored := range c.objects {
+		if key.gvk != gvk {
+			continue
+		}
+		if listOpts.Namespace != "" && key.namespace != listOpts.Namespace {
+			continue
+		}
+		if listOpts.LabelSelector != nil && !listOpts.LabelSelector.Matches(labels.Set(stored.GetLabels())) {
+			continue
+		}
+		items = append(items, stored)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return client.ObjectKeyFromObject(items[i].(client.Object)).String() < client.ObjectKeyFromObject(items[j].(client.Object)).String()
+	})
+
+	if u, ok := list.(*unstructured.UnstructuredList); ok {
+		u.Items = nil
+		for _, item := range items {
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(item)
+			if err != nil {
+				return err
+			}
+			obj := unstructured.Unstructured{Object: content}
+			obj.SetGroupVersionKind(gvk)
+			u.Items = append(u.Items, obj)
+		}
+		return nil
+	}
+
+	copies := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		copies = append(copies, item.DeepCopyObject())
+	}
+	return meta.SetList(list, copies)
+}
+
+func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+	key, err := c.keyFor(gvk, obj)
+	if err != nil {
+		return err
+	}
+
+	createOpts := client.CreateOptions{}
+	createOpts.ApplyOptions(opts)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if obj.GetName() == "" && obj.GetGenerateName() != "" {
+		obj.SetName(obj.GetGenerateName() + strconv.Itoa(len(c.objects)))
+	}
+	if obj.GetName() == "" {
+		return apierrors.NewBadRequest("name is required")
+	}
+
+	key.name = obj.GetName()
+	if _, ok := c.objects[key]; ok {
+		return apierrors.NewAlreadyExists(c.groupResource(gvk), obj.GetName())
+	}
+
+	if obj.GetUID() == "" {
+		obj.SetUID(stableUID(key))
+	}
+	if isDryRun(createOpts.DryRun) {
+		return nil
+	}
+	return c.store(key, obj)
+}
+
+func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+	key, err := c.keyFor(gvk, obj)
+	if err != nil {
+		return err
+	}
+
+	updateOpts := client.UpdateOptions{}
+	updateOpts.ApplyOptions(opts)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.objects[key]; !ok {
+		return apierrors.NewNotFound(c.groupResource(gvk), obj.GetName())
+	}
+	if isDryRun(updateOpts.DryRun) {
+		return nil
+	}
+	return c.store(key, obj)
+}
+
+func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+
+	key, err := c.keyFor(gvk, obj)
+	if err != nil {
+		return err
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+
+	patchOpts := client.PatchOptions{}
+	patchOpts.ApplyOptions(opts)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored, ok := c.objects[key]
+	if !ok {
+		return apierrors.NewNotFound(c.groupResource(gvk), obj.GetName())
+	}
+
+	original, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch patch.Type() {
+	case types.MergePatchType:
+		patched, err = jsonpatch.MergePatch(original, data)
+	case types.JSONPatchType:
+		var decoded jsonpatch.Patch
+		if decoded, err = jsonpatch.DecodePatch(data); err == nil {
+			patched, err = decoded.Apply(original)
+		}
+	case types.StrategicMergePatchType:
+		patched, err = strategicpatch.StrategicMergePatch(original, data, stored)
+	default:
+		return fmt.Errorf("patch type %s is not supported", patch.Type())
+	}
+	if err != nil {
+		return apierrors.NewBadRequest(err.Error())
+	}
+
+	result, err := c.scheme.New(gvk)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patched, result); err != nil {
+		return err
+	}
+	if isDryRun(patchOpts.DryRun) {
+		return c.copyInto(result.(client.Object), obj, gvk)
+	}
+	if err := c.store(key, result.(client.Object)); err != nil {
+		return err
+	}
+	return c.copyInto(c.objects[key], obj, gvk)
+}
+
+func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+	key, err := c.keyFor(gvk, obj)
+	if err != nil {
+		return err
+	}
+
+	deleteOpts := client.DeleteOptions{}
+	deleteOpts.ApplyOptions(opts)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.objects[key]; !ok {
+		return apierrors.NewNotFound(c.groupResource(gvk), obj.GetName())
+	}
+	if isDryRun(deleteOpts.DryRun) {
+		return nil
+	}
+	delete(c.objects, key)
+	return nil
+}
+
+func (c *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+
+	deleteOpts := client.DeleteAllOfOptions{}
+	deleteOpts.ApplyOptions(opts)
+	if isDryRun(deleteOpts.DryRun) {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, stored := range c.objects {
+		if key.gvk != gvk {
+			continue
+		}
+		if deleteOpts.Namespace != "" && key.namespace != deleteOpts.Namespace {
+			continue
+		}
+		if deleteOpts.LabelSelector != nil && !deleteOpts.LabelSelector.Matches(labels.Set(stored.GetLabels())) {
+			continue
+		}
+		delete(c.objects, key)
+	}
+	return nil
+}
+
+// Status writes the whole object, as the store doesn't separate status from the
+// rest of the object.
+func (c *Client) Status() client.SubResourceWriter {
+	return &subResourceClient{client: c}
+}
+
+func (c *Client) SubResource(subResource string) client.SubResourceClient {
+	return &subResourceClient{client: c, subResource: subResource}
+}
+
+func (c *Client) Scheme() *runtime.Scheme {
+	return c.scheme
+}
+
+func (c *Client) RESTMapper() meta.RESTMapper {
+	return c.mapper
+}
+
+func (c *Client) GroupVersionKindFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	return apiutil.GVKForObject(obj, c.scheme)
+}
+
+func (c *Client) IsObjectNamespaced(obj runtime.Object) (bool, error) {
+	return apiutil.IsObjectNamespaced(obj, c.scheme, c.mapper)
+}
+
+// keyFor returns the store key of obj, without a namespace for cluster scoped
+// kinds.
+func (c *Client) keyFor(gvk schema.GroupVersionKind, obj client.Object) (objectKey, error) {
+	key := objectKey{gvk: gvk, namespace: obj.GetNamespace(), name: obj.GetName()}
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return key, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		key.namespace = ""
+	}
+	return key, nil
+}
+
+func isDryRun(dryRun []string) bool {
+	for _, value := range dryRun {
+		if value == metav1.DryRunAll {
+			return true
+		}
+	}
+	return false
+}
+
+// store saves a typed copy of obj and bumps its resource version. Callers hold
+// the write lock.
+func (c *Client) store(key objectKey, obj client.Object) error {
+	c.resourceVersion++
+	obj.SetResourceVersion(strconv.Itoa(c.resourceVersion))
+
+	typed, err := c.scheme.New(key.gvk)
+	if err != nil {
+		return err
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+			return err
+		}
+	} else {
+		reflect.ValueOf(typed).Elem().Set(reflect.ValueOf(obj.DeepCopyObject()).Elem())
+	}
+
+	typed.GetObjectKind().SetGroupVersionKind(key.gvk)
+	c.objects[key] = typed.(client.Object)
+	return nil
+}
+
+func (c *Client) copyInto(stored client.Object, obj client.Object, gvk schema.GroupVersionKind) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(stored)
+		if err != nil {
+			return err
+		}
+		u.SetUnstructuredContent(content)
+		u.SetGroupVersionKind(gvk)
+		return nil
+	}
+
+	target := reflect.ValueOf(obj).Elem()
+	source := reflect.ValueOf(stored.DeepCopyObject()).Elem()
+	if target.Type() != source.Type() {
+		return fmt.Errorf("cannot copy %s into %T", gvk.Kind, obj)
+	}
+	target.Set(source)
+	return nil
+}
+
+func (c *Client) groupResource(gvk schema.GroupVersionKind) schema.GroupResource {
+	return schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind) + "s"}
+}
+
+type subResourceClient struct {
+	client      *Client
+	subResource string
+}
+
+func (s *subResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	return fmt.Errorf("subresource %s is not supported", s.subResource)
+}
+
+func (s *subResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	return fmt.Errorf("subresource %s is not supported", s.subResource)
+}
+
+func (s *subResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return s.client.Update(ctx, obj)
+}
+
+func (s *subResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	return s.client.Patch(ctx, obj, patch)
+}
+
+// stableUID derives a UID from the object key, so that repeated runs over the
+// same input produce the same output.
+func stableUID(key objectKey) types.UID {
+	sum := sha256.Sum256([]byte(key.gvk.String() + "/" + key.namespace + "/" + key.name))
+	id := hex.EncodeToString(sum[:16])
+	return types.UID(id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:32])
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newConfigMap(name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+}
+
+func TestClientDryRun(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewClient(clientgoscheme.Scheme, NewRESTMapper(clientgoscheme.Scheme), newConfigMap("existing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dryRun := client.NewDryRunClient(c)
+
+	if err := dryRun.Create(ctx, newConfigMap("created")); err != nil {
+		t.Fatalf("dry-run Create() error = %v", err)
+	}
+	if err := dryRun.Create(ctx, newConfigMap("existing")); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("dry-run Create() of an existing object error = %v, want AlreadyExists", err)
+	}
+
+	updated := newConfigMap("existing")
+	updated.Data = map[string]string{"key": "value"}
+	if err := dryRun.Update(ctx, updated); err != nil {
+		t.Fatalf("dry-run Update() error = %v", err)
+	}
+
+	patched := newConfigMap("existing")
+	if err := dryRun.Patch(ctx, patched, client.RawPatch("application/merge-patch+json", []byte(`{"data":{"key":"patched"}}`))); err != nil {
+		t.Fatalf("dry-run Patch() error = %v", err)
+	}
+	if patched.Data["key"] != "patched" {
+		t.Errorf("dry-run Patch() result data = %v, want the patched object", patched.Data)
+	}
+
+	if err := dryRun.Delete(ctx, newConfigMap("existing")); err != nil {
+		t.Fatalf("dry-run Delete() error = %v", err)
+	}
+
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "created"}, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Get() of dry-run created object error = %v, want NotFound", err)
+	}
+	stored := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "existing"}, stored); err != nil {
+		t.Fatalf("Get() of dry-run deleted object error = %v", err)
+	}
+	if len(stored.Data) != 0 {
+		t.Errorf("stored data = %v, want dry-run writes left out", stored.Data)
+	}
+}
+
+func TestClientScopeFromRESTMapper(t *testing.T) {
+	ctx := context.Background()
+	scheme := clientgoscheme.Scheme
+
+	mapper := meta.NewDefaultRESTMapper(scheme.PrioritizedVersionsAllGroups())
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	c, err := NewClient(scheme, mapper, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Namespace: "ignored", Name: "web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Get(ctx, client.ObjectKey{Name: "web"}, &corev1.Namespace{}); err != nil {
+		t.Errorf("Get() of cluster scoped object error = %v", err)
+	}
+	if namespaced, err := c.IsObjectNamespaced(&corev1.ConfigMap{}); err != nil || !namespaced {
+		t.Errorf("IsObjectNamespaced(ConfigMap) = %v, %v, want true", namespaced, err)
+	}
+	if err := c.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "unmapped"}}); err == nil {
+		t.Error("Create() of a kind the RESTMapper doesn't know error = nil, want an error")
+	}
+}
@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeploymentTopologySpread spreads the replicas of a Deployment over nodes by
+// adding topology spread constraints to its Pod template.
+type DeploymentTopologySpread struct{}
+
+func (d DeploymentTopologySpread) Name() string {
+	return "Deployment Topology Spread"
+}
+
+func (d DeploymentTopologySpread) Type() int {
+	return PolicyTypeDeployment
+}
+
+func (d DeploymentTopologySpread) Admission() bool {
+	return true
+}
+
+func (d DeploymentTopologySpread) Validate(obj runtime.Object, env *Env) (error, bool) {
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return fmt.Errorf("could not cast object to Deployment"), false
+	}
+
+	return nil, validateTopologySpread(env, d, deployment, deployment.Spec.Replicas, deployment.Spec.Selector, &deployment.Spec.Template)
+}
+
+func (d DeploymentTopologySpread) Apply(obj runtime.Object, env *Env) error {
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return fmt.Errorf("could not cast object to Deployment")
+	}
+
+	// Pods of older ReplicaSets are on their way out and shouldn't skew the
+	// spread of a rollout.
+	return applyTopologySpread(env, d, deployment, deployment.Spec.Selector, &deployment.Spec.Template, []string{appsv1.DefaultDeploymentUniqueLabelKey})
+}
+
+func (d DeploymentTopologySpread) Categories() []string {
+	return schedulingCategories
+}
+
+func (d DeploymentTopologySpread) Annotations() []annotation.Key {
+	return topologySpreadAnnotations
+}
+
+func (d DeploymentTopologySpread) ValidateConfig(policyConfig config.PolicyConfig) error {
+	return validateTopologySpreadConfig(policyConfig)
+}
+
+func init() {
+	RegisterPolicy(&DeploymentTopologySpread{})
+}
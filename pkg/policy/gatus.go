@@ -0,0 +1,417 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/util"
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	gatusGenerateAnnotation = annotation.Key{
+		Name:        "gatus-generate",
+		Inherit:     true,
+		Type:        annotation.TypeBool,
+		Description: "Generate a Gatus endpoint for the Ingress. Setting it to false removes a previously generated one.",
+	}
+	gatusGenerateServiceAnnotation = annotation.Key{
+		Name:        "gatus-generate-service",
+		Inherit:     true,
+		Type:        annotation.TypeBool,
+		Description: "Generate a Gatus endpoint for the Service. Setting it to false removes a previously generated one.",
+	}
+	gatusNameAnnotation = annotation.Key{
+		Name:        "gatus-name",
+		Type:        annotation.TypeString,
+		Description: "Name of the endpoint, defaults to the object name.",
+	}
+	gatusGroupAnnotation = annotation.Key{
+		Name:        "gatus-group",
+		Inherit:     true,
+		Type:        annotation.TypeString,
+		Default:     gatusDefaultGroup,
+		Description: "Group of the endpoint, defaults to the group setting of the policy.",
+	}
+	gatusHostAnnotation = annotation.Key{
+		Name:        "gatus-host",
+		Type:        annotation.TypeString,
+		Description: "Host to check, defaults to the host of the first Ingress rule or to the Service DNS name and first port.",
+	}
+	gatusPathAnnotation = annotation.Key{
+		Name:        "gatus-path",
+		Type:        annotation.TypeString,
+		Description: "Path to check, defaults to the path of the first Ingress rule or to / for Services.",
+	}
+	gatusProtocolAnnotation = annotation.Key{
+		Name:        "gatus-protocol",
+		Inherit:     true,
+		Type:        annotation.TypeEnum,
+		Allowed:     []string{"http", "https"},
+		Description: "Protocol to check with, defaults to the protocol setting of the policy, or https for Ingresses and http for Services.",
+	}
+	gatusConditions = annotation.Key{
+		Name:        "gatus-conditions",
+		Inherit:     true,
+		Type:        annotation.TypeList,
+		Default:     "[STATUS] == 200",
+		Description: "Comma separated Gatus conditions the endpoint has to meet.",
+	}
+	gatusDns = annotation.Key{
+		Name:        "gatus-dns",
+		Inherit:     true,
+		Type:        annotation.TypeBool,
+		Default:     "false",
+		Description: "Resolve the host through the dnsResolver setting of the policy.",
+	}
+	gatusLog = ctrl.Log.WithName("gatus")
+)
+
+// gatusCategories select every Gatus policy with the policies annotation.
+var gatusCategories = []string{"gatus", "monitoring"}
+
+// gatusAnnotations are understood by every Gatus policy, next to the opt-in key
+// of the policy itself.
+var gatusAnnotations = []annotation.Key{
+	gatusNameAnnotation,
+	gatusGroupAnnotation,
+	gatusHostAnnotation,
+	gatusPathAnnotation,
+	gatusProtocolAnnotation,
+	gatusConditions,
+	gatusDns,
+}
+
+const (
+	gatusDefaultInterval    = "1m"
+	gatusDefaultDnsResolver = "tcp://1.1.1.1:53"
+	gatusDefaultGroup       = "default"
+	gatusDefaultProtocol    = "https"
+	gatusConfigKey          = "config.yaml"
+)
+
+type GatusConfigMap struct {
+	Endpoints []GatusEndpoint `yaml:"endpoints"`
+}
+
+type GatusEndpoint struct {
+	Name       string          `yaml:"name"`
+	Group      string          `yaml:"group"`
+	Url        string          `yaml:"url"`
+	Interval   string          `yaml:"interval"`
+	Ui         GatusUi         `yaml:"ui"`
+	Conditions []string        `yaml:"conditions,omitempty"`
+	Dns        *GatusDnsClient `yaml:"client,omitempty"`
+	Alerts     []GatusAlert    `yaml:"alerts,omitempty"`
+}
+
+type GatusUi struct {
+	HideHostname bool `yaml:"hide-hostname"`
+	HideUrl      bool `yaml:"hide-url"`
+}
+
+type GatusDnsClient struct {
+	DnsResolver string `yaml:"dns-resolver"`
+}
+
+// GatusAlert is passed through to Gatus as is, see the Gatus docs for the
+// fields each alert type takes.
+type GatusAlert map[string]interface{}
+
+// GatusRenderOptions tune RenderGatusConfig for Gatus instances running outside
+// the cluster.
+type GatusRenderOptions struct {
+	// GroupByNamespace puts every endpoint without a group annotation in a group
+	// named after its namespace.
+	GroupByNamespace bool
+	// Alerts are added to every endpoint.
+	Alerts []GatusAlert
+}
+
+// gatusEndpointFunc generates the endpoint of a parent, reading its annotations
+// through the given parser.
+type gatusEndpointFunc func(parser *annotation.Parser) (GatusEndpoint, error)
+
+// validateGatus decides whether a Gatus policy applies to parent, opted in with
+// the generate annotation. An explicit false removes the generated ConfigMap,
+// invalid annotations skip the policy with a Warning Event so the last good
+// ConfigMap stays in place. Values inherited from an owner, the Namespace or the
+// cluster defaults are logged and reported in an Event.
+func validateGatus(env *Env, p PolicyInterface, parent client.Object, generate annotation.Key, endpoint gatusEndpointFunc) bool {
+	parser := annotationParser(env, parent)
+	enabled, set := parser.Bool(generate)
+	err := parser.Err()
+	if err == nil && set && enabled {
+		_, err = endpoint(parser)
+	}
+	if err != nil {
+		warnInvalidAnnotations(env, p, parent, err)
+		return false
+	}
+	if !set {
+		return false
+	}
+	if inherited := parser.Inherited(); len(inherited) > 0 {
+		gatusLog.Info("Using inherited annotations", "policy", p.Name(), "parent", client.ObjectKeyFromObject(parent), "inherited", inherited)
+		env.Eventf(parent, corev1.EventTypeNormal, "InheritedAnnotations", "%s uses inherited annotations: %s", p.Name(), formatInherited(inherited))
+	}
+	if !enabled {
+		gatusLog.Info("Removing Gatus ConfigMap because annotation is explicitly false", "policy", p.Name(), "parent", client.ObjectKeyFromObject(parent))
+		if err := handleGatusConfigMap(env, Key(p), parent, true, endpoint); err != nil {
+			gatusLog.Error(err, "error removing Gatus ConfigMap", "parent", client.ObjectKeyFromObject(parent))
+		}
+		return false
+	}
+	return true
+}
+
+// handleGatusConfigMap creates, updates or deletes the Gatus ConfigMap generated
+// for parent. The ConfigMap is removed when the parent is being deleted or when
+// remove is set.
+func handleGatusConfigMap(env *Env, policyKey string, parent client.Object, remove bool, endpoint gatusEndpointFunc) error {
+	labels := config.Current().Labels
+	configMapList := corev1.ConfigMapList{}
+	err := env.Client.List(context.Background(), &configMapList, client.MatchingLabels{
+		labels.ManagedBy: labels.ManagedByValue,
+		labels.ParentUid: string(parent.GetUID()),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Delete configmap on parent deletion
+	if !parent.GetDeletionTimestamp().IsZero() || remove {
+		if len(configMapList.Items) == 1 {
+			configMap := configMapList.Items[0]
+			return env.Client.Delete(context.Background(), &configMap)
+		}
+		return nil
+	}
+
+	// Create configmap if it doesn't exist
+	if len(configMapList.Items) == 0 {
+		gatusLog.Info("Creating Gatus ConfigMap", "parent", client.ObjectKeyFromObject(parent))
+
+		generated, err := endpoint(annotationParser(env, parent))
+		if err != nil {
+			return err
+		}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: generateGatusConfigMapMetadata(parent, policyKey),
+			Data: map[string]string{
+				gatusConfigKey: generateGatusConfigMapData(generated),
+			},
+		}
+		setGatusControllerTrigger(env, parent, configMap)
+		return env.Client.Create(context.Background(), configMap)
+	}
+
+	// Update configmap if it exists
+	if len(configMapList.Items) == 1 {
+		generated, err := endpoint(annotationParser(env, parent))
+		if err != nil {
+			return err
+		}
+		configMap := configMapList.Items[0]
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[gatusConfigKey] = generateGatusConfigMapData(generated)
+		setGatusControllerTrigger(env, parent, &configMap)
+		return env.Client.Update(context.Background(), &configMap)
+	}
+
+	return nil
+}
+
+// setGatusControllerTrigger points the generated ConfigMap back at its parent,
+// so edits to or deletion of the ConfigMap get reverted by a new reconcile.
+func setGatusControllerTrigger(env *Env, parent client.Object, configMap *corev1.ConfigMap) {
+	trigger, err := util.NewControllerTrigger(parent, env.Client.Scheme())
+	if err != nil {
+		gatusLog.Error(err, "error resolving controller trigger", "parent", client.ObjectKeyFromObject(parent))
+		return
+	}
+	util.SetControllerTrigger(configMap, trigger)
+}
+
+// generateGatusConfigMapMetadata names the ConfigMap after the kind and name of
+// parent, so an Ingress and a Service of the same name don't collide. When the
+// output namespace is redirected the namespace of parent is added as well.
+func generateGatusConfigMapMetadata(parent client.Object, policyKey string) metav1.ObjectMeta {
+	cfg := config.Current()
+	namespace := cfg.PolicyOutputNamespace(policyKey, parent.GetNamespace())
+	name := getObjectName(parent) + "-" + gatusParentKind(parent) + "-gatus-generated"
+	if namespace != parent.GetNamespace() {
+		name = parent.GetNamespace() + "-" + name
+	}
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		Labels: map[string]string{
+			cfg.Labels.ManagedBy: cfg.Labels.ManagedByValue,
+			"gatus.io/enabled":   "enabled",
+			cfg.Labels.ParentUid: string(parent.GetUID()),
+		},
+	}
+}
+
+// generateGatusConfigMapData renders a Gatus config.yaml. The controller renders
+// one endpoint per ConfigMap, RenderGatusConfig all of them at once.
+func generateGatusConfigMapData(endpoints ...GatusEndpoint) string {
+	configMapData := &GatusConfigMap{
+		Endpoints: endpoints,
+	}
+
+	outputYaml, err := yaml.Marshal(configMapData)
+	if err != nil {
+		gatusLog.Error(err, "error marshalling config map data")
+	}
+
+	return string(outputYaml)
+}
+
+// generateGatusEndpoint fills in the endpoint fields every parent kind shares,
+// reading the annotations through p so parse errors end up in p.Err.
+func generateGatusEndpoint(p *annotation.Parser, parent client.Object, policyConfig config.PolicyConfig, url string) (GatusEndpoint, error) {
+	dns, _ := p.Bool(gatusDns)
+
+	endpoint := GatusEndpoint{
+		Name:       p.String(gatusNameAnnotation, getObjectName(parent)),
+		Group:      p.String(gatusGroupAnnotation, policyConfig.Setting("group", gatusGroupAnnotation.Default)),
+		Url:        url,
+		Interval:   policyConfig.Setting("interval", gatusDefaultInterval),
+		Ui:         GatusUi{HideHostname: true, HideUrl: true},
+		Conditions: p.List(gatusConditions),
+		Dns:        mutateGatusDns(dns, policyConfig),
+	}
+	return endpoint, p.Err()
+}
+
+func mutateGatusDns(annotationValue bool, policyConfig config.PolicyConfig) *GatusDnsClient {
+	if annotationValue == false {
+		return nil
+	}
+
+	return &GatusDnsClient{
+		DnsResolver: policyConfig.Setting("dnsResolver", gatusDefaultDnsResolver),
+	}
+}
+
+// RenderGatusConfig merges the endpoints of every Ingress and Service with Gatus
+// generation turned on, and the policy enabled and selected, into a single
+// config.yaml, sorted by namespace, kind and name. Each endpoint is rendered
+// exactly as the controller renders it.
+func RenderGatusConfig(env *Env, objects []client.Object, opts GatusRenderOptions) string {
+	sorted := append([]client.Object{}, objects...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+		if kindOrder(a) != kindOrder(b) {
+			return kindOrder(a) < kindOrder(b)
+		}
+		return getObjectName(a) < getObjectName(b)
+	})
+
+	var endpoints []GatusEndpoint
+	for _, obj := range sorted {
+		var p PolicyInterface
+		var generate annotation.Key
+		switch obj.(type) {
+		case *networkingv1.Ingress:
+			p, generate = IngressGenerateGatus{}, gatusGenerateAnnotation
+		case *corev1.Service:
+			p, generate = ServiceGenerateGatus{}, gatusGenerateServiceAnnotation
+		default:
+			continue
+		}
+
+		parser := annotationParser(env, obj)
+		if enabled, _ := parser.Bool(generate); !enabled {
+			continue
+		}
+		if selection, _ := selectPolicies(env, obj); !Config(p).IsEnabled() || !selection.selects(p) {
+			continue
+		}
+
+		var endpoint GatusEndpoint
+		var err error
+		switch typed := obj.(type) {
+		case *networkingv1.Ingress:
+			endpoint, err = generateIngressGatusEndpoint(parser, typed, Config(p))
+		case *corev1.Service:
+			endpoint, err = generateServiceGatusEndpoint(parser, typed, Config(p))
+		}
+		if err != nil {
+			gatusLog.Error(err, "skipping object with invalid annotations", "object", client.ObjectKeyFromObject(obj))
+			continue
+		}
+
+		if _, ok := parser.Origin(gatusGroupAnnotation); !ok && opts.GroupByNamespace {
+			endpoint.Group = obj.GetNamespace()
+		}
+		if len(opts.Alerts) > 0 {
+			endpoint.Alerts = opts.Alerts
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return generateGatusConfigMapData(endpoints...)
+}
+
+func gatusParentKind(obj client.Object) string {
+	if _, ok := obj.(*networkingv1.Ingress); ok {
+		return "ingress"
+	}
+	return "service"
+}
+
+func kindOrder(obj client.Object) int {
+	if _, ok := obj.(*networkingv1.Ingress); ok {
+		return 0
+	}
+	return 1
+}
+
+func validateGatusConfig(policyConfig config.PolicyConfig) error {
+	for key, value := range policyConfig.Settings {
+		switch key {
+		case "interval":
+			if _, err := time.ParseDuration(value); err != nil {
+				return fmt.Errorf("settings.interval: %w", err)
+			}
+		case "dnsResolver":
+			if u, err := url.Parse(value); err != nil || u.Host == "" {
+				return fmt.Errorf("settings.dnsResolver %q: expected <protocol>://<host>:<port>", value)
+			}
+		case "group":
+		case "protocol":
+			if value != "http" && value != "https" {
+				return fmt.Errorf("settings.protocol %q: expected http or https", value)
+			}
+		default:
+			return fmt.Errorf("settings.%s: unknown setting", key)
+		}
+	}
+	return nil
+}
+
+func getObjectName(obj client.Object) string {
+	name := obj.GetName()
+	if name != "" {
+		return name
+	}
+	return obj.GetGenerateName()
+}
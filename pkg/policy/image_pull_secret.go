@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	imagePullSecretAnnotation = annotation.Key{
+		Name:        "image-pull-secret",
+		Type:        annotation.TypeBool,
+		Description: "Set on a Namespace to always (true) or never (false) get a copy of the image pull Secret, regardless of the namespaceSelector setting of the policy.",
+	}
+	imagePullSecretLog = ctrl.Log.WithName("image_pull_secret")
+)
+
+const (
+	attachToServiceAccount = "serviceAccount"
+	attachToPod            = "pod"
+	attachToNone           = "none"
+
+	defaultServiceAccountName = "default"
+)
+
+// imagePullSecretConfig is the replication configured on the Namespace Image
+// Pull Secret policy, which the Pod Image Pull Secret policy shares.
+type imagePullSecretConfig struct {
+	// source is the Secret copied into every selected Namespace.
+	source client.ObjectKey
+	// name is the name of the copies.
+	name     string
+	selector labels.Selector
+	// attachTo is where the copy is added to imagePullSecrets: the default
+	// ServiceAccount, every Pod, or nowhere.
+	attachTo string
+}
+
+// imagePullSecretSettings parses the replication settings. The second result is
+// false when no source Secret is set, the policies do nothing then.
+func imagePullSecretSettings(policyConfig config.PolicyConfig) (imagePullSecretConfig, bool, error) {
+	settings := imagePullSecretConfig{}
+
+	secret := policyConfig.Setting("secret", "")
+	if secret == "" {
+		return settings, false, nil
+	}
+	namespace, name, ok := strings.Cut(secret, "/")
+	if !ok || namespace == "" || name == "" {
+		return settings, false, fmt.Errorf("settings.secret %q: expected namespace/name", secret)
+	}
+	settings.source = client.ObjectKey{Namespace: namespace, Name: name}
+
+	settings.name = policyConfig.Setting("name", name)
+	if msgs := validation.IsDNS1123Subdomain(settings.name); len(msgs) > 0 {
+		return settings, false, fmt.Errorf("settings.name %q: %s", settings.name, strings.Join(msgs, ", "))
+	}
+
+	selector, err := labels.Parse(policyConfig.Setting("namespaceSelector", ""))
+	if err != nil {
+		return settings, false, fmt.Errorf("settings.namespaceSelector: %w", err)
+	}
+	settings.selector = selector
+
+	settings.attachTo = policyConfig.Setting("attachTo", attachToServiceAccount)
+	switch settings.attachTo {
+	case attachToServiceAccount, attachToPod, attachToNone:
+	default:
+		return settings, false, fmt.Errorf("settings.attachTo %q: expected %s, %s or %s", settings.attachTo, attachToServiceAccount, attachToPod, attachToNone)
+	}
+	return settings, true, nil
+}
+
+// selects reports whether namespace should hold a copy. The annotation of the
+// Namespace overrides the selector, the source Namespace never gets a copy.
+func (c imagePullSecretConfig) selects(namespace *corev1.Namespace) (bool, error) {
+	if namespace.Name == c.source.Namespace {
+		return false, nil
+	}
+	if value, ok := imagePullSecretAnnotation.Value(namespace.Annotations); ok {
+		selected, err := annotation.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("annotation %s on Namespace %s: %w", imagePullSecretAnnotation.FullName(), namespace.Name, err)
+		}
+		return selected, nil
+	}
+	return c.selector.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// addImagePullSecret adds name to refs unless it is already there.
+func addImagePullSecret(refs []corev1.LocalObjectReference, name string) ([]corev1.LocalObjectReference, bool) {
+	for _, ref := range refs {
+		if ref.Name == name {
+			return refs, false
+		}
+	}
+	return append(refs, corev1.LocalObjectReference{Name: name}), true
+}
+
+// removeImagePullSecret removes name from refs.
+func removeImagePullSecret(refs []corev1.LocalObjectReference, name string) ([]corev1.LocalObjectReference, bool) {
+	kept := make([]corev1.LocalObjectReference, 0, len(refs))
+	for _, ref := range refs {
+		if ref.Name != name {
+			kept = append(kept, ref)
+		}
+	}
+	return kept, len(kept) != len(refs)
+}
@@ -0,0 +1,287 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func setImagePullSecretConfig(t *testing.T, settings map[string]string) {
+	cfg := config.Default()
+	cfg.Policies = map[string]config.PolicyConfig{"namespace-image-pull-secret": {Settings: settings}}
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+}
+
+// imagePullSecretObjects returns the source Secret and the team-a Namespace with
+// its default ServiceAccount.
+func imagePullSecretObjects(labels map[string]string, annotations map[string]string) []client.Object {
+	return []client.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "registry", Name: "regcred"},
+			Type:       corev1.SecretTypeDockerConfigJson,
+			Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", UID: "team-a-uid", Labels: labels, Annotations: annotations}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: defaultServiceAccountName}},
+	}
+}
+
+func getNamespaceObject(t *testing.T, env *Env, name string) *corev1.Namespace {
+	namespace := &corev1.Namespace{}
+	if err := env.Client.Get(context.Background(), client.ObjectKey{Name: name}, namespace); err != nil {
+		t.Fatal(err)
+	}
+	return namespace
+}
+
+func defaultServiceAccountSecrets(t *testing.T, env *Env) []corev1.LocalObjectReference {
+	serviceAccount := &corev1.ServiceAccount{}
+	if err := env.Client.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: defaultServiceAccountName}, serviceAccount); err != nil {
+		t.Fatal(err)
+	}
+	return serviceAccount.ImagePullSecrets
+}
+
+func TestNamespaceImagePullSecret(t *testing.T) {
+	tests := []struct {
+		name                string
+		settings            map[string]string
+		labels              map[string]string
+		annotations         map[string]string
+		wantOutcome         string
+		wantCopy            bool
+		wantServiceAccounts []string
+	}{
+		{
+			name:                "selected by default",
+			settings:            map[string]string{"secret": "registry/regcred"},
+			wantOutcome:         audit.OutcomeApplied,
+			wantCopy:            true,
+			wantServiceAccounts: []string{"regcred"},
+		},
+		{
+			name:        "not selected",
+			settings:    map[string]string{"secret": "registry/regcred", "namespaceSelector": "pull-secret=true"},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:                "selected by label with another name",
+			settings:            map[string]string{"secret": "registry/regcred", "namespaceSelector": "pull-secret=true", "name": "mirror-pull"},
+			labels:              map[string]string{"pull-secret": "true"},
+			wantOutcome:         audit.OutcomeApplied,
+			wantCopy:            true,
+			wantServiceAccounts: []string{"mirror-pull"},
+		},
+		{
+			name:                "annotation overrides the selector",
+			settings:            map[string]string{"secret": "registry/regcred", "namespaceSelector": "pull-secret=true"},
+			annotations:         map[string]string{"policy-control.aumer.io/image-pull-secret": "true"},
+			wantOutcome:         audit.OutcomeApplied,
+			wantCopy:            true,
+			wantServiceAccounts: []string{"regcred"},
+		},
+		{
+			name:        "annotation opts out",
+			settings:    map[string]string{"secret": "registry/regcred"},
+			annotations: map[string]string{"policy-control.aumer.io/image-pull-secret": "false"},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "attached to Pods",
+			settings:    map[string]string{"secret": "registry/regcred", "attachTo": "pod"},
+			wantOutcome: audit.OutcomeApplied,
+			wantCopy:    true,
+		},
+		{
+			name:        "no source Secret set",
+			wantOutcome: audit.OutcomeSkipped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setImagePullSecretConfig(t, tt.settings)
+			env := newTestEnv(imagePullSecretObjects(tt.labels, tt.annotations)...)
+
+			entry, err := ApplyPolicy(NamespaceImagePullSecret{}, getNamespaceObject(t, env, "team-a"), env)
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, tt.wantOutcome)
+			}
+
+			name := tt.settings["name"]
+			if name == "" {
+				name = "regcred"
+			}
+			copied := &corev1.Secret{}
+			err = env.Client.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: name}, copied)
+			switch {
+			case tt.wantCopy && err != nil:
+				t.Errorf("copy %s not created: %v", name, err)
+			case tt.wantCopy && (copied.Type != corev1.SecretTypeDockerConfigJson || string(copied.Data[corev1.DockerConfigJsonKey]) != "{}"):
+				t.Errorf("copy = %+v, want the type and data of the source", copied)
+			case !tt.wantCopy && !apierrors.IsNotFound(err):
+				t.Errorf("copy %s get error = %v, want not found", name, err)
+			}
+
+			var refs []string
+			for _, ref := range defaultServiceAccountSecrets(t, env) {
+				refs = append(refs, ref.Name)
+			}
+			if len(refs) != len(tt.wantServiceAccounts) || (len(refs) > 0 && refs[0] != tt.wantServiceAccounts[0]) {
+				t.Errorf("default ServiceAccount imagePullSecrets = %v, want %v", refs, tt.wantServiceAccounts)
+			}
+		})
+	}
+}
+
+func TestNamespaceImagePullSecretRemovesCopy(t *testing.T) {
+	setImagePullSecretConfig(t, map[string]string{"secret": "registry/regcred"})
+	env := newTestEnv(imagePullSecretObjects(nil, nil)...)
+	if _, err := ApplyPolicy(NamespaceImagePullSecret{}, getNamespaceObject(t, env, "team-a"), env); err != nil {
+		t.Fatalf("ApplyPolicy() error = %v", err)
+	}
+
+	// The Namespace leaves the selector.
+	setImagePullSecretConfig(t, map[string]string{"secret": "registry/regcred", "namespaceSelector": "pull-secret=true"})
+	if _, err := ApplyPolicy(NamespaceImagePullSecret{}, getNamespaceObject(t, env, "team-a"), env); err != nil {
+		t.Fatalf("ApplyPolicy() error = %v", err)
+	}
+
+	err := env.Client.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "regcred"}, &corev1.Secret{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("copy get error = %v, want not found", err)
+	}
+	if refs := defaultServiceAccountSecrets(t, env); len(refs) != 0 {
+		t.Errorf("default ServiceAccount imagePullSecrets = %v, want none", refs)
+	}
+}
+
+func TestNamespaceImagePullSecretKeepsUnmanagedSecret(t *testing.T) {
+	setImagePullSecretConfig(t, map[string]string{"secret": "registry/regcred"})
+	unmanaged := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "regcred"},
+		Data:       map[string][]byte{"token": []byte("mine")},
+	}
+	env := newTestEnv(append(imagePullSecretObjects(nil, nil), unmanaged)...)
+
+	entry, err := ApplyPolicy(NamespaceImagePullSecret{}, getNamespaceObject(t, env, "team-a"), env)
+	if err == nil || entry.Outcome != audit.OutcomeError {
+		t.Fatalf("ApplyPolicy() = %q, %v, want an error", entry.Outcome, err)
+	}
+
+	existing := &corev1.Secret{}
+	if err := env.Client.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "regcred"}, existing); err != nil {
+		t.Fatal(err)
+	}
+	if string(existing.Data["token"]) != "mine" {
+		t.Errorf("unmanaged Secret data = %v, want it left alone", existing.Data)
+	}
+}
+
+func TestPodImagePullSecret(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    map[string]string
+		operation   string
+		secrets     []corev1.LocalObjectReference
+		wantOutcome string
+		wantSecrets []string
+	}{
+		{
+			name:        "attached to Pods",
+			settings:    map[string]string{"secret": "registry/regcred", "attachTo": "pod"},
+			secrets:     []corev1.LocalObjectReference{{Name: "other"}},
+			wantOutcome: audit.OutcomeApplied,
+			wantSecrets: []string{"other", "regcred"},
+		},
+		{
+			name:        "added once",
+			settings:    map[string]string{"secret": "registry/regcred", "attachTo": "pod"},
+			secrets:     []corev1.LocalObjectReference{{Name: "regcred"}},
+			wantOutcome: audit.OutcomeApplied,
+			wantSecrets: []string{"regcred"},
+		},
+		{
+			name:        "attached to the ServiceAccount",
+			settings:    map[string]string{"secret": "registry/regcred"},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "Namespace not selected",
+			settings:    map[string]string{"secret": "registry/regcred", "attachTo": "pod", "namespaceSelector": "pull-secret=true"},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "updates are skipped",
+			settings:    map[string]string{"secret": "registry/regcred", "attachTo": "pod"},
+			operation:   "UPDATE",
+			wantOutcome: audit.OutcomeSkipped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setImagePullSecretConfig(t, tt.settings)
+			env := newTestEnv(imagePullSecretObjects(nil, nil)...)
+			env.Operation = tt.operation
+			pod := newPod(nil)
+			pod.Namespace = "team-a"
+			pod.Spec.ImagePullSecrets = tt.secrets
+
+			entry, err := ApplyPolicy(PodImagePullSecret{}, pod, env)
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, tt.wantOutcome)
+			}
+
+			var names []string
+			for _, ref := range pod.Spec.ImagePullSecrets {
+				names = append(names, ref.Name)
+			}
+			if len(names) != len(tt.wantSecrets) {
+				t.Fatalf("imagePullSecrets = %v, want %v", names, tt.wantSecrets)
+			}
+			for i := range names {
+				if names[i] != tt.wantSecrets[i] {
+					t.Errorf("imagePullSecrets = %v, want %v", names, tt.wantSecrets)
+				}
+			}
+		})
+	}
+}
+
+func TestImagePullSecretSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		wantErr  bool
+	}{
+		{name: "unset"},
+		{name: "valid", settings: map[string]string{"secret": "registry/regcred", "name": "pull", "namespaceSelector": "team", "attachTo": "none"}},
+		{name: "secret without namespace", settings: map[string]string{"secret": "regcred"}, wantErr: true},
+		{name: "invalid name", settings: map[string]string{"secret": "registry/regcred", "name": "Pull_Secret"}, wantErr: true},
+		{name: "invalid selector", settings: map[string]string{"secret": "registry/regcred", "namespaceSelector": "team in ("}, wantErr: true},
+		{name: "unknown attachTo", settings: map[string]string{"secret": "registry/regcred", "attachTo": "deployment"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NamespaceImagePullSecret{}.ValidateConfig(config.PolicyConfig{Settings: tt.settings})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
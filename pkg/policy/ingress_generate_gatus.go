@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	ingressGenerateGatusLog = ctrl.Log.WithName("ingress_generate_gatus")
+)
+
+type IngressGenerateGatus struct {
+	Env *Env
+}
+
+func (i IngressGenerateGatus) Name() string {
+	return "Ingress Generate Gatus"
+}
+
+func (i IngressGenerateGatus) Type() int {
+	return PolicyTypeIngress
+}
+
+func (i IngressGenerateGatus) Validate(obj runtime.Object, env *Env) (error, bool) {
+	i.Env = env
+
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		return fmt.Errorf("could not cast object to Ingress"), false
+	}
+
+	return nil, validateGatus(env, i, ingress, gatusGenerateAnnotation, func(parser *annotation.Parser) (GatusEndpoint, error) {
+		return generateIngressGatusEndpoint(parser, ingress, Config(i))
+	})
+}
+
+func (i IngressGenerateGatus) Apply(obj runtime.Object, env *Env) error {
+	i.Env = env
+
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		ingressGenerateGatusLog.Error(fmt.Errorf("could not cast object to Ingress"), "error casting object to Ingress")
+		return nil
+	}
+
+	err := i.Handle(ingress, false)
+	return err
+}
+
+func (i IngressGenerateGatus) Handle(ingress *networkingv1.Ingress, fromValidate bool) error {
+	return handleGatusConfigMap(i.Env, Key(i), ingress, fromValidate, func(parser *annotation.Parser) (GatusEndpoint, error) {
+		return generateIngressGatusEndpoint(parser, ingress, Config(i))
+	})
+}
+
+func (i IngressGenerateGatus) Categories() []string {
+	return gatusCategories
+}
+
+func (i IngressGenerateGatus) Annotations() []annotation.Key {
+	return append([]annotation.Key{gatusGenerateAnnotation}, gatusAnnotations...)
+}
+
+// Dependencies are the TLS Secrets and backend Services of the Ingress, so that a
+// renewed certificate or a replaced backend reconciles it again.
+func (i IngressGenerateGatus) Dependencies(obj runtime.Object) []client.Object {
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		return nil
+	}
+
+	var dependencies []client.Object
+	seen := map[string]bool{}
+	add := func(dependency client.Object, kind string) {
+		key := kind + "/" + dependency.GetName()
+		if dependency.GetName() == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		dependencies = append(dependencies, dependency)
+	}
+	addBackend := func(backend *networkingv1.IngressBackend) {
+		if backend != nil && backend.Service != nil {
+			add(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: ingress.Namespace, Name: backend.Service.Name}}, "Service")
+		}
+	}
+
+	for _, tls := range ingress.Spec.TLS {
+		add(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ingress.Namespace, Name: tls.SecretName}}, "Secret")
+	}
+	addBackend(ingress.Spec.DefaultBackend)
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			addBackend(&path.Backend)
+		}
+	}
+	return dependencies
+}
+
+func (i IngressGenerateGatus) ValidateConfig(policyConfig config.PolicyConfig) error {
+	return validateGatusConfig(policyConfig)
+}
+
+func generateIngressGatusEndpoint(p *annotation.Parser, ingress *networkingv1.Ingress, policyConfig config.PolicyConfig) (GatusEndpoint, error) {
+	return generateGatusEndpoint(p, ingress, policyConfig, mutateGatusUrl(p, ingress, policyConfig))
+}
+
+func mutateGatusUrl(p *annotation.Parser, ingress *networkingv1.Ingress, policyConfig config.PolicyConfig) string {
+	var defaultHost, defaultPath string
+	if len(ingress.Spec.Rules) > 0 {
+		defaultHost = ingress.Spec.Rules[0].Host
+		if ingress.Spec.Rules[0].HTTP != nil && len(ingress.Spec.Rules[0].HTTP.Paths) > 0 {
+			defaultPath = ingress.Spec.Rules[0].HTTP.Paths[0].Path
+		}
+	}
+
+	protocol := p.String(gatusProtocolAnnotation, policyConfig.Setting("protocol", gatusDefaultProtocol))
+	host := p.String(gatusHostAnnotation, defaultHost)
+	path := p.String(gatusPathAnnotation, defaultPath)
+
+	return protocol + "://" + host + path
+}
+
+func init() {
+	RegisterPolicy(&IngressGenerateGatus{})
+}
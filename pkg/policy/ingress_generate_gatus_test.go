@@ -0,0 +1,187 @@
+package policy_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policytest"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestIngressGenerateGatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		namespace   map[string]string
+		annotations map[string]string
+		outcome     string
+		// configMap is namespace/name of the generated ConfigMap, empty if none.
+		configMap string
+		contains  []string
+		eventType string
+		event     string
+	}{
+		{
+			name:        "opted in",
+			annotations: map[string]string{"policy-control.aumer.io/gatus-generate": "true"},
+			outcome:     audit.OutcomeApplied,
+			configMap:   "web/site-ingress-gatus-generated",
+			contains:    []string{"name: site", "group: default", "url: https://site.example.com/", "- '[STATUS] == 200'"},
+		},
+		{
+			name:    "not opted in",
+			outcome: audit.OutcomeSkipped,
+		},
+		{
+			name: "annotations override the defaults",
+			annotations: map[string]string{
+				"policy-control.aumer.io/gatus-generate":   "yes",
+				"policy-control.aumer.io/gatus-name":       "storefront",
+				"policy-control.aumer.io/gatus-protocol":   "http",
+				"policy-control.aumer.io/gatus-path":       "/ready",
+				"policy-control.aumer.io/gatus-conditions": `[STATUS] == 200, [BODY].ok == true`,
+				"policy-control.aumer.io/gatus-dns":        "true",
+			},
+			outcome:   audit.OutcomeApplied,
+			configMap: "web/site-ingress-gatus-generated",
+			contains:  []string{"name: storefront", "url: http://site.example.com/ready", "- '[BODY].ok == true'", "dns-resolver: tcp://1.1.1.1:53"},
+		},
+		{
+			name:        "empty conditions fall back to the default",
+			annotations: map[string]string{"policy-control.aumer.io/gatus-generate": "true", "policy-control.aumer.io/gatus-conditions": ""},
+			outcome:     audit.OutcomeApplied,
+			configMap:   "web/site-ingress-gatus-generated",
+			contains:    []string{"- '[STATUS] == 200'"},
+		},
+		{
+			name:      "inherited from the namespace",
+			namespace: map[string]string{"policy-control.aumer.io/gatus-generate": "true", "policy-control.aumer.io/gatus-group": "shop"},
+			outcome:   audit.OutcomeApplied,
+			configMap: "web/site-ingress-gatus-generated",
+			contains:  []string{"group: shop"},
+			eventType: corev1.EventTypeNormal,
+			event:     "InheritedAnnotations",
+		},
+		{
+			name: "output namespace",
+			config: `
+policies:
+  ingress-generate-gatus:
+    outputNamespace: monitoring
+    settings:
+      interval: 5m
+`,
+			annotations: map[string]string{"policy-control.aumer.io/gatus-generate": "true"},
+			outcome:     audit.OutcomeApplied,
+			configMap:   "monitoring/web-site-ingress-gatus-generated",
+			contains:    []string{"interval: 5m"},
+		},
+		{
+			name: "audit mode",
+			config: `
+policies:
+  ingress-generate-gatus:
+    mode: audit
+`,
+			annotations: map[string]string{"policy-control.aumer.io/gatus-generate": "true"},
+			outcome:     audit.OutcomeAudited,
+		},
+		{
+			name:        "invalid annotation",
+			annotations: map[string]string{"policy-control.aumer.io/gatus-generate": "true", "policy-control.aumer.io/gatus-protocol": "ftp"},
+			outcome:     audit.OutcomeSkipped,
+			eventType:   corev1.EventTypeWarning,
+			event:       "InvalidAnnotation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policytest.SetConfig(t, tt.config)
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web", Annotations: tt.namespace}}
+			h := policytest.New(t, namespace)
+			ingress := policytest.Ingress("web", "site", "site", "site-tls")
+			ingress.Annotations = tt.annotations
+			result := h.Run(t, policy.IngressGenerateGatus{}, ingress)
+
+			result.AssertNoError(t)
+			result.AssertNoPatch(t)
+			result.AssertOutcome(t, "ingress-generate-gatus", tt.outcome)
+			if tt.event != "" {
+				result.AssertEvent(t, tt.eventType, tt.event)
+			}
+
+			if tt.configMap == "" {
+				if len(result.Generated) > 0 {
+					t.Errorf("expected nothing to be generated, got %d objects", len(result.Generated))
+				}
+				return
+			}
+			namespaceName, name, _ := strings.Cut(tt.configMap, "/")
+			generated := result.AssertGenerated(t, "ConfigMap", namespaceName, name)
+			configMap, ok := generated.(*corev1.ConfigMap)
+			if !ok {
+				return
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(configMap.Data["config.yaml"], want) {
+					t.Errorf("config.yaml doesn't contain %q:\n%s", want, configMap.Data["config.yaml"])
+				}
+			}
+		})
+	}
+}
+
+func TestIngressGenerateGatusRemovesConfigMap(t *testing.T) {
+	policytest.SetConfig(t, "")
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	ctx := context.Background()
+	h := policytest.New(t)
+	ingress := policytest.Ingress("web", "site", "site", "site-tls")
+	ingress.Annotations = map[string]string{"policy-control.aumer.io/gatus-generate": "true"}
+	h.Run(t, policy.IngressGenerateGatus{}, ingress).AssertGenerated(t, "ConfigMap", "web", "site-ingress-gatus-generated")
+
+	// Update the stored Ingress, it carries the UID the ConfigMap points at.
+	if err := h.Store.Get(ctx, client.ObjectKeyFromObject(ingress), ingress); err != nil {
+		t.Fatal(err)
+	}
+	ingress.Annotations["policy-control.aumer.io/gatus-generate"] = "false"
+	if err := h.Store.Update(ctx, ingress); err != nil {
+		t.Fatal(err)
+	}
+	h.Run(t, policy.IngressGenerateGatus{}, ingress).AssertOutcome(t, "ingress-generate-gatus", audit.OutcomeSkipped)
+
+	if err := h.Store.Get(ctx, client.ObjectKey{Namespace: "web", Name: "site-ingress-gatus-generated"}, &corev1.ConfigMap{}); err == nil {
+		t.Error("expected the ConfigMap to be removed")
+	}
+}
+
+func TestIngressGenerateGatusDependencies(t *testing.T) {
+	ingress := policytest.Ingress("web", "site", "site", "site-tls")
+	ingress.Spec.TLS = append(ingress.Spec.TLS, networkingv1.IngressTLS{SecretName: "site-tls"})
+	ingress.Spec.DefaultBackend = &networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "fallback"}}
+
+	var got []string
+	for _, dependency := range (policy.IngressGenerateGatus{}).Dependencies(ingress) {
+		kind := "Service"
+		if _, ok := dependency.(*corev1.Secret); ok {
+			kind = "Secret"
+		}
+		got = append(got, kind+" "+client.ObjectKeyFromObject(dependency).String())
+	}
+
+	want := []string{"Secret web/site-tls", "Service web/fallback", "Service web/site"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Dependencies() = %v, want %v", got, want)
+	}
+}
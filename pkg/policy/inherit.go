@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxOwnerDepth bounds the walk up the controller chain, Pod to ReplicaSet to
+// Deployment is two steps.
+const maxOwnerDepth = 4
+
+// annotationParser resolves the annotations of obj through its controlling
+// owners, nearest first, then its Namespace and then the cluster defaults of the
+// config. Levels that can't be read are left out.
+func annotationParser(env *Env, obj client.Object) *annotation.Parser {
+	layers := []annotation.Layer{{Level: annotation.LevelObject, Annotations: obj.GetAnnotations()}}
+
+	if env != nil && env.Client != nil {
+		layers = append(layers, ownerLayers(env, obj)...)
+
+		if obj.GetNamespace() != "" {
+			namespace := &corev1.Namespace{}
+			err := env.Client.Get(context.Background(), client.ObjectKey{Name: obj.GetNamespace()}, namespace)
+			if err == nil {
+				layers = append(layers, annotation.Layer{
+					Level:       annotation.LevelNamespace,
+					Source:      "Namespace/" + namespace.Name,
+					Annotations: namespace.Annotations,
+				})
+			} else if !apierrors.IsNotFound(err) {
+				policyLog.Error(err, "unable to read Namespace annotations", "namespace", obj.GetNamespace())
+			}
+		}
+	}
+
+	layers = append(layers, annotation.ClusterLayer())
+	return annotation.NewLayeredParser(layers...)
+}
+
+func ownerLayers(env *Env, obj client.Object) []annotation.Layer {
+	var layers []annotation.Layer
+
+	current := obj
+	for i := 0; i < maxOwnerDepth; i++ {
+		ref := metav1.GetControllerOf(current)
+		if ref == nil {
+			break
+		}
+
+		owner, err := getOwner(env, current.GetNamespace(), ref)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				policyLog.Error(err, "unable to read owner annotations", "object", client.ObjectKeyFromObject(current), "owner", ref.Kind+"/"+ref.Name)
+			}
+			break
+		}
+
+		layers = append(layers, annotation.Layer{
+			Level:       annotation.LevelOwner,
+			Source:      ref.Kind + "/" + ref.Name,
+			Annotations: owner.GetAnnotations(),
+		})
+		current = owner
+	}
+	return layers
+}
+
+// getOwner reads the object ref points at. Kinds the scheme doesn't know are
+// read as unstructured.
+func getOwner(env *Env, namespace string, ref *metav1.OwnerReference) (client.Object, error) {
+	gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+
+	var owner client.Object
+	if typed, err := env.Client.Scheme().New(gvk); err == nil {
+		owner = typed.(client.Object)
+	} else {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		owner = u
+	}
+
+	namespaced, err := env.Client.IsObjectNamespaced(owner)
+	if err != nil {
+		return nil, err
+	}
+	key := client.ObjectKey{Name: ref.Name}
+	if namespaced {
+		key.Namespace = namespace
+	}
+
+	if err := env.Client.Get(context.Background(), key, owner); err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// getNamespace reads the Namespace called name. A Namespace that doesn't exist,
+// as when evaluating manifests without it, is returned with only its name set.
+func getNamespace(env *Env, name string) (*corev1.Namespace, error) {
+	namespace := &corev1.Namespace{}
+	if err := env.Client.Get(context.Background(), client.ObjectKey{Name: name}, namespace); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		namespace.Name = name
+	}
+	return namespace, nil
+}
+
+// formatInherited lists inherited values as "key from origin", sorted by key.
+func formatInherited(inherited map[string]string) string {
+	keys := make([]string, 0, len(inherited))
+	for key := range inherited {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+" from "+inherited[key])
+	}
+	return strings.Join(parts, ", ")
+}
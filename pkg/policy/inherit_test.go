@@ -0,0 +1,110 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func controllerRef(kind string, name string, uid types.UID) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: kind, Name: name, UID: uid, Controller: &controller}}
+}
+
+func TestAnnotationParserLayers(t *testing.T) {
+	cfg := config.Default()
+	cfg.AnnotationDefaults = map[string]string{
+		"test-object":     "cluster",
+		"test-owner":      "cluster",
+		"test-deployment": "cluster",
+		"test-namespace":  "cluster",
+		"test-cluster":    "cluster",
+		"test-local":      "cluster",
+	}
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	key := func(name string) string { return cfg.AnnotationKey(name) }
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "web", Name: "site", UID: "deployment-uid",
+		Annotations: map[string]string{key("test-object"): "deployment", key("test-owner"): "deployment", key("test-deployment"): "deployment", key("test-local"): "deployment"},
+	}}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "web", Name: "site-6d4b", UID: "replicaset-uid",
+		OwnerReferences: controllerRef("Deployment", "site", "deployment-uid"),
+		Annotations:     map[string]string{key("test-object"): "replicaset", key("test-owner"): "replicaset"},
+	}}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "web",
+		Annotations: map[string]string{key("test-object"): "namespace", key("test-owner"): "namespace", key("test-namespace"): "namespace"},
+	}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "web", Name: "site-6d4b-x2x9z",
+		OwnerReferences: controllerRef("ReplicaSet", "site-6d4b", "replicaset-uid"),
+		Annotations:     map[string]string{key("test-object"): "pod"},
+	}}
+
+	parser := annotationParser(newTestEnv(deployment, replicaSet, namespace), pod)
+
+	tests := []struct {
+		name       string
+		inherit    bool
+		want       string
+		wantOrigin string
+	}{
+		{name: "test-object", inherit: true, want: "pod", wantOrigin: "object"},
+		{name: "test-owner", inherit: true, want: "replicaset", wantOrigin: "owner ReplicaSet/site-6d4b"},
+		{name: "test-deployment", inherit: true, want: "deployment", wantOrigin: "owner Deployment/site"},
+		{name: "test-namespace", inherit: true, want: "namespace", wantOrigin: "namespace Namespace/web"},
+		{name: "test-cluster", inherit: true, want: "cluster", wantOrigin: "cluster config"},
+		{name: "test-local", want: "unset"},
+		{name: "test-missing", inherit: true, want: "unset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := annotation.Key{Name: tt.name, Type: annotation.TypeString, Inherit: tt.inherit}
+			if got := parser.String(k, "unset"); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			origin, ok := parser.Origin(k)
+			if tt.wantOrigin == "" {
+				if ok {
+					t.Errorf("Origin() = %s, want none", origin)
+				}
+				return
+			}
+			if origin.String() != tt.wantOrigin {
+				t.Errorf("Origin() = %s, want %s", origin, tt.wantOrigin)
+			}
+		})
+	}
+
+	if inherited := parser.Inherited(); len(inherited) != 4 || inherited[key("test-object")] != "" {
+		t.Errorf("Inherited() = %v, want the four values not set on the Pod", inherited)
+	}
+}
+
+func TestAnnotationParserWithoutNamespaceOrOwners(t *testing.T) {
+	config.Set(config.Default())
+
+	k := annotation.Key{Name: "test-inherit", Type: annotation.TypeString, Inherit: true}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "missing", Name: "web",
+		OwnerReferences: controllerRef("ReplicaSet", "gone", "gone-uid"),
+	}}
+
+	parser := annotationParser(newTestEnv(), pod)
+	if got := parser.String(k, "fallback"); got != "fallback" {
+		t.Errorf("String() = %q, want fallback", got)
+	}
+	if len(parser.Inherited()) != 0 {
+		t.Errorf("Inherited() = %v, want none", parser.Inherited())
+	}
+}
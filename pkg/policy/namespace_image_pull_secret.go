@@ -0,0 +1,232 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/util"
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NamespaceImagePullSecret keeps a copy of the Secret named by the secret
+// setting, as namespace/name, in every Namespace the namespaceSelector setting
+// or the image-pull-secret annotation selects. Copies are labelled as managed,
+// updated when the source changes and removed when their Namespace is no longer
+// selected. A Secret of the same name the controller didn't create is left
+// alone.
+//
+// The attachTo setting adds the copy to the imagePullSecrets of the default
+// ServiceAccount (serviceAccount, the default), of every Pod through the Pod
+// Image Pull Secret policy (pod), or nowhere (none).
+type NamespaceImagePullSecret struct{}
+
+func (n NamespaceImagePullSecret) Name() string {
+	return "Namespace Image Pull Secret"
+}
+
+func (n NamespaceImagePullSecret) Type() int {
+	return PolicyTypeNamespace
+}
+
+func (n NamespaceImagePullSecret) Validate(obj runtime.Object, env *Env) (error, bool) {
+	namespace, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return fmt.Errorf("could not cast object to Namespace"), false
+	}
+
+	settings, configured, err := imagePullSecretSettings(Config(n))
+	if err != nil || !configured || !namespace.DeletionTimestamp.IsZero() {
+		return err, false
+	}
+
+	selected, err := settings.selects(namespace)
+	if err != nil {
+		warnInvalidAnnotations(env, n, namespace, err)
+		return nil, false
+	}
+	if !selected {
+		if err := removeImagePullSecretCopy(env, settings, namespace); err != nil {
+			imagePullSecretLog.Error(err, "error removing image pull Secret", "namespace", namespace.Name)
+		}
+		return nil, false
+	}
+	return nil, true
+}
+
+func (n NamespaceImagePullSecret) Apply(obj runtime.Object, env *Env) error {
+	namespace, ok := obj.(*corev1.Namespace)
+	if !ok {
+		imagePullSecretLog.Error(fmt.Errorf("could not cast object to Namespace"), "error casting object to Namespace")
+		return nil
+	}
+
+	settings, _, err := imagePullSecretSettings(Config(n))
+	if err != nil {
+		return err
+	}
+
+	if err := syncImagePullSecretCopy(env, settings, namespace); err != nil {
+		return err
+	}
+	if settings.attachTo == attachToServiceAccount {
+		return updateDefaultServiceAccount(env, namespace.Name, func(refs []corev1.LocalObjectReference) ([]corev1.LocalObjectReference, bool) {
+			return addImagePullSecret(refs, settings.name)
+		})
+	}
+	return nil
+}
+
+func (n NamespaceImagePullSecret) Annotations() []annotation.Key {
+	return []annotation.Key{imagePullSecretAnnotation}
+}
+
+func (n NamespaceImagePullSecret) ValidateConfig(policyConfig config.PolicyConfig) error {
+	_, _, err := imagePullSecretSettings(policyConfig)
+	return err
+}
+
+// WatchedFields reconciles Namespaces whose labels change, they may move in or
+// out of the namespaceSelector.
+func (n NamespaceImagePullSecret) WatchedFields() []string {
+	return []string{"metadata.labels"}
+}
+
+func (n NamespaceImagePullSecret) Sources() []client.Object {
+	settings, configured, err := imagePullSecretSettings(Config(n))
+	if err != nil || !configured {
+		return nil
+	}
+	return []client.Object{&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: settings.source.Namespace, Name: settings.source.Name}}}
+}
+
+// syncImagePullSecretCopy creates or updates the copy of the source Secret in
+// namespace.
+func syncImagePullSecretCopy(env *Env, settings imagePullSecretConfig, namespace *corev1.Namespace) error {
+	ctx := context.Background()
+
+	source := &corev1.Secret{}
+	if err := env.Client.Get(ctx, settings.source, source); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("source Secret %s not found", settings.source)
+		}
+		return err
+	}
+
+	existing := &corev1.Secret{}
+	err := env.Client.Get(ctx, client.ObjectKey{Namespace: namespace.Name, Name: settings.name}, existing)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if apierrors.IsNotFound(err) {
+		imagePullSecretLog.Info("Creating image pull Secret", "namespace", namespace.Name, "secret", settings.name)
+		return env.Client.Create(ctx, newImagePullSecretCopy(env, settings, source, namespace))
+	}
+
+	if !managedImagePullSecretCopy(existing, namespace) {
+		env.Warningf(namespace, "ImagePullSecretConflict", "Secret %s exists and isn't managed by the controller, not replacing it", settings.name)
+		return fmt.Errorf("Secret %s/%s exists and isn't managed by the controller", namespace.Name, settings.name)
+	}
+
+	// The type of a Secret can't be changed, so the copy is recreated.
+	if existing.Type != source.Type {
+		imagePullSecretLog.Info("Recreating image pull Secret with new type", "namespace", namespace.Name, "secret", settings.name, "type", source.Type)
+		if err := env.Client.Delete(ctx, existing); err != nil {
+			return err
+		}
+		return env.Client.Create(ctx, newImagePullSecretCopy(env, settings, source, namespace))
+	}
+
+	wanted := newImagePullSecretCopy(env, settings, source, namespace)
+	if reflect.DeepEqual(existing.Data, wanted.Data) && reflect.DeepEqual(existing.Labels, wanted.Labels) && reflect.DeepEqual(existing.Annotations, wanted.Annotations) {
+		return nil
+	}
+	imagePullSecretLog.Info("Updating image pull Secret", "namespace", namespace.Name, "secret", settings.name)
+	existing.Labels = wanted.Labels
+	existing.Annotations = wanted.Annotations
+	existing.Data = wanted.Data
+	return env.Client.Update(ctx, existing)
+}
+
+// removeImagePullSecretCopy deletes the managed copy in namespace and takes it
+// off the default ServiceAccount.
+func removeImagePullSecretCopy(env *Env, settings imagePullSecretConfig, namespace *corev1.Namespace) error {
+	ctx := context.Background()
+
+	existing := &corev1.Secret{}
+	if err := env.Client.Get(ctx, client.ObjectKey{Namespace: namespace.Name, Name: settings.name}, existing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !managedImagePullSecretCopy(existing, namespace) {
+		return nil
+	}
+
+	imagePullSecretLog.Info("Removing image pull Secret because Namespace is no longer selected", "namespace", namespace.Name, "secret", settings.name)
+	if err := env.Client.Delete(ctx, existing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	return updateDefaultServiceAccount(env, namespace.Name, func(refs []corev1.LocalObjectReference) ([]corev1.LocalObjectReference, bool) {
+		return removeImagePullSecret(refs, settings.name)
+	})
+}
+
+func newImagePullSecretCopy(env *Env, settings imagePullSecretConfig, source *corev1.Secret, namespace *corev1.Namespace) *corev1.Secret {
+	cfg := config.Current()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      settings.name,
+			Namespace: namespace.Name,
+			Labels: map[string]string{
+				cfg.Labels.ManagedBy: cfg.Labels.ManagedByValue,
+				cfg.Labels.ParentUid: string(namespace.UID),
+			},
+		},
+		Type: source.Type,
+		Data: source.Data,
+	}
+
+	// Edits to or deletion of the copy reconcile its Namespace, which reverts
+	// them.
+	trigger, err := util.NewControllerTrigger(namespace, env.Client.Scheme())
+	if err != nil {
+		imagePullSecretLog.Error(err, "error resolving controller trigger", "namespace", namespace.Name)
+		return secret
+	}
+	util.SetControllerTrigger(secret, trigger)
+	return secret
+}
+
+func managedImagePullSecretCopy(secret *corev1.Secret, namespace *corev1.Namespace) bool {
+	labels := config.Current().Labels
+	return secret.Labels[labels.ManagedBy] == labels.ManagedByValue && secret.Labels[labels.ParentUid] == string(namespace.UID)
+}
+
+// updateDefaultServiceAccount changes the imagePullSecrets of the default
+// ServiceAccount of namespace with update. A missing ServiceAccount is skipped,
+// its creation reconciles the Namespace again.
+func updateDefaultServiceAccount(env *Env, namespace string, update func([]corev1.LocalObjectReference) ([]corev1.LocalObjectReference, bool)) error {
+	ctx := context.Background()
+
+	serviceAccount := &corev1.ServiceAccount{}
+	if err := env.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: defaultServiceAccountName}, serviceAccount); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	refs, changed := update(serviceAccount.ImagePullSecrets)
+	if !changed {
+		return nil
+	}
+	imagePullSecretLog.Info("Updating imagePullSecrets of default ServiceAccount", "namespace", namespace)
+	serviceAccount.ImagePullSecrets = refs
+	return env.Client.Update(ctx, serviceAccount)
+}
+
+func init() {
+	RegisterPolicy(&NamespaceImagePullSecret{})
+}
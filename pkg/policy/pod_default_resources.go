@@ -0,0 +1,317 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var (
+	defaultCpuRequestAnnotation = annotation.Key{
+		Name:        "default-cpu-request",
+		Inherit:     true,
+		Type:        annotation.TypeQuantity,
+		Description: "CPU request for containers that don't set one, defaults to the cpuRequest setting of the policy.",
+	}
+	defaultMemoryRequestAnnotation = annotation.Key{
+		Name:        "default-memory-request",
+		Inherit:     true,
+		Type:        annotation.TypeQuantity,
+		Description: "Memory request for containers that don't set one, defaults to the memoryRequest setting of the policy.",
+	}
+	defaultEphemeralStorageRequestAnnotation = annotation.Key{
+		Name:        "default-ephemeral-storage-request",
+		Inherit:     true,
+		Type:        annotation.TypeQuantity,
+		Description: "Ephemeral storage request for containers that don't set one, defaults to the ephemeralStorageRequest setting of the policy.",
+	}
+	defaultMemoryLimitAnnotation = annotation.Key{
+		Name:        "default-memory-limit",
+		Inherit:     true,
+		Type:        annotation.TypeQuantity,
+		Description: "Memory limit for containers that don't set one and have no memory request to apply the ratio to, defaults to the memoryLimit setting of the policy.",
+	}
+	defaultMemoryLimitRatioAnnotation = annotation.Key{
+		Name:        "default-memory-limit-ratio",
+		Inherit:     true,
+		Type:        annotation.TypeFloat,
+		Description: "Memory limit for containers that don't set one, as a multiple of the memory request, e.g. 1.5. Defaults to the memoryLimitRatio setting of the policy.",
+	}
+	defaultResourcesExemptAnnotation = annotation.Key{
+		Name:        "default-resources-exempt",
+		Inherit:     true,
+		Type:        annotation.TypeList,
+		Description: "Comma separated names of containers, such as sidecars, that are left as they are. Defaults to the exemptContainers setting of the policy.",
+	}
+	keepLimitAnnotation = annotation.Key{
+		Name:        "keep-limit",
+		Inherit:     true,
+		Type:        annotation.TypeBool,
+		Default:     "false",
+		Description: "Leave the limits of the Pod as they are written, no memory limit is added. Requests are still defaulted.",
+	}
+	podDefaultResourcesLog = ctrl.Log.WithName("pod_default_resources")
+)
+
+// resourceCategories select every policy handling Pod resources with the
+// policies annotation.
+var resourceCategories = []string{"resources"}
+
+// PodDefaultResources fills in missing CPU, memory and ephemeral storage
+// requests, and optionally memory limits, when a Pod is created. Unlike a
+// LimitRange the defaults can be set per Namespace or workload through
+// annotations, sidecars can be exempted by name, and the memory limit can follow
+// the request.
+type PodDefaultResources struct{}
+
+// podResourceDefaults is what PodDefaultResources applies to a single Pod.
+type podResourceDefaults struct {
+	requests         corev1.ResourceList
+	memoryLimit      *resource.Quantity
+	memoryLimitRatio float64
+	exempt           map[string]bool
+	keepLimit        bool
+}
+
+func (p PodDefaultResources) Name() string {
+	return "Pod Default Resources"
+}
+
+func (p PodDefaultResources) Type() int {
+	return PolicyTypePod
+}
+
+func (p PodDefaultResources) Admission() bool {
+	return true
+}
+
+func (p PodDefaultResources) Phase() Phase {
+	return PhaseDefault
+}
+
+func (p PodDefaultResources) Validate(obj runtime.Object, env *Env) (error, bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("could not cast object to Pod"), false
+	}
+	// Container resources can't be changed once the Pod exists.
+	if !env.creating() {
+		return nil, false
+	}
+
+	defaults, err := resolvePodResourceDefaults(annotationParser(env, pod), Config(p))
+	if err != nil {
+		warnInvalidAnnotations(env, p, pod, err)
+		return nil, false
+	}
+	return nil, defaults.apply(pod.DeepCopy())
+}
+
+func (p PodDefaultResources) Apply(obj runtime.Object, env *Env) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		podDefaultResourcesLog.Error(fmt.Errorf("could not cast object to Pod"), "error casting object to Pod")
+		return nil
+	}
+
+	defaults, err := resolvePodResourceDefaults(annotationParser(env, pod), Config(p))
+	if err != nil {
+		return err
+	}
+	if defaults.apply(pod) {
+		podDefaultResourcesLog.Info("Defaulted container resources", "namespace", pod.Namespace, "pod", getObjectName(pod))
+	}
+	return nil
+}
+
+func (p PodDefaultResources) Categories() []string {
+	return resourceCategories
+}
+
+func (p PodDefaultResources) Annotations() []annotation.Key {
+	return []annotation.Key{
+		defaultCpuRequestAnnotation,
+		defaultMemoryRequestAnnotation,
+		defaultEphemeralStorageRequestAnnotation,
+		defaultMemoryLimitAnnotation,
+		defaultMemoryLimitRatioAnnotation,
+		defaultResourcesExemptAnnotation,
+		keepLimitAnnotation,
+	}
+}
+
+func (p PodDefaultResources) ValidateConfig(policyConfig config.PolicyConfig) error {
+	for _, setting := range []string{"cpuRequest", "memoryRequest", "ephemeralStorageRequest", "memoryLimit"} {
+		if _, err := quantitySetting(policyConfig, setting); err != nil {
+			return err
+		}
+	}
+	if _, err := ratioSetting(policyConfig, "memoryLimitRatio"); err != nil {
+		return err
+	}
+	if exempt := policyConfig.Setting("exemptContainers", ""); exempt != "" {
+		if _, err := annotation.SplitList(exempt); err != nil {
+			return fmt.Errorf("settings.exemptContainers: %w", err)
+		}
+	}
+	return nil
+}
+
+// resolvePodResourceDefaults reads the defaults from the annotations, falling
+// back to the policy settings for those that aren't set anywhere.
+func resolvePodResourceDefaults(parser *annotation.Parser, policyConfig config.PolicyConfig) (podResourceDefaults, error) {
+	defaults := podResourceDefaults{
+		requests: corev1.ResourceList{},
+		exempt:   map[string]bool{},
+	}
+
+	requests := map[corev1.ResourceName]struct {
+		key     annotation.Key
+		setting string
+	}{
+		corev1.ResourceCPU:              {defaultCpuRequestAnnotation, "cpuRequest"},
+		corev1.ResourceMemory:           {defaultMemoryRequestAnnotation, "memoryRequest"},
+		corev1.ResourceEphemeralStorage: {defaultEphemeralStorageRequestAnnotation, "ephemeralStorageRequest"},
+	}
+	for name, request := range requests {
+		fallback, _ := quantitySetting(policyConfig, request.setting)
+		if q := parser.Quantity(request.key, fallback); !q.IsZero() {
+			defaults.requests[name] = q
+		}
+	}
+
+	limitFallback, _ := quantitySetting(policyConfig, "memoryLimit")
+	if limit := parser.Quantity(defaultMemoryLimitAnnotation, limitFallback); !limit.IsZero() {
+		defaults.memoryLimit = &limit
+	}
+
+	ratioFallback, _ := ratioSetting(policyConfig, "memoryLimitRatio")
+	defaults.memoryLimitRatio = parser.Float(defaultMemoryLimitRatioAnnotation, ratioFallback)
+
+	exempt := parser.List(defaultResourcesExemptAnnotation)
+	if _, set := parser.Origin(defaultResourcesExemptAnnotation); !set {
+		exempt, _ = annotation.SplitList(policyConfig.Setting("exemptContainers", ""))
+	}
+	for _, name := range exempt {
+		defaults.exempt[name] = true
+	}
+
+	defaults.keepLimit, _ = parser.Bool(keepLimitAnnotation)
+
+	if err := parser.Err(); err != nil {
+		return defaults, err
+	}
+	if defaults.memoryLimitRatio != 0 && defaults.memoryLimitRatio < 1 {
+		return defaults, fmt.Errorf("annotation %s: the memory limit can't be below the request, got ratio %v", defaultMemoryLimitRatioAnnotation.FullName(), defaults.memoryLimitRatio)
+	}
+	return defaults, nil
+}
+
+// apply defaults the resources of every container that isn't exempt, and
+// reports whether anything changed.
+func (d podResourceDefaults) apply(pod *corev1.Pod) bool {
+	changed := false
+	for i := range pod.Spec.InitContainers {
+		changed = d.applyContainer(&pod.Spec.InitContainers[i]) || changed
+	}
+	for i := range pod.Spec.Containers {
+		changed = d.applyContainer(&pod.Spec.Containers[i]) || changed
+	}
+	return changed
+}
+
+func (d podResourceDefaults) applyContainer(container *corev1.Container) bool {
+	if d.exempt[container.Name] {
+		return false
+	}
+	resources := &container.Resources
+
+	changed := false
+	for name, q := range d.requests {
+		if _, ok := resources.Requests[name]; ok {
+			continue
+		}
+		// The API server defaults a missing request to the limit.
+		if _, ok := resources.Limits[name]; ok {
+			continue
+		}
+		if resources.Requests == nil {
+			resources.Requests = corev1.ResourceList{}
+		}
+		resources.Requests[name] = q.DeepCopy()
+		changed = true
+	}
+
+	if d.keepLimit {
+		return changed
+	}
+	if _, ok := resources.Limits[corev1.ResourceMemory]; ok {
+		return changed
+	}
+	limit, ok := d.memoryLimitFor(resources)
+	if !ok {
+		return changed
+	}
+	if resources.Limits == nil {
+		resources.Limits = corev1.ResourceList{}
+	}
+	resources.Limits[corev1.ResourceMemory] = limit
+	return true
+}
+
+// memoryLimitFor returns the memory limit for a container. The ratio applies to
+// containers with a memory request, the fixed limit to all others. A limit
+// below the request is never returned.
+func (d podResourceDefaults) memoryLimitFor(resources *corev1.ResourceRequirements) (resource.Quantity, bool) {
+	request, hasRequest := resources.Requests[corev1.ResourceMemory]
+
+	var limit resource.Quantity
+	switch {
+	case hasRequest && d.memoryLimitRatio != 0:
+		limit = *resource.NewQuantity(int64(math.Ceil(float64(request.Value())*d.memoryLimitRatio)), resource.BinarySI)
+	case d.memoryLimit != nil:
+		limit = d.memoryLimit.DeepCopy()
+	default:
+		return resource.Quantity{}, false
+	}
+
+	if hasRequest && limit.Cmp(request) < 0 {
+		return resource.Quantity{}, false
+	}
+	return limit, true
+}
+
+func quantitySetting(policyConfig config.PolicyConfig, setting string) (resource.Quantity, error) {
+	value := policyConfig.Setting(setting, "")
+	if value == "" {
+		return resource.Quantity{}, nil
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("settings.%s: expected a quantity such as 100m or 1Gi, got %q", setting, value)
+	}
+	return q, nil
+}
+
+func ratioSetting(policyConfig config.PolicyConfig, setting string) (float64, error) {
+	value := policyConfig.Setting(setting, "")
+	if value == "" {
+		return 0, nil
+	}
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil || ratio < 1 {
+		return 0, fmt.Errorf("settings.%s: expected a number of at least 1, got %q", setting, value)
+	}
+	return ratio, nil
+}
+
+func init() {
+	RegisterPolicy(&PodDefaultResources{})
+}
@@ -0,0 +1,189 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// resources builds the requests or limits of a container from resource name
+// and quantity pairs.
+func resources(pairs ...string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	for i := 0; i < len(pairs); i += 2 {
+		list[corev1.ResourceName(pairs[i])] = resource.MustParse(pairs[i+1])
+	}
+	return list
+}
+
+func TestPodDefaultResources(t *testing.T) {
+	tests := []struct {
+		name                 string
+		settings             map[string]string
+		namespaceAnnotations map[string]string
+		annotations          map[string]string
+		operation            string
+		containers           []corev1.Container
+		wantOutcome          string
+		want                 map[string]corev1.ResourceRequirements
+	}{
+		{
+			name:        "nothing configured",
+			containers:  []corev1.Container{{Name: "web"}},
+			wantOutcome: audit.OutcomeSkipped,
+			want:        map[string]corev1.ResourceRequirements{"web": {}},
+		},
+		{
+			name:        "requests from settings",
+			settings:    map[string]string{"cpuRequest": "100m", "memoryRequest": "128Mi"},
+			containers:  []corev1.Container{{Name: "web"}},
+			wantOutcome: audit.OutcomeApplied,
+			want:        map[string]corev1.ResourceRequirements{"web": {Requests: resources("cpu", "100m", "memory", "128Mi")}},
+		},
+		{
+			name:       "existing requests and limits are kept",
+			settings:   map[string]string{"cpuRequest": "100m", "memoryRequest": "128Mi"},
+			containers: []corev1.Container{{Name: "web", Resources: corev1.ResourceRequirements{Requests: resources("memory", "1Gi"), Limits: resources("cpu", "2")}}},
+			// The API server defaults the CPU request to the limit.
+			wantOutcome: audit.OutcomeSkipped,
+			want:        map[string]corev1.ResourceRequirements{"web": {Requests: resources("memory", "1Gi"), Limits: resources("cpu", "2")}},
+		},
+		{
+			name:        "memory limit ratio of the defaulted request",
+			settings:    map[string]string{"memoryRequest": "128Mi", "memoryLimitRatio": "1.5"},
+			containers:  []corev1.Container{{Name: "web"}},
+			wantOutcome: audit.OutcomeApplied,
+			want:        map[string]corev1.ResourceRequirements{"web": {Requests: resources("memory", "128Mi"), Limits: resources("memory", "192Mi")}},
+		},
+		{
+			name:        "memory limit ratio of an existing request",
+			settings:    map[string]string{"memoryLimitRatio": "2"},
+			containers:  []corev1.Container{{Name: "web", Resources: corev1.ResourceRequirements{Requests: resources("memory", "200Mi")}}},
+			wantOutcome: audit.OutcomeApplied,
+			want:        map[string]corev1.ResourceRequirements{"web": {Requests: resources("memory", "200Mi"), Limits: resources("memory", "400Mi")}},
+		},
+		{
+			name:        "ratio takes precedence over the fixed limit",
+			settings:    map[string]string{"memoryLimitRatio": "2", "memoryLimit": "1Gi"},
+			containers:  []corev1.Container{{Name: "web", Resources: corev1.ResourceRequirements{Requests: resources("memory", "100Mi")}}, {Name: "worker"}},
+			wantOutcome: audit.OutcomeApplied,
+			want: map[string]corev1.ResourceRequirements{
+				"web":    {Requests: resources("memory", "100Mi"), Limits: resources("memory", "200Mi")},
+				"worker": {Limits: resources("memory", "1Gi")},
+			},
+		},
+		{
+			name:        "fixed limit below the request is not added",
+			settings:    map[string]string{"memoryLimit": "64Mi"},
+			containers:  []corev1.Container{{Name: "web", Resources: corev1.ResourceRequirements{Requests: resources("memory", "128Mi")}}},
+			wantOutcome: audit.OutcomeSkipped,
+			want:        map[string]corev1.ResourceRequirements{"web": {Requests: resources("memory", "128Mi")}},
+		},
+		{
+			name:        "annotation ratio below one",
+			annotations: map[string]string{"policy-control.aumer.io/default-memory-limit-ratio": "0.5"},
+			containers:  []corev1.Container{{Name: "web", Resources: corev1.ResourceRequirements{Requests: resources("memory", "128Mi")}}},
+			wantOutcome: audit.OutcomeSkipped,
+			want:        map[string]corev1.ResourceRequirements{"web": {Requests: resources("memory", "128Mi")}},
+		},
+		{
+			name:        "sidecar exempt by setting",
+			settings:    map[string]string{"memoryRequest": "128Mi", "exemptContainers": "istio-proxy"},
+			containers:  []corev1.Container{{Name: "web"}, {Name: "istio-proxy"}},
+			wantOutcome: audit.OutcomeApplied,
+			want: map[string]corev1.ResourceRequirements{
+				"web":         {Requests: resources("memory", "128Mi")},
+				"istio-proxy": {},
+			},
+		},
+		{
+			name:        "sidecar exempt by annotation replaces the setting",
+			settings:    map[string]string{"memoryRequest": "128Mi", "exemptContainers": "istio-proxy"},
+			annotations: map[string]string{"policy-control.aumer.io/default-resources-exempt": "log-shipper"},
+			containers:  []corev1.Container{{Name: "istio-proxy"}, {Name: "log-shipper"}},
+			wantOutcome: audit.OutcomeApplied,
+			want: map[string]corev1.ResourceRequirements{
+				"istio-proxy": {Requests: resources("memory", "128Mi")},
+				"log-shipper": {},
+			},
+		},
+		{
+			name:        "keep-limit",
+			settings:    map[string]string{"memoryRequest": "128Mi", "memoryLimitRatio": "2"},
+			annotations: map[string]string{"policy-control.aumer.io/keep-limit": "true"},
+			containers:  []corev1.Container{{Name: "web"}},
+			wantOutcome: audit.OutcomeApplied,
+			want:        map[string]corev1.ResourceRequirements{"web": {Requests: resources("memory", "128Mi")}},
+		},
+		{
+			name:        "keep-limit under the legacy prefix",
+			settings:    map[string]string{"memoryRequest": "128Mi", "memoryLimitRatio": "2"},
+			annotations: map[string]string{"k8s-ycl.bjw-s.dev/keep-limit": "true"},
+			containers:  []corev1.Container{{Name: "web"}},
+			wantOutcome: audit.OutcomeApplied,
+			want:        map[string]corev1.ResourceRequirements{"web": {Requests: resources("memory", "128Mi")}},
+		},
+		{
+			name:                 "keep-limit inherited from the namespace",
+			settings:             map[string]string{"memoryLimit": "1Gi"},
+			namespaceAnnotations: map[string]string{"k8s-ycl.bjw-s.dev/keep-limit": "true"},
+			containers:           []corev1.Container{{Name: "web"}},
+			wantOutcome:          audit.OutcomeSkipped,
+			want:                 map[string]corev1.ResourceRequirements{"web": {}},
+		},
+		{
+			name:                 "namespace annotation overrides settings",
+			settings:             map[string]string{"cpuRequest": "100m", "memoryRequest": "128Mi"},
+			namespaceAnnotations: map[string]string{"policy-control.aumer.io/default-memory-request": "256Mi"},
+			containers:           []corev1.Container{{Name: "web"}},
+			wantOutcome:          audit.OutcomeApplied,
+			want:                 map[string]corev1.ResourceRequirements{"web": {Requests: resources("cpu", "100m", "memory", "256Mi")}},
+		},
+		{
+			name:                 "pod annotation overrides the namespace",
+			namespaceAnnotations: map[string]string{"policy-control.aumer.io/default-memory-request": "256Mi"},
+			annotations:          map[string]string{"policy-control.aumer.io/default-memory-request": "64Mi"},
+			containers:           []corev1.Container{{Name: "web"}},
+			wantOutcome:          audit.OutcomeApplied,
+			want:                 map[string]corev1.ResourceRequirements{"web": {Requests: resources("memory", "64Mi")}},
+		},
+		{
+			name:        "updates are skipped",
+			settings:    map[string]string{"memoryRequest": "128Mi"},
+			operation:   "UPDATE",
+			containers:  []corev1.Container{{Name: "web"}},
+			wantOutcome: audit.OutcomeSkipped,
+			want:        map[string]corev1.ResourceRequirements{"web": {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Policies = map[string]config.PolicyConfig{"pod-default-resources": {Settings: tt.settings}}
+			config.Set(cfg)
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			pod := newPod(tt.annotations, tt.containers...)
+			env := newTestEnv(newNamespace(nil, tt.namespaceAnnotations))
+			env.Operation = tt.operation
+
+			entry, err := ApplyPolicy(PodDefaultResources{}, pod, env)
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, tt.wantOutcome)
+			}
+			for _, container := range pod.Spec.Containers {
+				if want := tt.want[container.Name]; !apiequality.Semantic.DeepEqual(container.Resources, want) {
+					t.Errorf("container %s resources = %v, want %v", container.Name, container.Resources, want)
+				}
+			}
+		})
+	}
+}
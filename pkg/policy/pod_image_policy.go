@@ -0,0 +1,211 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/image"
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var (
+	allowedRegistriesAnnotation = annotation.Key{
+		Name:        "allowed-registries",
+		Inherit:     true,
+		Type:        annotation.TypeList,
+		Description: "Comma separated registries, optionally with a repository path such as ghcr.io/org, that images may be pulled from. Defaults to the allowedRegistries setting of the policy, all registries are allowed if neither is set.",
+	}
+	pinDigestsAnnotation = annotation.Key{
+		Name:        "pin-digests",
+		Inherit:     true,
+		Type:        annotation.TypeBool,
+		Description: "Pin image tags to the digest they currently point at. Defaults to the pinDigests setting of the policy.",
+	}
+	podImagePolicyLog = ctrl.Log.WithName("pod_image_policy")
+)
+
+// PodImagePolicy rejects Pods with images from registries outside the allowed
+// ones, and images without a tag or tagged latest. Images pinned to a digest
+// don't need a tag. With pin-digests it also adds the digest to tagged images,
+// resolved through Resolver or the file named by the digestFile setting.
+//
+// It runs in the validate phase, after registry mirrors rewrote the images, so
+// the allowed registries are the ones the images are pulled from.
+//
+// In audit mode violations don't block the Pod. They are returned as admission
+// warnings, and existing Pods are reported by the manager.
+type PodImagePolicy struct {
+	// Resolver overrides the digestFile setting.
+	Resolver image.Resolver
+}
+
+func (p PodImagePolicy) Name() string {
+	return "Pod Image Policy"
+}
+
+func (p PodImagePolicy) Type() int {
+	return PolicyTypePod
+}
+
+func (p PodImagePolicy) Admission() bool {
+	return true
+}
+
+func (p PodImagePolicy) Phase() Phase {
+	return PhaseValidate
+}
+
+func (p PodImagePolicy) Validate(obj runtime.Object, env *Env) (error, bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("could not cast object to Pod"), false
+	}
+	// Existing Pods are reported by the manager rather than blocked, and pinning
+	// the image of a running container would restart it.
+	if !env.creating() {
+		return nil, false
+	}
+
+	parser := annotationParser(env, pod)
+	imagePolicyAllowedRegistries(parser, Config(p))
+	imagePolicyPinDigests(parser, Config(p))
+	if err := parser.Err(); err != nil {
+		warnInvalidAnnotations(env, p, pod, err)
+		return nil, false
+	}
+	return nil, len(pod.Spec.Containers) > 0
+}
+
+func (p PodImagePolicy) Apply(obj runtime.Object, env *Env) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		podImagePolicyLog.Error(fmt.Errorf("could not cast object to Pod"), "error casting object to Pod")
+		return nil
+	}
+
+	parser := annotationParser(env, pod)
+	allowed := imagePolicyAllowedRegistries(parser, Config(p))
+
+	var violations []string
+	for _, container := range podImages(pod) {
+		violations = append(violations, imageViolations(container, pod.Namespace, allowed)...)
+	}
+	if len(violations) > 0 {
+		return Deny("%s", strings.Join(violations, "; "))
+	}
+
+	if pin := imagePolicyPinDigests(parser, Config(p)); pin {
+		p.pinDigests(pod)
+	}
+	return nil
+}
+
+func (p PodImagePolicy) Categories() []string {
+	return imageCategories
+}
+
+func (p PodImagePolicy) Annotations() []annotation.Key {
+	return []annotation.Key{allowedRegistriesAnnotation, pinDigestsAnnotation}
+}
+
+func (p PodImagePolicy) ValidateConfig(policyConfig config.PolicyConfig) error {
+	if registries := policyConfig.Setting("allowedRegistries", ""); registries != "" {
+		if _, err := annotation.SplitList(registries); err != nil {
+			return fmt.Errorf("settings.allowedRegistries: %w", err)
+		}
+	}
+	if pin := policyConfig.Setting("pinDigests", ""); pin != "" {
+		if _, err := annotation.ParseBool(pin); err != nil {
+			return fmt.Errorf("settings.pinDigests: %w", err)
+		}
+	}
+	return nil
+}
+
+// imagePolicyAllowedRegistries returns the allowed registry prefixes, or nil if
+// any registry is allowed.
+func imagePolicyAllowedRegistries(parser *annotation.Parser, policyConfig config.PolicyConfig) []string {
+	registries := parser.List(allowedRegistriesAnnotation)
+	if _, set := parser.Origin(allowedRegistriesAnnotation); !set {
+		registries, _ = annotation.SplitList(policyConfig.Setting("allowedRegistries", ""))
+	}
+	for i, registry := range registries {
+		registries[i] = image.NormalizePrefix(registry)
+	}
+	return registries
+}
+
+func imagePolicyPinDigests(parser *annotation.Parser, policyConfig config.PolicyConfig) bool {
+	pin, set := parser.Bool(pinDigestsAnnotation)
+	if !set {
+		pin, _ = annotation.ParseBool(policyConfig.Setting("pinDigests", "false"))
+	}
+	return pin
+}
+
+// imageViolations explains everything wrong with the image of a container.
+func imageViolations(container podImage, namespace string, allowed []string) []string {
+	ref, err := image.Parse(strings.TrimSpace(*container.Image))
+	if err != nil {
+		return []string{fmt.Sprintf("container %s: %v", container.Container, err)}
+	}
+
+	var violations []string
+	if len(allowed) > 0 {
+		matched := false
+		for _, registry := range allowed {
+			if ref.HasPrefix(registry) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			violations = append(violations, fmt.Sprintf("container %s: image %s is not from an allowed registry in namespace %s, allowed are %s", container.Container, *container.Image, namespace, strings.Join(allowed, ", ")))
+		}
+	}
+
+	switch {
+	case ref.Tag == "latest":
+		violations = append(violations, fmt.Sprintf("container %s: image %s uses the mutable tag latest, use a version tag or a digest", container.Container, *container.Image))
+	case ref.Tag == "" && ref.Digest == "":
+		violations = append(violations, fmt.Sprintf("container %s: image %s has no tag, which means latest, use a version tag or a digest", container.Container, *container.Image))
+	}
+	return violations
+}
+
+// pinDigests adds the current digest to every tagged image without one. Images
+// that can't be resolved keep their tag, pinning is best effort.
+func (p PodImagePolicy) pinDigests(pod *corev1.Pod) {
+	resolver := p.Resolver
+	if resolver == nil {
+		path := Config(p).Setting("digestFile", "")
+		if path == "" {
+			podImagePolicyLog.Info("No digest resolver configured, not pinning digests", "namespace", pod.Namespace, "pod", getObjectName(pod))
+			return
+		}
+		resolver = image.NewFileResolver(path)
+	}
+
+	for _, container := range podImages(pod) {
+		ref, err := image.Parse(strings.TrimSpace(*container.Image))
+		if err != nil || ref.Digest != "" {
+			continue
+		}
+		digest, err := resolver.Resolve(context.Background(), ref)
+		if err != nil {
+			podImagePolicyLog.Info("Unable to resolve digest", "namespace", pod.Namespace, "pod", getObjectName(pod), "image", *container.Image, "error", err.Error())
+			continue
+		}
+		// The image is kept as written, only the digest is appended.
+		*container.Image = strings.TrimSpace(*container.Image) + "@" + digest
+	}
+}
+
+func init() {
+	RegisterPolicy(&PodImagePolicy{})
+}
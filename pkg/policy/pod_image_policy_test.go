@@ -0,0 +1,213 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/image"
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const testDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+
+// mapResolver resolves digests from a map keyed by the full reference.
+type mapResolver map[string]string
+
+func (r mapResolver) Resolve(ctx context.Context, ref image.Reference) (string, error) {
+	digest, ok := r[ref.String()]
+	if !ok {
+		return "", fmt.Errorf("no digest for %s", ref)
+	}
+	return digest, nil
+}
+
+// imageContainers returns containers c0, c1 and so on running images.
+func imageContainers(images ...string) []corev1.Container {
+	var containers []corev1.Container
+	for i, img := range images {
+		containers = append(containers, corev1.Container{Name: fmt.Sprintf("c%d", i), Image: img})
+	}
+	return containers
+}
+
+func TestPodImagePolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		operation   string
+		settings    map[string]string
+		objects     []client.Object
+		pod         *corev1.Pod
+		init        []corev1.Container
+		wantOutcome string
+		wantMessage []string
+		wantImages  []string
+	}{
+		{
+			name:        "version tag",
+			pod:         newPod(nil, imageContainers("nginx:1.25")...),
+			wantOutcome: audit.OutcomeApplied,
+			wantImages:  []string{"nginx:1.25"},
+		},
+		{
+			name:        "digest without tag",
+			pod:         newPod(nil, imageContainers("nginx@"+testDigest)...),
+			wantOutcome: audit.OutcomeApplied,
+		},
+		{
+			name:        "latest tag",
+			pod:         newPod(nil, imageContainers("nginx:latest")...),
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: []string{"container c0: image nginx:latest uses the mutable tag latest"},
+		},
+		{
+			name:        "no tag",
+			pod:         newPod(nil, imageContainers("nginx")...),
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: []string{"container c0: image nginx has no tag"},
+		},
+		{
+			name:        "every violation is reported",
+			pod:         newPod(nil, imageContainers("nginx:1.25", "redis", "busybox:latest")...),
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: []string{"container c1: image redis has no tag", "container c2: image busybox:latest uses the mutable tag latest"},
+		},
+		{
+			name:        "audit mode does not deny",
+			mode:        config.ModeAudit,
+			pod:         newPod(nil, imageContainers("nginx")...),
+			wantOutcome: audit.OutcomeAudited,
+			wantMessage: []string{"container c0: image nginx has no tag"},
+		},
+		{
+			name:        "allowed registry from settings",
+			settings:    map[string]string{"allowedRegistries": "ghcr.io/org,registry.local"},
+			pod:         newPod(nil, imageContainers("ghcr.io/org/app:1.0", "registry.local/tools/cli:2")...),
+			wantOutcome: audit.OutcomeApplied,
+		},
+		{
+			name:        "registry outside the allowed ones",
+			settings:    map[string]string{"allowedRegistries": "ghcr.io/org"},
+			pod:         newPod(nil, imageContainers("ghcr.io/other/app:1.0")...),
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: []string{"container c0: image ghcr.io/other/app:1.0 is not from an allowed registry in namespace default"},
+		},
+		{
+			name:        "docker hub images match docker.io",
+			settings:    map[string]string{"allowedRegistries": "docker.io"},
+			pod:         newPod(nil, imageContainers("nginx:1.25")...),
+			wantOutcome: audit.OutcomeApplied,
+		},
+		{
+			name:        "annotation overrides settings",
+			settings:    map[string]string{"allowedRegistries": "ghcr.io/org"},
+			pod:         newPod(map[string]string{"policy-control.aumer.io/allowed-registries": "quay.io"}, imageContainers("ghcr.io/org/app:1.0")...),
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: []string{"is not from an allowed registry"},
+		},
+		{
+			name:        "annotation inherited from the namespace",
+			objects:     []client.Object{newNamespace(nil, map[string]string{"policy-control.aumer.io/allowed-registries": "ghcr.io/org"})},
+			pod:         newPod(nil, imageContainers("quay.io/app:1.0")...),
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: []string{"is not from an allowed registry"},
+		},
+		{
+			name:        "init containers are checked",
+			pod:         newPod(nil, corev1.Container{Name: "web", Image: "nginx:1.25"}),
+			init:        []corev1.Container{{Name: "init", Image: "busybox"}},
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: []string{"container init: image busybox has no tag"},
+		},
+		{
+			name:        "invalid annotation",
+			pod:         newPod(map[string]string{"policy-control.aumer.io/pin-digests": "maybe"}, imageContainers("nginx")...),
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "updates are skipped",
+			operation:   "UPDATE",
+			settings:    map[string]string{"pinDigests": "true"},
+			pod:         newPod(nil, imageContainers("nginx:latest", "nginx:1.25")...),
+			wantOutcome: audit.OutcomeSkipped,
+			wantImages:  []string{"nginx:latest", "nginx:1.25"},
+		},
+		{
+			name:        "pin digests from settings",
+			settings:    map[string]string{"pinDigests": "true"},
+			pod:         newPod(nil, imageContainers("nginx:1.25", "redis:7")...),
+			wantOutcome: audit.OutcomeApplied,
+			wantImages:  []string{"nginx:1.25@" + testDigest, "redis:7"},
+		},
+		{
+			name:        "pin digests from annotation",
+			pod:         newPod(map[string]string{"policy-control.aumer.io/pin-digests": "true"}, imageContainers("nginx:1.25")...),
+			wantOutcome: audit.OutcomeApplied,
+			wantImages:  []string{"nginx:1.25@" + testDigest},
+		},
+		{
+			name:        "pinned images are kept",
+			settings:    map[string]string{"pinDigests": "true"},
+			pod:         newPod(nil, imageContainers("nginx:1.25@sha256:0000000000000000000000000000000000000000000000000000000000000002")...),
+			wantOutcome: audit.OutcomeApplied,
+			wantImages:  []string{"nginx:1.25@sha256:0000000000000000000000000000000000000000000000000000000000000002"},
+		},
+	}
+
+	resolver := mapResolver{"docker.io/library/nginx:1.25": testDigest}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Policies = map[string]config.PolicyConfig{"pod-image-policy": {Mode: tt.mode, Settings: tt.settings}}
+			config.Set(cfg)
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			tt.pod.Spec.InitContainers = tt.init
+			env := newTestEnv(tt.objects...)
+			env.Operation = tt.operation
+			entry, err := ApplyPolicy(PodImagePolicy{Resolver: resolver}, tt.pod, env)
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, tt.wantOutcome)
+			}
+			for _, want := range tt.wantMessage {
+				if !strings.Contains(entry.Message, want) {
+					t.Errorf("message = %q, want it to contain %q", entry.Message, want)
+				}
+			}
+			for i, want := range tt.wantImages {
+				if got := tt.pod.Spec.Containers[i].Image; got != want {
+					t.Errorf("container %d image = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPodImagePolicyValidateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		wantErr  bool
+	}{
+		{name: "empty"},
+		{name: "valid", settings: map[string]string{"allowedRegistries": "ghcr.io/org,docker.io", "pinDigests": "true"}},
+		{name: "invalid pinDigests", settings: map[string]string{"pinDigests": "maybe"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := PodImagePolicy{}.ValidateConfig(config.PolicyConfig{Settings: tt.settings})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
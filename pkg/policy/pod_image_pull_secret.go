@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// PodImagePullSecret adds the image pull Secret copied by the Namespace Image
+// Pull Secret policy to the imagePullSecrets of Pods in selected Namespaces. It
+// shares the settings of that policy and only runs when its attachTo setting is
+// pod.
+type PodImagePullSecret struct{}
+
+func (p PodImagePullSecret) Name() string {
+	return "Pod Image Pull Secret"
+}
+
+func (p PodImagePullSecret) Type() int {
+	return PolicyTypePod
+}
+
+func (p PodImagePullSecret) Admission() bool {
+	return true
+}
+
+func (p PodImagePullSecret) Phase() Phase {
+	return PhaseDefault
+}
+
+func (p PodImagePullSecret) Validate(obj runtime.Object, env *Env) (error, bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("could not cast object to Pod"), false
+	}
+	// imagePullSecrets can't be changed once the Pod exists.
+	if !env.creating() {
+		return nil, false
+	}
+
+	replication := Config(NamespaceImagePullSecret{})
+	settings, configured, err := imagePullSecretSettings(replication)
+	if err != nil || !configured || !replication.IsEnabled() || settings.attachTo != attachToPod {
+		return err, false
+	}
+
+	namespace, err := getNamespace(env, pod.Namespace)
+	if err != nil {
+		return err, false
+	}
+	selected, err := settings.selects(namespace)
+	if err != nil {
+		warnInvalidAnnotations(env, p, pod, err)
+		return nil, false
+	}
+	return nil, selected
+}
+
+func (p PodImagePullSecret) Apply(obj runtime.Object, env *Env) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		imagePullSecretLog.Error(fmt.Errorf("could not cast object to Pod"), "error casting object to Pod")
+		return nil
+	}
+
+	settings, _, err := imagePullSecretSettings(Config(NamespaceImagePullSecret{}))
+	if err != nil {
+		return err
+	}
+
+	var added bool
+	pod.Spec.ImagePullSecrets, added = addImagePullSecret(pod.Spec.ImagePullSecrets, settings.name)
+	if added {
+		imagePullSecretLog.Info("Added image pull Secret", "namespace", pod.Namespace, "pod", getObjectName(pod), "secret", settings.name)
+	}
+	return nil
+}
+
+func init() {
+	RegisterPolicy(&PodImagePullSecret{})
+}
@@ -0,0 +1,336 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	injectAnnotation = annotation.Key{
+		Name:        "inject",
+		Inherit:     true,
+		Type:        annotation.TypeList,
+		Description: "Comma separated injection templates to add to the Pod, e.g. vault-agent,log-shipper.",
+	}
+	podInjectLog = ctrl.Log.WithName("pod_inject")
+)
+
+const (
+	// injectTemplatesLabel marks the ConfigMaps holding injection templates, it
+	// is put under the canonical annotation prefix.
+	injectTemplatesLabel = "inject-templates"
+	// serviceAccountNamespaceFile holds the namespace of the controller when it
+	// runs in a cluster.
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// injectCategories select every policy adding containers to Pods with the
+// policies annotation.
+var injectCategories = []string{"injection"}
+
+// PodInject adds the containers, init containers, volumes, env and annotations
+// of the templates a Pod names in its inject annotation.
+//
+// Templates live in ConfigMaps labelled policy-control.aumer.io/inject-templates
+// in the namespace setting, which defaults to the namespace of the controller.
+// Every data key is a template, rendered with text/template against .Pod and
+// .Namespace metadata and read as YAML with the fields initContainers,
+// containers, volumes, env and annotations. Env is added to every container.
+// Nothing the Pod already has by name is replaced, so admitting a Pod twice
+// doesn't duplicate anything.
+//
+// Injection runs in PhaseInject: resource defaults and image pull secrets are
+// set before it and don't cover injected containers, while registry mirrors,
+// security defaults and every validating policy, the image policy included, see
+// them. Without a namespace to read templates from the policy is skipped with a
+// Warning Event.
+type PodInject struct{}
+
+// injectTemplate is a rendered injection template.
+type injectTemplate struct {
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+	Containers     []corev1.Container `json:"containers,omitempty"`
+	Volumes        []corev1.Volume    `json:"volumes,omitempty"`
+	Env            []corev1.EnvVar    `json:"env,omitempty"`
+	Annotations    map[string]string  `json:"annotations,omitempty"`
+}
+
+// injectTemplateData is what templates are rendered against. The Pod name is
+// empty for Pods created with generateName.
+type injectTemplateData struct {
+	Pod       metav1.ObjectMeta
+	Namespace metav1.ObjectMeta
+}
+
+func (p PodInject) Name() string {
+	return "Pod Inject"
+}
+
+func (p PodInject) Type() int {
+	return PolicyTypePod
+}
+
+func (p PodInject) Admission() bool {
+	return true
+}
+
+func (p PodInject) Phase() Phase {
+	return PhaseInject
+}
+
+func (p PodInject) Validate(obj runtime.Object, env *Env) (error, bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("could not cast object to Pod"), false
+	}
+	// Containers can't be added once the Pod exists.
+	if !env.creating() {
+		return nil, false
+	}
+
+	parser := annotationParser(env, pod)
+	names := parser.List(injectAnnotation)
+	if err := parser.Err(); err != nil {
+		warnInvalidAnnotations(env, p, pod, err)
+		return nil, false
+	}
+	if len(names) == 0 {
+		return nil, false
+	}
+
+	if templatesNamespace(p) == "" {
+		podInjectLog.Info("No namespace to read injection templates from, skipping policy", "namespace", pod.Namespace, "pod", getObjectName(pod))
+		env.Warningf(pod, "InjectionSkipped", "%s skipped: no namespace to read injection templates from, set settings.namespace", p.Name())
+		return nil, false
+	}
+	return nil, true
+}
+
+func (p PodInject) Apply(obj runtime.Object, env *Env) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		podInjectLog.Error(fmt.Errorf("could not cast object to Pod"), "error casting object to Pod")
+		return nil
+	}
+
+	parser := annotationParser(env, pod)
+	names := parser.List(injectAnnotation)
+	if err := parser.Err(); err != nil {
+		return err
+	}
+
+	templates, err := p.templates(env)
+	if err != nil {
+		return err
+	}
+	data, err := injectData(env, pod)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		source, ok := templates[name]
+		if !ok {
+			return Deny("unknown injection template %q", name)
+		}
+		rendered, err := renderInjectTemplate(name, source, data)
+		if err != nil {
+			return Deny("%s", err)
+		}
+		if injectInto(pod, rendered) {
+			podInjectLog.Info("Injected template", "namespace", pod.Namespace, "pod", getObjectName(pod), "template", name)
+		}
+	}
+	return nil
+}
+
+func (p PodInject) Categories() []string {
+	return injectCategories
+}
+
+func (p PodInject) Annotations() []annotation.Key {
+	return []annotation.Key{injectAnnotation}
+}
+
+func (p PodInject) ValidateConfig(policyConfig config.PolicyConfig) error {
+	if namespace := policyConfig.Setting("namespace", ""); namespace != "" {
+		if msgs := validation.IsDNS1123Label(namespace); len(msgs) > 0 {
+			return fmt.Errorf("settings.namespace %q: %s", namespace, strings.Join(msgs, ", "))
+		}
+	}
+	return nil
+}
+
+// templates returns the source of every injection template by name. A name
+// defined by several ConfigMaps resolves to the first ConfigMap by name.
+func (p PodInject) templates(env *Env) (map[string]string, error) {
+	namespace := templatesNamespace(p)
+	if namespace == "" {
+		return nil, fmt.Errorf("no namespace to read injection templates from, set settings.namespace")
+	}
+
+	configMapList := corev1.ConfigMapList{}
+	label := config.Current().AnnotationPrefix + injectTemplatesLabel
+	if err := env.Client.List(context.Background(), &configMapList, client.InNamespace(namespace), client.HasLabels{label}); err != nil {
+		return nil, err
+	}
+	sort.Slice(configMapList.Items, func(i, j int) bool {
+		return configMapList.Items[i].Name < configMapList.Items[j].Name
+	})
+
+	templates := map[string]string{}
+	for _, configMap := range configMapList.Items {
+		for name, source := range configMap.Data {
+			if _, ok := templates[name]; ok {
+				podInjectLog.Info("Ignoring duplicate injection template", "template", name, "configMap", client.ObjectKeyFromObject(&configMap))
+				continue
+			}
+			templates[name] = source
+		}
+	}
+	return templates, nil
+}
+
+// templatesNamespace returns the namespace injection templates are read from,
+// or an empty string when neither the setting nor the controller namespace is
+// known.
+func templatesNamespace(p PodInject) string {
+	return Config(p).Setting("namespace", controllerNamespace())
+}
+
+// controllerNamespace returns the namespace the controller runs in, or an empty
+// string when it runs outside of a cluster.
+func controllerNamespace() string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	namespace, err := os.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(namespace))
+}
+
+func injectData(env *Env, pod *corev1.Pod) (injectTemplateData, error) {
+	namespace, err := getNamespace(env, pod.Namespace)
+	if err != nil {
+		return injectTemplateData{}, err
+	}
+
+	return injectTemplateData{
+		Pod: metav1.ObjectMeta{
+			Name:         pod.Name,
+			GenerateName: pod.GenerateName,
+			Namespace:    pod.Namespace,
+			Labels:       pod.Labels,
+			Annotations:  pod.Annotations,
+		},
+		Namespace: metav1.ObjectMeta{
+			Name:        namespace.Name,
+			Labels:      namespace.Labels,
+			Annotations: namespace.Annotations,
+		},
+	}, nil
+}
+
+func renderInjectTemplate(name string, source string, data injectTemplateData) (*injectTemplate, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("injection template %q: %w", name, err)
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("injection template %q: %w", name, err)
+	}
+
+	injected := &injectTemplate{}
+	if err := yaml.UnmarshalStrict(rendered.Bytes(), injected); err != nil {
+		return nil, fmt.Errorf("injection template %q: %w", name, err)
+	}
+	return injected, nil
+}
+
+// injectInto merges injected into pod and reports whether anything changed.
+// Containers, volumes, env and annotations the Pod already has by name are left
+// as they are.
+func injectInto(pod *corev1.Pod, injected *injectTemplate) bool {
+	changed := false
+	for _, container := range injected.InitContainers {
+		if !hasContainer(pod, container.Name) {
+			pod.Spec.InitContainers = append(pod.Spec.InitContainers, container)
+			changed = true
+		}
+	}
+	for _, container := range injected.Containers {
+		if !hasContainer(pod, container.Name) {
+			pod.Spec.Containers = append(pod.Spec.Containers, container)
+			changed = true
+		}
+	}
+	for _, volume := range injected.Volumes {
+		if !hasVolume(pod, volume.Name) {
+			pod.Spec.Volumes = append(pod.Spec.Volumes, volume)
+			changed = true
+		}
+	}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			for _, envVar := range injected.Env {
+				if !hasEnv(&containers[i], envVar.Name) {
+					containers[i].Env = append(containers[i].Env, envVar)
+					changed = true
+				}
+			}
+		}
+	}
+	for key, value := range injected.Annotations {
+		if _, ok := pod.Annotations[key]; ok {
+			continue
+		}
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[key] = value
+		changed = true
+	}
+	return changed
+}
+
+func hasContainer(pod *corev1.Pod, name string) bool {
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			if container.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasEnv(container *corev1.Container, name string) bool {
+	for _, envVar := range container.Env {
+		if envVar.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	RegisterPolicy(&PodInject{})
+}
@@ -0,0 +1,229 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const logShipperTemplate = `
+containers:
+- name: log-shipper
+  image: fluent-bit:3.0
+  args: ["--tag={{ .Namespace.Name }}.{{ .Pod.GenerateName }}"]
+  volumeMounts:
+  - name: logs
+    mountPath: /var/log/app
+volumes:
+- name: logs
+  emptyDir: {}
+env:
+- name: LOG_DIR
+  value: /var/log/app
+annotations:
+  logs.example.com/enabled: "true"
+`
+
+func newInjectTemplates(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "policy-system",
+			Name:      "templates",
+			Labels:    map[string]string{config.Default().AnnotationPrefix + injectTemplatesLabel: ""},
+		},
+		Data: data,
+	}
+}
+
+func setInjectConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Policies = map[string]config.PolicyConfig{"pod-inject": {Settings: map[string]string{"namespace": "policy-system"}}}
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+}
+
+func TestPodInject(t *testing.T) {
+	setInjectConfig(t)
+	env := newTestEnv(newInjectTemplates(map[string]string{"log-shipper": logShipperTemplate}))
+	pod := newPod(map[string]string{"policy-control.aumer.io/inject": "log-shipper"}, corev1.Container{Name: "web", Image: "app:1.0"})
+	// Pods of workloads are named from generateName after admission.
+	pod.Name, pod.GenerateName = "", "web-"
+	pod.Spec.InitContainers = []corev1.Container{{Name: "migrate", Image: "app:1.0"}}
+
+	entry, err := ApplyPolicy(PodInject{}, pod, env)
+	if err != nil {
+		t.Fatalf("ApplyPolicy() error = %v", err)
+	}
+	if entry.Outcome != audit.OutcomeApplied {
+		t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, audit.OutcomeApplied)
+	}
+
+	if len(pod.Spec.Containers) != 2 || pod.Spec.Containers[1].Name != "log-shipper" {
+		t.Fatalf("containers = %v, want web and log-shipper", pod.Spec.Containers)
+	}
+	if args := pod.Spec.Containers[1].Args; len(args) != 1 || args[0] != "--tag=default.web-" {
+		t.Errorf("log-shipper args = %v, want the rendered tag --tag=default.web-", args)
+	}
+	if len(pod.Spec.Volumes) != 1 || pod.Spec.Volumes[0].Name != "logs" {
+		t.Errorf("volumes = %v, want logs", pod.Spec.Volumes)
+	}
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		if !hasEnv(&container, "LOG_DIR") {
+			t.Errorf("container %s env = %v, want LOG_DIR", container.Name, container.Env)
+		}
+	}
+	if pod.Annotations["logs.example.com/enabled"] != "true" {
+		t.Errorf("annotations = %v, want logs.example.com/enabled", pod.Annotations)
+	}
+}
+
+func TestPodInjectIsIdempotent(t *testing.T) {
+	setInjectConfig(t)
+	env := newTestEnv(newInjectTemplates(map[string]string{"log-shipper": logShipperTemplate}))
+	pod := newPod(map[string]string{"policy-control.aumer.io/inject": "log-shipper"}, corev1.Container{Name: "web", Image: "app:1.0"})
+
+	if _, err := ApplyPolicy(PodInject{}, pod, env); err != nil {
+		t.Fatalf("first ApplyPolicy() error = %v", err)
+	}
+	injected := pod.DeepCopy()
+
+	entry, err := ApplyPolicy(PodInject{}, pod, env)
+	if err != nil {
+		t.Fatalf("second ApplyPolicy() error = %v", err)
+	}
+	if entry.Patch != nil {
+		t.Errorf("second patch = %s, want none", entry.Patch)
+	}
+	if !reflect.DeepEqual(pod, injected) {
+		t.Errorf("second ApplyPolicy() changed the Pod:\n%+v\nwant\n%+v", pod.Spec, injected.Spec)
+	}
+}
+
+func TestPodInjectKeepsExisting(t *testing.T) {
+	setInjectConfig(t)
+	env := newTestEnv(newInjectTemplates(map[string]string{"log-shipper": logShipperTemplate}))
+	pod := newPod(map[string]string{"policy-control.aumer.io/inject": "log-shipper"}, corev1.Container{Name: "web", Image: "app:1.0"})
+	pod.Annotations["logs.example.com/enabled"] = "false"
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "log-shipper", Image: "fluent-bit:2.0"})
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "LOG_DIR", Value: "/logs"}}
+
+	if _, err := ApplyPolicy(PodInject{}, pod, env); err != nil {
+		t.Fatalf("ApplyPolicy() error = %v", err)
+	}
+	if len(pod.Spec.Containers) != 2 || pod.Spec.Containers[1].Image != "fluent-bit:2.0" {
+		t.Errorf("containers = %v, want the existing log-shipper kept", pod.Spec.Containers)
+	}
+	if env := pod.Spec.Containers[0].Env; len(env) != 1 || env[0].Value != "/logs" {
+		t.Errorf("web env = %v, want the existing LOG_DIR kept", env)
+	}
+	if pod.Annotations["logs.example.com/enabled"] != "false" {
+		t.Errorf("annotations = %v, want the existing value kept", pod.Annotations)
+	}
+}
+
+func TestPodInjectDenies(t *testing.T) {
+	tests := []struct {
+		name        string
+		templates   map[string]string
+		inject      string
+		wantMessage string
+	}{
+		{
+			name:        "unknown template",
+			templates:   map[string]string{"log-shipper": logShipperTemplate},
+			inject:      "vault-agent",
+			wantMessage: `unknown injection template "vault-agent"`,
+		},
+		{
+			name:        "template does not render",
+			templates:   map[string]string{"broken": "containers: {{ .Pod.Name"},
+			inject:      "broken",
+			wantMessage: `injection template "broken"`,
+		},
+		{
+			name:        "unknown field",
+			templates:   map[string]string{"broken": "sidecars: []"},
+			inject:      "broken",
+			wantMessage: `injection template "broken"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setInjectConfig(t)
+			pod := newPod(map[string]string{"policy-control.aumer.io/inject": tt.inject}, corev1.Container{Name: "web", Image: "app:1.0"})
+			entry, err := ApplyPolicy(PodInject{}, pod, newTestEnv(newInjectTemplates(tt.templates)))
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != audit.OutcomeDenied {
+				t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, audit.OutcomeDenied)
+			}
+			if !strings.Contains(entry.Message, tt.wantMessage) {
+				t.Errorf("message = %q, want it to contain %q", entry.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestPodInjectWithoutNamespace(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "")
+	if controllerNamespace() != "" {
+		t.Skip("running in a cluster, the controller namespace is known")
+	}
+	config.Set(config.Default())
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	recorder := record.NewFakeRecorder(1)
+	env := newTestEnv(newInjectTemplates(map[string]string{"log-shipper": logShipperTemplate}))
+	env.Recorder = recorder
+	pod := newPod(map[string]string{"policy-control.aumer.io/inject": "log-shipper"}, corev1.Container{Name: "web", Image: "app:1.0"})
+
+	entry, err := ApplyPolicy(PodInject{}, pod, env)
+	if err != nil {
+		t.Fatalf("ApplyPolicy() error = %v", err)
+	}
+	if entry.Outcome != audit.OutcomeSkipped {
+		t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, audit.OutcomeSkipped)
+	}
+	if len(pod.Spec.Containers) != 1 {
+		t.Errorf("containers = %v, want only web", pod.Spec.Containers)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "InjectionSkipped") {
+			t.Errorf("event = %q, want InjectionSkipped", event)
+		}
+	default:
+		t.Error("no Warning Event recorded")
+	}
+}
+
+func TestApplyPoliciesChecksInjectedImages(t *testing.T) {
+	cfg := config.Default()
+	cfg.Policies = map[string]config.PolicyConfig{"pod-inject": {Settings: map[string]string{"namespace": "policy-system"}}}
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	env := newTestEnv(newInjectTemplates(map[string]string{"debug": "containers:\n- name: debug\n  image: busybox:latest\n"}))
+	pod := newPod(map[string]string{"policy-control.aumer.io/inject": "debug"}, corev1.Container{Name: "web", Image: "app:1.0"})
+	decisions, err := ApplyPoliciesByType(PolicyTypePod, pod, env)
+	if err != nil {
+		t.Fatalf("ApplyPoliciesByType() error = %v", err)
+	}
+	for _, decision := range decisions {
+		if decision.Policy == "Pod Image Policy" {
+			if decision.Outcome != audit.OutcomeDenied || !strings.Contains(decision.Message, "container debug") {
+				t.Errorf("image policy outcome = %q (%s), want the injected container denied", decision.Outcome, decision.Message)
+			}
+			return
+		}
+	}
+	t.Error("image policy didn't run")
+}
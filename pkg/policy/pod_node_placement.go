@@ -0,0 +1,350 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// The node placement annotations are only read from the Namespace, so that a
+// workload can't move itself off its dedicated nodes.
+var (
+	nodeSelectorAnnotation = annotation.Key{
+		Name:        "node-selector",
+		Type:        annotation.TypeString,
+		Description: "Comma separated key=value node labels merged into the nodeSelector of Pods in the Namespace. Only read from Namespaces, overrides the nodeSelector setting of the policy.",
+	}
+	nodeAffinityAnnotation = annotation.Key{
+		Name: "node-affinity",
+		Type: annotation.TypeString,
+		Description: "Label selector for nodes added to the required node affinity of Pods in the Namespace, e.g. \"pool in (tenant-a),!spot\". " +
+			"Only read from Namespaces, overrides the nodeAffinity setting of the policy.",
+	}
+	defaultTolerationsAnnotation = annotation.Key{
+		Name:        "default-tolerations",
+		Type:        annotation.TypeString,
+		Description: "JSON list of tolerations added to Pods in the Namespace. Only read from Namespaces, overrides the defaultTolerations setting of the policy.",
+	}
+	tolerationsAllowlistAnnotation = annotation.Key{
+		Name: "tolerations-allowlist",
+		Type: annotation.TypeString,
+		Description: "JSON list of the tolerations Pods in the Namespace may have, an empty key with operator Exists allows any key. " +
+			"Only read from Namespaces, overrides the tolerationsAllowlist setting of the policy.",
+	}
+	podNodePlacementLog = ctrl.Log.WithName("pod_node_placement")
+)
+
+// PodNodePlacement keeps the Pods of a Namespace on the nodes meant for it, like
+// the PodNodeSelector and PodTolerationRestriction admission plugins. The node
+// selector, node affinity and default tolerations of the Namespace are merged
+// into every Pod, and Pods are denied when their nodeSelector conflicts with
+// the Namespace or they tolerate a taint the allowlist doesn't.
+//
+// Every annotation falls back to the setting of the same name, an annotation
+// with an empty value opts the Namespace out of that setting.
+type PodNodePlacement struct{}
+
+// nodePlacement is what a Namespace requires of the placement of its Pods.
+type nodePlacement struct {
+	nodeSelector       labels.Set
+	nodeAffinity       []corev1.NodeSelectorRequirement
+	defaultTolerations []corev1.Toleration
+	// allowlist is nil when any toleration is allowed.
+	allowlist []corev1.Toleration
+}
+
+func (n nodePlacement) empty() bool {
+	return len(n.nodeSelector) == 0 && len(n.nodeAffinity) == 0 && len(n.defaultTolerations) == 0 && n.allowlist == nil
+}
+
+// automaticTolerations are added to every Pod by the DefaultTolerationSeconds
+// admission plugin before webhooks run, so they are always allowed.
+var automaticTolerations = []corev1.Toleration{
+	{Key: corev1.TaintNodeNotReady, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeUnreachable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+}
+
+func (p PodNodePlacement) Name() string {
+	return "Pod Node Placement"
+}
+
+func (p PodNodePlacement) Type() int {
+	return PolicyTypePod
+}
+
+func (p PodNodePlacement) Admission() bool {
+	return true
+}
+
+func (p PodNodePlacement) Phase() Phase {
+	return PhaseValidate
+}
+
+func (p PodNodePlacement) Validate(obj runtime.Object, env *Env) (error, bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("could not cast object to Pod"), false
+	}
+	// Placement is fixed once the Pod is scheduled.
+	if !env.creating() {
+		return nil, false
+	}
+
+	placement, err := p.resolve(env, pod)
+	if err != nil {
+		warnInvalidAnnotations(env, p, pod, err)
+		return nil, false
+	}
+	return nil, !placement.empty()
+}
+
+func (p PodNodePlacement) Apply(obj runtime.Object, env *Env) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		podNodePlacementLog.Error(fmt.Errorf("could not cast object to Pod"), "error casting object to Pod")
+		return nil
+	}
+
+	placement, err := p.resolve(env, pod)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range placement.nodeSelector {
+		if current, ok := pod.Spec.NodeSelector[key]; ok && current != value {
+			return Deny("nodeSelector %s=%s conflicts with %s=%s required by Namespace %s", key, current, key, value, pod.Namespace)
+		}
+	}
+	if len(placement.nodeSelector) > 0 {
+		pod.Spec.NodeSelector = labels.Merge(pod.Spec.NodeSelector, placement.nodeSelector)
+	}
+	mergeNodeAffinity(pod, placement.nodeAffinity)
+	for _, toleration := range placement.defaultTolerations {
+		if !hasToleration(pod.Spec.Tolerations, toleration) {
+			pod.Spec.Tolerations = append(pod.Spec.Tolerations, toleration)
+		}
+	}
+
+	if placement.allowlist != nil {
+		var denied []string
+		for _, toleration := range pod.Spec.Tolerations {
+			if !tolerationAllowed(toleration, placement.allowlist) {
+				denied = append(denied, formatToleration(toleration))
+			}
+		}
+		if len(denied) > 0 {
+			return Deny("tolerations not allowed in Namespace %s: %s", pod.Namespace, strings.Join(denied, ", "))
+		}
+	}
+	return nil
+}
+
+func (p PodNodePlacement) Categories() []string {
+	return schedulingCategories
+}
+
+func (p PodNodePlacement) Annotations() []annotation.Key {
+	return []annotation.Key{nodeSelectorAnnotation, nodeAffinityAnnotation, defaultTolerationsAnnotation, tolerationsAllowlistAnnotation}
+}
+
+func (p PodNodePlacement) ValidateConfig(policyConfig config.PolicyConfig) error {
+	_, err := parseNodePlacement(func(key annotation.Key) (string, string, bool) {
+		return nodePlacementSetting(policyConfig, key)
+	})
+	return err
+}
+
+// resolve returns the placement the Namespace of pod requires.
+func (p PodNodePlacement) resolve(env *Env, pod *corev1.Pod) (nodePlacement, error) {
+	namespace, err := getNamespace(env, pod.Namespace)
+	if err != nil {
+		return nodePlacement{}, err
+	}
+
+	policyConfig := Config(p)
+	return parseNodePlacement(func(key annotation.Key) (string, string, bool) {
+		if value, ok := key.Value(namespace.Annotations); ok {
+			return value, fmt.Sprintf("annotation %s on Namespace %s", key.FullName(), namespace.Name), true
+		}
+		return nodePlacementSetting(policyConfig, key)
+	})
+}
+
+// nodePlacementSetting returns the setting an annotation falls back to, e.g.
+// nodeSelector for node-selector.
+func nodePlacementSetting(policyConfig config.PolicyConfig, key annotation.Key) (string, string, bool) {
+	parts := strings.Split(key.Name, "-")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	setting := strings.Join(parts, "")
+
+	value := policyConfig.Setting(setting, "")
+	return value, "settings." + setting, value != ""
+}
+
+// parseNodePlacement parses the placement from the values lookup returns, along
+// with where they came from for errors.
+func parseNodePlacement(lookup func(key annotation.Key) (string, string, bool)) (nodePlacement, error) {
+	placement := nodePlacement{}
+
+	if value, source, ok := lookup(nodeSelectorAnnotation); ok && strings.TrimSpace(value) != "" {
+		nodeSelector, err := labels.ConvertSelectorToLabelsMap(value)
+		if err != nil {
+			return placement, fmt.Errorf("%s: %w", source, err)
+		}
+		placement.nodeSelector = nodeSelector
+	}
+
+	if value, source, ok := lookup(nodeAffinityAnnotation); ok && strings.TrimSpace(value) != "" {
+		requirements, err := parseNodeRequirements(value)
+		if err != nil {
+			return placement, fmt.Errorf("%s: %w", source, err)
+		}
+		placement.nodeAffinity = requirements
+	}
+
+	if value, source, ok := lookup(defaultTolerationsAnnotation); ok && strings.TrimSpace(value) != "" {
+		if err := yaml.UnmarshalStrict([]byte(value), &placement.defaultTolerations); err != nil {
+			return placement, fmt.Errorf("%s: %w", source, err)
+		}
+	}
+
+	if value, source, ok := lookup(tolerationsAllowlistAnnotation); ok && strings.TrimSpace(value) != "" {
+		placement.allowlist = []corev1.Toleration{}
+		if err := yaml.UnmarshalStrict([]byte(value), &placement.allowlist); err != nil {
+			return placement, fmt.Errorf("%s: %w", source, err)
+		}
+	}
+	return placement, nil
+}
+
+// parseNodeRequirements turns a label selector into node selector requirements.
+func parseNodeRequirements(value string) ([]corev1.NodeSelectorRequirement, error) {
+	selector, err := labels.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	parsed, _ := selector.Requirements()
+
+	requirements := make([]corev1.NodeSelectorRequirement, 0, len(parsed))
+	for _, requirement := range parsed {
+		var operator corev1.NodeSelectorOperator
+		switch requirement.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In:
+			operator = corev1.NodeSelectorOpIn
+		case selection.NotEquals, selection.NotIn:
+			operator = corev1.NodeSelectorOpNotIn
+		case selection.Exists:
+			operator = corev1.NodeSelectorOpExists
+		case selection.DoesNotExist:
+			operator = corev1.NodeSelectorOpDoesNotExist
+		case selection.GreaterThan:
+			operator = corev1.NodeSelectorOpGt
+		case selection.LessThan:
+			operator = corev1.NodeSelectorOpLt
+		default:
+			return nil, fmt.Errorf("unsupported operator %q in %q", requirement.Operator(), requirement.String())
+		}
+		requirements = append(requirements, corev1.NodeSelectorRequirement{
+			Key:      requirement.Key(),
+			Operator: operator,
+			Values:   requirement.Values().List(),
+		})
+	}
+	return requirements, nil
+}
+
+// mergeNodeAffinity adds requirements to every required node selector term of
+// pod, since terms are ORed, or to a new term if there are none.
+func mergeNodeAffinity(pod *corev1.Pod, requirements []corev1.NodeSelectorRequirement) {
+	if len(requirements) == 0 {
+		return
+	}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+	required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(required.NodeSelectorTerms) == 0 {
+		required.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+	}
+
+	for i := range required.NodeSelectorTerms {
+		term := &required.NodeSelectorTerms[i]
+		for _, requirement := range requirements {
+			if !hasNodeRequirement(term.MatchExpressions, requirement) {
+				term.MatchExpressions = append(term.MatchExpressions, requirement)
+			}
+		}
+	}
+}
+
+func hasNodeRequirement(requirements []corev1.NodeSelectorRequirement, requirement corev1.NodeSelectorRequirement) bool {
+	for _, existing := range requirements {
+		if equality.Semantic.DeepEqual(existing, requirement) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasToleration(tolerations []corev1.Toleration, toleration corev1.Toleration) bool {
+	for _, existing := range tolerations {
+		if existing.MatchToleration(&toleration) {
+			return true
+		}
+	}
+	return false
+}
+
+// tolerationAllowed reports whether an allowlist entry tolerates at least what
+// toleration does.
+func tolerationAllowed(toleration corev1.Toleration, allowlist []corev1.Toleration) bool {
+	for _, allowed := range append(append([]corev1.Toleration{}, automaticTolerations...), allowlist...) {
+		if allowed.Key != toleration.Key && !(allowed.Key == "" && allowed.Operator == corev1.TolerationOpExists) {
+			continue
+		}
+		if allowed.Effect != "" && allowed.Effect != toleration.Effect {
+			continue
+		}
+		if allowed.Operator != corev1.TolerationOpExists && (toleration.Operator == corev1.TolerationOpExists || allowed.Value != toleration.Value) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func formatToleration(toleration corev1.Toleration) string {
+	key := toleration.Key
+	if key == "" {
+		key = "*"
+	}
+	if toleration.Operator != corev1.TolerationOpExists {
+		key += "=" + toleration.Value
+	}
+	if toleration.Effect != "" {
+		key += ":" + string(toleration.Effect)
+	}
+	return key
+}
+
+func init() {
+	RegisterPolicy(&PodNodePlacement{})
+}
@@ -0,0 +1,205 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestTolerationAllowed(t *testing.T) {
+	allowlist := []corev1.Toleration{
+		{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "tenant-a", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "gpu", Operator: corev1.TolerationOpExists},
+	}
+
+	tests := []struct {
+		name       string
+		toleration corev1.Toleration
+		allowlist  []corev1.Toleration
+		want       bool
+	}{
+		{
+			name:       "exact match",
+			toleration: corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "tenant-a", Effect: corev1.TaintEffectNoSchedule},
+			allowlist:  allowlist,
+			want:       true,
+		},
+		{
+			name:       "other value",
+			toleration: corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "tenant-b", Effect: corev1.TaintEffectNoSchedule},
+			allowlist:  allowlist,
+		},
+		{
+			name:       "exists tolerates every value",
+			toleration: corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+			allowlist:  allowlist,
+		},
+		{
+			name:       "other effect",
+			toleration: corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "tenant-a", Effect: corev1.TaintEffectNoExecute},
+			allowlist:  allowlist,
+		},
+		{
+			name:       "every effect",
+			toleration: corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "tenant-a"},
+			allowlist:  allowlist,
+		},
+		{
+			name:       "allowed key with any value and effect",
+			toleration: corev1.Toleration{Key: "gpu", Operator: corev1.TolerationOpEqual, Value: "a100", Effect: corev1.TaintEffectNoExecute},
+			allowlist:  allowlist,
+			want:       true,
+		},
+		{
+			name:       "unknown key",
+			toleration: corev1.Toleration{Key: "spot", Operator: corev1.TolerationOpExists},
+			allowlist:  allowlist,
+		},
+		{
+			name:       "every taint",
+			toleration: corev1.Toleration{Operator: corev1.TolerationOpExists},
+			allowlist:  allowlist,
+		},
+		{
+			name:       "empty key with exists allows any key",
+			toleration: corev1.Toleration{Key: "spot", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoSchedule},
+			allowlist:  []corev1.Toleration{{Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}},
+			want:       true,
+		},
+		{
+			name:       "automatic tolerations are always allowed",
+			toleration: corev1.Toleration{Key: corev1.TaintNodeNotReady, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+			allowlist:  []corev1.Toleration{},
+			want:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tolerationAllowed(tt.toleration, tt.allowlist); got != tt.want {
+				t.Errorf("tolerationAllowed(%s) = %v, want %v", formatToleration(tt.toleration), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodNodePlacement(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    map[string]string
+		annotations map[string]string
+		pod         corev1.PodSpec
+		wantOutcome string
+		wantMessage string
+		check       func(t *testing.T, pod *corev1.Pod)
+	}{
+		{
+			name:        "nothing configured",
+			pod:         corev1.PodSpec{Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}}},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "toleration outside the allowlist",
+			settings:    map[string]string{"tolerationsAllowlist": `[{"key": "dedicated", "operator": "Equal", "value": "tenant-a"}]`},
+			pod:         corev1.PodSpec{Tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "tenant-b", Effect: corev1.TaintEffectNoSchedule}}},
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: "tolerations not allowed in Namespace default: dedicated=tenant-b:NoSchedule",
+		},
+		{
+			name:        "empty allowlist allows no tolerations",
+			annotations: map[string]string{"policy-control.aumer.io/tolerations-allowlist": "[]"},
+			pod:         corev1.PodSpec{Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}}},
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: "tolerations not allowed in Namespace default: *",
+		},
+		{
+			name:        "empty annotation opts out of the setting",
+			settings:    map[string]string{"tolerationsAllowlist": "[]"},
+			annotations: map[string]string{"policy-control.aumer.io/tolerations-allowlist": ""},
+			pod:         corev1.PodSpec{Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}}},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "default tolerations are checked against the allowlist",
+			settings:    map[string]string{"defaultTolerations": `[{"key": "spot", "operator": "Exists"}]`, "tolerationsAllowlist": `[{"key": "dedicated", "operator": "Exists"}]`},
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: "spot",
+		},
+		{
+			name:        "default tolerations are added once",
+			annotations: map[string]string{"policy-control.aumer.io/default-tolerations": `[{"key": "dedicated", "operator": "Equal", "value": "tenant-a", "effect": "NoSchedule"}]`},
+			pod:         corev1.PodSpec{Tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "tenant-a", Effect: corev1.TaintEffectNoSchedule}}},
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				if len(pod.Spec.Tolerations) != 1 {
+					t.Errorf("tolerations = %v, want a single one", pod.Spec.Tolerations)
+				}
+			},
+		},
+		{
+			name:        "node selector is merged",
+			annotations: map[string]string{"policy-control.aumer.io/node-selector": "pool=tenant-a"},
+			pod:         corev1.PodSpec{NodeSelector: map[string]string{"kubernetes.io/arch": "arm64"}},
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				if pod.Spec.NodeSelector["pool"] != "tenant-a" || pod.Spec.NodeSelector["kubernetes.io/arch"] != "arm64" {
+					t.Errorf("nodeSelector = %v, want pool and kubernetes.io/arch", pod.Spec.NodeSelector)
+				}
+			},
+		},
+		{
+			name:        "conflicting node selector",
+			annotations: map[string]string{"policy-control.aumer.io/node-selector": "pool=tenant-a"},
+			pod:         corev1.PodSpec{NodeSelector: map[string]string{"pool": "tenant-b"}},
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: "nodeSelector pool=tenant-b conflicts with pool=tenant-a required by Namespace default",
+		},
+		{
+			name:        "node affinity is added to every term",
+			settings:    map[string]string{"nodeAffinity": "!spot"},
+			pod:         corev1.PodSpec{Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{}, {}}}}}},
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				for i, term := range pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+					if len(term.MatchExpressions) != 1 || term.MatchExpressions[0].Key != "spot" || term.MatchExpressions[0].Operator != corev1.NodeSelectorOpDoesNotExist {
+						t.Errorf("term %d = %v, want spot DoesNotExist", i, term.MatchExpressions)
+					}
+				}
+			},
+		},
+		{
+			name:        "invalid allowlist",
+			annotations: map[string]string{"policy-control.aumer.io/tolerations-allowlist": "dedicated"},
+			pod:         corev1.PodSpec{Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}}},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Policies = map[string]config.PolicyConfig{"pod-node-placement": {Settings: tt.settings}}
+			config.Set(cfg)
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			pod := newPod(nil)
+			pod.Spec = tt.pod
+			entry, err := ApplyPolicy(PodNodePlacement{}, pod, newTestEnv(newNamespace(nil, tt.annotations)))
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, tt.wantOutcome)
+			}
+			if !strings.Contains(entry.Message, tt.wantMessage) {
+				t.Errorf("message = %q, want it to contain %q", entry.Message, tt.wantMessage)
+			}
+			if tt.check != nil {
+				tt.check(t, pod)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/image"
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var (
+	registryMirrorAnnotation = annotation.Key{
+		Name:        "registry-mirror",
+		Inherit:     true,
+		Type:        annotation.TypeBool,
+		Default:     "true",
+		Description: "Pull images through the mirrors configured for the Pod Registry Mirror policy. Set it to false to keep the images as written.",
+	}
+	podRegistryMirrorLog = ctrl.Log.WithName("pod_registry_mirror")
+)
+
+// imageCategories select every policy handling container images with the
+// policies annotation.
+var imageCategories = []string{"images"}
+
+// PodRegistryMirror rewrites the images of every container to pull through a
+// mirror. The mirrors setting is a comma separated list of from=to rules, e.g.
+// docker.io=mirror.local/dockerhub,ghcr.io=mirror.local/ghcr. The longest
+// matching rule wins, tags and digests are kept.
+type PodRegistryMirror struct{}
+
+func (p PodRegistryMirror) Name() string {
+	return "Pod Registry Mirror"
+}
+
+func (p PodRegistryMirror) Type() int {
+	return PolicyTypePod
+}
+
+func (p PodRegistryMirror) Admission() bool {
+	return true
+}
+
+func (p PodRegistryMirror) Phase() Phase {
+	return PhaseRewrite
+}
+
+func (p PodRegistryMirror) Validate(obj runtime.Object, env *Env) (error, bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("could not cast object to Pod"), false
+	}
+
+	// Changing the image of a running container restarts it, so only new Pods
+	// and the ephemeral containers an UPDATE adds are rewritten.
+	if len(admittedImages(env, pod)) == 0 {
+		return nil, false
+	}
+
+	parser := annotationParser(env, pod)
+	enabled, _ := parser.Bool(registryMirrorAnnotation)
+	if err := parser.Err(); err != nil {
+		warnInvalidAnnotations(env, p, pod, err)
+		return nil, false
+	}
+	if !enabled {
+		return nil, false
+	}
+
+	mirrors, err := registryMirrors(Config(p))
+	if err != nil || len(mirrors) == 0 {
+		return err, false
+	}
+	copied := pod.DeepCopy()
+	return nil, len(mirrorImages(copied, admittedImages(env, copied), mirrors)) > 0
+}
+
+func (p PodRegistryMirror) Apply(obj runtime.Object, env *Env) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		podRegistryMirrorLog.Error(fmt.Errorf("could not cast object to Pod"), "error casting object to Pod")
+		return nil
+	}
+
+	mirrors, err := registryMirrors(Config(p))
+	if err != nil {
+		return err
+	}
+	for original, mirrored := range mirrorImages(pod, admittedImages(env, pod), mirrors) {
+		podRegistryMirrorLog.Info("Rewrote image to mirror", "namespace", pod.Namespace, "pod", getObjectName(pod), "image", original, "mirror", mirrored)
+	}
+	return nil
+}
+
+func (p PodRegistryMirror) Categories() []string {
+	return imageCategories
+}
+
+func (p PodRegistryMirror) Annotations() []annotation.Key {
+	return []annotation.Key{registryMirrorAnnotation}
+}
+
+func (p PodRegistryMirror) ValidateConfig(policyConfig config.PolicyConfig) error {
+	_, err := registryMirrors(policyConfig)
+	return err
+}
+
+func registryMirrors(policyConfig config.PolicyConfig) ([]image.Mirror, error) {
+	value := policyConfig.Setting("mirrors", "")
+	if value == "" {
+		return nil, nil
+	}
+	rules, err := annotation.SplitList(value)
+	if err != nil {
+		return nil, fmt.Errorf("settings.mirrors: %w", err)
+	}
+
+	mirrors := make([]image.Mirror, 0, len(rules))
+	for _, rule := range rules {
+		mirror, err := image.ParseMirror(rule)
+		if err != nil {
+			return nil, fmt.Errorf("settings.mirrors: %w", err)
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	return mirrors, nil
+}
+
+// mirrorImages rewrites images, which belong to pod, and returns the rewritten
+// images by their original. Images that don't parse are left alone for the API
+// server to reject.
+func mirrorImages(pod *corev1.Pod, images []podImage, mirrors []image.Mirror) map[string]string {
+	rewritten := map[string]string{}
+	for _, container := range images {
+		img := container.Image
+		ref, err := image.Parse(strings.TrimSpace(*img))
+		if err != nil {
+			podRegistryMirrorLog.Info("Skipping invalid image", "namespace", pod.Namespace, "pod", getObjectName(pod), "error", err.Error())
+			continue
+		}
+		mirrored, ok, err := image.Rewrite(ref, mirrors)
+		if err != nil {
+			podRegistryMirrorLog.Info("Unable to rewrite image", "namespace", pod.Namespace, "pod", getObjectName(pod), "image", *img, "error", err.Error())
+			continue
+		}
+		if !ok || mirrored.String() == *img {
+			continue
+		}
+		rewritten[*img] = mirrored.String()
+		*img = mirrored.String()
+	}
+	return rewritten
+}
+
+// podImage is the image of a single container, init container or ephemeral
+// container of a Pod.
+type podImage struct {
+	Container string
+	Image     *string
+}
+
+// podImages returns the image of every container, init container and ephemeral
+// container of pod, in that order.
+func podImages(pod *corev1.Pod) []podImage {
+	var images []podImage
+	for i := range pod.Spec.InitContainers {
+		images = append(images, podImage{Container: pod.Spec.InitContainers[i].Name, Image: &pod.Spec.InitContainers[i].Image})
+	}
+	for i := range pod.Spec.Containers {
+		images = append(images, podImage{Container: pod.Spec.Containers[i].Name, Image: &pod.Spec.Containers[i].Image})
+	}
+	for i := range pod.Spec.EphemeralContainers {
+		images = append(images, podImage{Container: pod.Spec.EphemeralContainers[i].Name, Image: &pod.Spec.EphemeralContainers[i].Image})
+	}
+	return images
+}
+
+// admittedImages returns the images admission may change: every image of a Pod
+// being created, and on UPDATE only those of the ephemeral containers the old
+// Pod doesn't have. Ephemeral containers are added through an UPDATE of the
+// ephemeralcontainers subresource, everything else is fixed once the Pod runs.
+func admittedImages(env *Env, pod *corev1.Pod) []podImage {
+	if env.creating() {
+		return podImages(pod)
+	}
+	var images []podImage
+	for _, container := range addedEphemeralContainers(env, pod) {
+		images = append(images, podImage{Container: container.Name, Image: &container.Image})
+	}
+	return images
+}
+
+// addedEphemeralContainers returns the ephemeral containers of pod that the old
+// object of an admission UPDATE doesn't have, pointing into pod.
+func addedEphemeralContainers(env *Env, pod *corev1.Pod) []*corev1.EphemeralContainer {
+	old, ok := env.OldObject.(*corev1.Pod)
+	if !ok {
+		return nil
+	}
+	existing := map[string]bool{}
+	for _, container := range old.Spec.EphemeralContainers {
+		existing[container.Name] = true
+	}
+
+	var added []*corev1.EphemeralContainer
+	for i := range pod.Spec.EphemeralContainers {
+		if !existing[pod.Spec.EphemeralContainers[i].Name] {
+			added = append(added, &pod.Spec.EphemeralContainers[i])
+		}
+	}
+	return added
+}
+
+func init() {
+	RegisterPolicy(&PodRegistryMirror{})
+}
@@ -0,0 +1,92 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPodRegistryMirrorOperations(t *testing.T) {
+	running := newPod(nil, corev1.Container{Name: "web", Image: "nginx:1.25"})
+	running.Spec.InitContainers = []corev1.Container{{Name: "migrate", Image: "ghcr.io/org/migrate:v2"}}
+	running.Spec.EphemeralContainers = []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+		Name: "debug", Image: "busybox:1.36",
+	}}}
+	debugged := running.DeepCopy()
+	debugged.Spec.EphemeralContainers = append(debugged.Spec.EphemeralContainers, corev1.EphemeralContainer{EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+		Name: "debug-2", Image: "busybox:1.36",
+	}})
+	relabelled := running.DeepCopy()
+	relabelled.Labels = map[string]string{"app": "web"}
+
+	tests := []struct {
+		name        string
+		operation   string
+		oldObject   *corev1.Pod
+		pod         *corev1.Pod
+		wantOutcome string
+		wantImages  []string
+	}{
+		{
+			name:        "create rewrites every container",
+			operation:   "CREATE",
+			pod:         running.DeepCopy(),
+			wantOutcome: audit.OutcomeApplied,
+			wantImages:  []string{"mirror.local/ghcr/org/migrate:v2", "mirror.local/dockerhub/library/nginx:1.25", "mirror.local/dockerhub/library/busybox:1.36"},
+		},
+		{
+			name:        "update leaves running containers alone",
+			operation:   "UPDATE",
+			oldObject:   running,
+			pod:         relabelled,
+			wantOutcome: audit.OutcomeSkipped,
+			wantImages:  []string{"ghcr.io/org/migrate:v2", "nginx:1.25", "busybox:1.36"},
+		},
+		{
+			name:        "update rewrites added ephemeral containers only",
+			operation:   "UPDATE",
+			oldObject:   running,
+			pod:         debugged,
+			wantOutcome: audit.OutcomeApplied,
+			wantImages:  []string{"ghcr.io/org/migrate:v2", "nginx:1.25", "busybox:1.36", "mirror.local/dockerhub/library/busybox:1.36"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Policies = map[string]config.PolicyConfig{"pod-registry-mirror": {Settings: map[string]string{"mirrors": "docker.io=mirror.local/dockerhub,ghcr.io=mirror.local/ghcr"}}}
+			config.Set(cfg)
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			env := newTestEnv()
+			env.Operation = tt.operation
+			if tt.oldObject != nil {
+				env.OldObject = tt.oldObject
+			}
+			entry, err := ApplyPolicy(PodRegistryMirror{}, tt.pod, env)
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, tt.wantOutcome)
+			}
+
+			var images []string
+			for _, container := range podImages(tt.pod) {
+				images = append(images, *container.Image)
+			}
+			if len(images) != len(tt.wantImages) {
+				t.Fatalf("images = %v, want %v", images, tt.wantImages)
+			}
+			for i := range images {
+				if images[i] != tt.wantImages[i] {
+					t.Errorf("images = %v, want %v", images, tt.wantImages)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var (
+	securityDefaultsExemptAnnotation = annotation.Key{
+		Name:    "security-defaults-exempt",
+		Inherit: true,
+		Type:    annotation.TypeList,
+		Description: "Comma separated containers left as they are, e.g. legacy. A single default is skipped with container:field, " +
+			"where field is runAsNonRoot, seccompProfile, allowPrivilegeEscalation, capabilities or readOnlyRootFilesystem.",
+	}
+	readOnlyRootFilesystemAnnotation = annotation.Key{
+		Name:        "read-only-root-filesystem",
+		Inherit:     true,
+		Type:        annotation.TypeBool,
+		Description: "Also default readOnlyRootFilesystem to true, with an emptyDir mounted at /tmp. Defaults to the readOnlyRootFilesystem setting of the policy.",
+	}
+	podSecurityDefaultsLog = ctrl.Log.WithName("pod_security_defaults")
+)
+
+const (
+	securityFieldRunAsNonRoot             = "runAsNonRoot"
+	securityFieldSeccompProfile           = "seccompProfile"
+	securityFieldAllowPrivilegeEscalation = "allowPrivilegeEscalation"
+	securityFieldCapabilities             = "capabilities"
+	securityFieldReadOnlyRootFilesystem   = "readOnlyRootFilesystem"
+
+	// tmpVolumeName is the emptyDir mounted at /tmp in containers with a read
+	// only root filesystem.
+	tmpVolumeName = "policy-control-tmp"
+	tmpMountPath  = "/tmp"
+)
+
+var securityFields = []string{
+	securityFieldRunAsNonRoot,
+	securityFieldSeccompProfile,
+	securityFieldAllowPrivilegeEscalation,
+	securityFieldCapabilities,
+	securityFieldReadOnlyRootFilesystem,
+}
+
+// securityCategories select every policy hardening Pods with the policies
+// annotation.
+var securityCategories = []string{"security"}
+
+// PodSecurityDefaults sets hardened defaults for the securityContext fields a
+// container and its Pod leave unset: runAsNonRoot, the RuntimeDefault seccomp
+// profile, no privilege escalation, all capabilities dropped and optionally a
+// read only root filesystem. Values set on the container or the Pod are never
+// overridden. Defaults are set per container so that a single container can be
+// exempted.
+type PodSecurityDefaults struct{}
+
+// securityExemptions are the exempted fields by container name, an empty set
+// exempts the whole container.
+type securityExemptions map[string]map[string]bool
+
+func (e securityExemptions) exempt(container string, field string) bool {
+	fields, ok := e[container]
+	return ok && (len(fields) == 0 || fields[field])
+}
+
+func (p PodSecurityDefaults) Name() string {
+	return "Pod Security Defaults"
+}
+
+func (p PodSecurityDefaults) Type() int {
+	return PolicyTypePod
+}
+
+func (p PodSecurityDefaults) Admission() bool {
+	return true
+}
+
+func (p PodSecurityDefaults) Phase() Phase {
+	return PhaseRewrite
+}
+
+func (p PodSecurityDefaults) Validate(obj runtime.Object, env *Env) (error, bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("could not cast object to Pod"), false
+	}
+	// The securityContext can't be changed once the Pod exists, and most of it
+	// doesn't apply to Windows.
+	if !env.creating() || (pod.Spec.OS != nil && pod.Spec.OS.Name == corev1.Windows) {
+		return nil, false
+	}
+
+	exemptions, readOnly, err := podSecurityDefaultsSettings(annotationParser(env, pod), Config(p))
+	if err != nil {
+		warnInvalidAnnotations(env, p, pod, err)
+		return nil, false
+	}
+	return nil, applySecurityDefaults(pod.DeepCopy(), exemptions, readOnly)
+}
+
+func (p PodSecurityDefaults) Apply(obj runtime.Object, env *Env) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		podSecurityDefaultsLog.Error(fmt.Errorf("could not cast object to Pod"), "error casting object to Pod")
+		return nil
+	}
+
+	exemptions, readOnly, err := podSecurityDefaultsSettings(annotationParser(env, pod), Config(p))
+	if err != nil {
+		return err
+	}
+	if applySecurityDefaults(pod, exemptions, readOnly) {
+		podSecurityDefaultsLog.Info("Defaulted securityContext", "namespace", pod.Namespace, "pod", getObjectName(pod))
+	}
+	return nil
+}
+
+func (p PodSecurityDefaults) Categories() []string {
+	return securityCategories
+}
+
+func (p PodSecurityDefaults) Annotations() []annotation.Key {
+	return []annotation.Key{securityDefaultsExemptAnnotation, readOnlyRootFilesystemAnnotation}
+}
+
+func (p PodSecurityDefaults) ValidateConfig(policyConfig config.PolicyConfig) error {
+	if readOnly := policyConfig.Setting("readOnlyRootFilesystem", ""); readOnly != "" {
+		if _, err := annotation.ParseBool(readOnly); err != nil {
+			return fmt.Errorf("settings.readOnlyRootFilesystem: %w", err)
+		}
+	}
+	return nil
+}
+
+func podSecurityDefaultsSettings(parser *annotation.Parser, policyConfig config.PolicyConfig) (securityExemptions, bool, error) {
+	whole := map[string]bool{}
+	fields := map[string]map[string]bool{}
+	for _, entry := range parser.List(securityDefaultsExemptAnnotation) {
+		container, field, scoped := strings.Cut(entry, ":")
+		if !scoped {
+			whole[container] = true
+			continue
+		}
+		if !isSecurityField(field) {
+			return nil, false, fmt.Errorf("annotation %s: unknown field %q in %q, expected one of %s", securityDefaultsExemptAnnotation.FullName(), field, entry, strings.Join(securityFields, ", "))
+		}
+		if fields[container] == nil {
+			fields[container] = map[string]bool{}
+		}
+		fields[container][field] = true
+	}
+
+	exemptions := securityExemptions{}
+	for container, exempted := range fields {
+		exemptions[container] = exempted
+	}
+	// Listing a container without a field exempts all of it.
+	for container := range whole {
+		exemptions[container] = map[string]bool{}
+	}
+
+	readOnly, set := parser.Bool(readOnlyRootFilesystemAnnotation)
+	if !set {
+		readOnly, _ = annotation.ParseBool(policyConfig.Setting("readOnlyRootFilesystem", "false"))
+	}
+	return exemptions, readOnly, parser.Err()
+}
+
+func isSecurityField(field string) bool {
+	for _, known := range securityFields {
+		if field == known {
+			return true
+		}
+	}
+	return false
+}
+
+// applySecurityDefaults sets the defaults on every container and init container
+// of pod that isn't exempt, and reports whether anything changed.
+func applySecurityDefaults(pod *corev1.Pod, exemptions securityExemptions, readOnly bool) bool {
+	changed := false
+	needsTmp := false
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			containerChanged, mountTmp := applyContainerSecurityDefaults(pod, &containers[i], exemptions, readOnly)
+			changed = changed || containerChanged
+			needsTmp = needsTmp || mountTmp
+		}
+	}
+
+	if needsTmp && !hasVolume(pod, tmpVolumeName) {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name:         tmpVolumeName,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+	}
+	return changed
+}
+
+// applyContainerSecurityDefaults defaults the securityContext of container. The
+// second result reports whether the container got the /tmp emptyDir mounted.
+func applyContainerSecurityDefaults(pod *corev1.Pod, container *corev1.Container, exemptions securityExemptions, readOnly bool) (bool, bool) {
+	podContext := pod.Spec.SecurityContext
+	if podContext == nil {
+		podContext = &corev1.PodSecurityContext{}
+	}
+	created := container.SecurityContext == nil
+	if created {
+		container.SecurityContext = &corev1.SecurityContext{}
+	}
+	sc := container.SecurityContext
+	exempt := func(field string) bool {
+		return exemptions.exempt(container.Name, field)
+	}
+
+	changed := false
+	if !exempt(securityFieldRunAsNonRoot) && sc.RunAsNonRoot == nil && podContext.RunAsNonRoot == nil {
+		runAsNonRoot := true
+		sc.RunAsNonRoot = &runAsNonRoot
+		changed = true
+	}
+	if !exempt(securityFieldSeccompProfile) && sc.SeccompProfile == nil && podContext.SeccompProfile == nil {
+		sc.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+		changed = true
+	}
+	// Privileged containers can't turn privilege escalation off.
+	if !exempt(securityFieldAllowPrivilegeEscalation) && sc.AllowPrivilegeEscalation == nil && (sc.Privileged == nil || !*sc.Privileged) {
+		allowPrivilegeEscalation := false
+		sc.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+		changed = true
+	}
+	if !exempt(securityFieldCapabilities) && (sc.Capabilities == nil || len(sc.Capabilities.Drop) == 0) {
+		if sc.Capabilities == nil {
+			sc.Capabilities = &corev1.Capabilities{}
+		}
+		sc.Capabilities.Drop = []corev1.Capability{"ALL"}
+		changed = true
+	}
+
+	mountTmp := false
+	if readOnly && !exempt(securityFieldReadOnlyRootFilesystem) && sc.ReadOnlyRootFilesystem == nil {
+		readOnlyRootFilesystem := true
+		sc.ReadOnlyRootFilesystem = &readOnlyRootFilesystem
+		changed = true
+
+		if !hasMount(container, tmpMountPath) {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: tmpVolumeName, MountPath: tmpMountPath})
+			mountTmp = true
+		}
+	}
+
+	if !changed && created {
+		container.SecurityContext = nil
+	}
+	return changed, mountTmp
+}
+
+func hasMount(container *corev1.Container, path string) bool {
+	for _, mount := range container.VolumeMounts {
+		if strings.TrimSuffix(mount.MountPath, "/") == path {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVolume(pod *corev1.Pod, name string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	RegisterPolicy(&PodSecurityDefaults{})
+}
@@ -0,0 +1,223 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// hardened reports whether sc has every default except readOnlyRootFilesystem.
+func hardened(sc *corev1.SecurityContext) bool {
+	return sc != nil &&
+		sc.RunAsNonRoot != nil && *sc.RunAsNonRoot &&
+		sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeRuntimeDefault &&
+		sc.AllowPrivilegeEscalation != nil && !*sc.AllowPrivilegeEscalation &&
+		sc.Capabilities != nil && len(sc.Capabilities.Drop) == 1 && sc.Capabilities.Drop[0] == "ALL"
+}
+
+func TestPodSecurityDefaults(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name        string
+		settings    map[string]string
+		operation   string
+		pod         *corev1.Pod
+		wantOutcome string
+		check       func(t *testing.T, pod *corev1.Pod)
+	}{
+		{
+			name:        "defaults every container",
+			pod:         newPod(nil, corev1.Container{Name: "web"}, corev1.Container{Name: "sidecar"}),
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				for _, container := range pod.Spec.Containers {
+					if !hardened(container.SecurityContext) {
+						t.Errorf("container %s securityContext = %+v, want the defaults", container.Name, container.SecurityContext)
+					}
+					if container.SecurityContext.ReadOnlyRootFilesystem != nil {
+						t.Errorf("container %s readOnlyRootFilesystem set without the setting", container.Name)
+					}
+				}
+				if len(pod.Spec.Volumes) != 0 {
+					t.Errorf("volumes = %v, want none", pod.Spec.Volumes)
+				}
+			},
+		},
+		{
+			name: "values on the container are kept",
+			pod: newPod(nil, corev1.Container{Name: "web", SecurityContext: &corev1.SecurityContext{
+				RunAsNonRoot:             &no,
+				AllowPrivilegeEscalation: &yes,
+				Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"NET_RAW"}},
+			}}),
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				sc := pod.Spec.Containers[0].SecurityContext
+				if *sc.RunAsNonRoot || !*sc.AllowPrivilegeEscalation || sc.Capabilities.Drop[0] != "NET_RAW" {
+					t.Errorf("securityContext = %+v, want the values of the container kept", sc)
+				}
+				if sc.SeccompProfile == nil {
+					t.Errorf("seccompProfile = nil, want RuntimeDefault")
+				}
+			},
+		},
+		{
+			name: "values on the Pod are kept",
+			pod: func() *corev1.Pod {
+				pod := newPod(nil, corev1.Container{Name: "web"})
+				pod.Spec.SecurityContext = &corev1.PodSecurityContext{
+					RunAsNonRoot:   &no,
+					SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined},
+				}
+				return pod
+			}(),
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				sc := pod.Spec.Containers[0].SecurityContext
+				if sc.RunAsNonRoot != nil || sc.SeccompProfile != nil {
+					t.Errorf("securityContext = %+v, want runAsNonRoot and seccompProfile left to the Pod", sc)
+				}
+			},
+		},
+		{
+			name: "privileged containers may escalate",
+			pod: newPod(nil, corev1.Container{Name: "web", SecurityContext: &corev1.SecurityContext{
+				Privileged: &yes,
+			}}),
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				if sc := pod.Spec.Containers[0].SecurityContext; sc.AllowPrivilegeEscalation != nil {
+					t.Errorf("allowPrivilegeEscalation = %v, want unset", *sc.AllowPrivilegeEscalation)
+				}
+			},
+		},
+		{
+			name:        "exempt container",
+			pod:         newPod(map[string]string{"policy-control.aumer.io/security-defaults-exempt": "legacy"}, corev1.Container{Name: "web"}, corev1.Container{Name: "legacy"}),
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				if !hardened(pod.Spec.Containers[0].SecurityContext) {
+					t.Errorf("container web securityContext = %+v, want the defaults", pod.Spec.Containers[0].SecurityContext)
+				}
+				if sc := pod.Spec.Containers[1].SecurityContext; sc != nil {
+					t.Errorf("container legacy securityContext = %+v, want nil", sc)
+				}
+			},
+		},
+		{
+			name:        "exempt field",
+			pod:         newPod(map[string]string{"policy-control.aumer.io/security-defaults-exempt": "web:runAsNonRoot"}, corev1.Container{Name: "web"}),
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				sc := pod.Spec.Containers[0].SecurityContext
+				if sc.RunAsNonRoot != nil {
+					t.Errorf("runAsNonRoot = %v, want unset", *sc.RunAsNonRoot)
+				}
+				if sc.SeccompProfile == nil || sc.Capabilities == nil {
+					t.Errorf("securityContext = %+v, want the other defaults", sc)
+				}
+			},
+		},
+		{
+			name:        "unknown exempt field",
+			pod:         newPod(map[string]string{"policy-control.aumer.io/security-defaults-exempt": "web:privileged"}, corev1.Container{Name: "web"}),
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "read only root filesystem from settings",
+			settings:    map[string]string{"readOnlyRootFilesystem": "true"},
+			pod:         newPod(nil, corev1.Container{Name: "web"}, corev1.Container{Name: "sidecar"}),
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				for _, container := range pod.Spec.Containers {
+					if sc := container.SecurityContext; sc.ReadOnlyRootFilesystem == nil || !*sc.ReadOnlyRootFilesystem {
+						t.Errorf("container %s readOnlyRootFilesystem = %v, want true", container.Name, sc.ReadOnlyRootFilesystem)
+					}
+					if len(container.VolumeMounts) != 1 || container.VolumeMounts[0].Name != tmpVolumeName || container.VolumeMounts[0].MountPath != tmpMountPath {
+						t.Errorf("container %s mounts = %v, want %s at %s", container.Name, container.VolumeMounts, tmpVolumeName, tmpMountPath)
+					}
+				}
+				if len(pod.Spec.Volumes) != 1 || pod.Spec.Volumes[0].Name != tmpVolumeName || pod.Spec.Volumes[0].EmptyDir == nil {
+					t.Errorf("volumes = %v, want a single %s emptyDir", pod.Spec.Volumes, tmpVolumeName)
+				}
+			},
+		},
+		{
+			name:     "existing /tmp mount is kept",
+			settings: map[string]string{"readOnlyRootFilesystem": "true"},
+			pod: newPod(nil, corev1.Container{Name: "web", VolumeMounts: []corev1.VolumeMount{
+				{Name: "scratch", MountPath: "/tmp/"},
+			}}),
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				if mounts := pod.Spec.Containers[0].VolumeMounts; len(mounts) != 1 || mounts[0].Name != "scratch" {
+					t.Errorf("mounts = %v, want only scratch", mounts)
+				}
+				if len(pod.Spec.Volumes) != 0 {
+					t.Errorf("volumes = %v, want none", pod.Spec.Volumes)
+				}
+			},
+		},
+		{
+			name:        "annotation overrides settings",
+			settings:    map[string]string{"readOnlyRootFilesystem": "true"},
+			pod:         newPod(map[string]string{"policy-control.aumer.io/read-only-root-filesystem": "false"}, corev1.Container{Name: "web"}),
+			wantOutcome: audit.OutcomeApplied,
+			check: func(t *testing.T, pod *corev1.Pod) {
+				if sc := pod.Spec.Containers[0].SecurityContext; sc.ReadOnlyRootFilesystem != nil {
+					t.Errorf("readOnlyRootFilesystem = %v, want unset", *sc.ReadOnlyRootFilesystem)
+				}
+			},
+		},
+		{
+			name: "nothing left to default",
+			pod: newPod(nil, corev1.Container{Name: "web", SecurityContext: &corev1.SecurityContext{
+				RunAsNonRoot:             &yes,
+				SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+				AllowPrivilegeEscalation: &no,
+				Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+			}}),
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "updates are skipped",
+			operation:   "UPDATE",
+			pod:         newPod(nil, corev1.Container{Name: "web"}),
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name: "windows Pods are skipped",
+			pod: func() *corev1.Pod {
+				pod := newPod(nil, corev1.Container{Name: "web"})
+				pod.Spec.OS = &corev1.PodOS{Name: corev1.Windows}
+				return pod
+			}(),
+			wantOutcome: audit.OutcomeSkipped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Policies = map[string]config.PolicyConfig{"pod-security-defaults": {Settings: tt.settings}}
+			config.Set(cfg)
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			env := newTestEnv()
+			env.Operation = tt.operation
+			entry, err := ApplyPolicy(PodSecurityDefaults{}, tt.pod, env)
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, tt.wantOutcome)
+			}
+			if tt.check != nil {
+				tt.check(t, tt.pod)
+			}
+		})
+	}
+}
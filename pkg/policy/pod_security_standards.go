@@ -0,0 +1,240 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/internal/pss"
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// The Pod Security Standards annotations are only read from the Namespace, so
+// that a workload can't lower its own level.
+var (
+	podSecurityLevelAnnotation = annotation.Key{
+		Name:        "pod-security-level",
+		Type:        annotation.TypeEnum,
+		Allowed:     []string{string(pss.LevelPrivileged), string(pss.LevelBaseline), string(pss.LevelRestricted)},
+		Description: "Pod Security Standards level enforced on Pods in the Namespace. Only read from Namespaces, overrides the levels and level settings of the policy.",
+	}
+	podSecurityExemptAnnotation = annotation.Key{
+		Name: "pod-security-exempt",
+		Type: annotation.TypeList,
+		Description: "Comma separated Pod Security Standards checks skipped in the Namespace, e.g. runAsUser, or check:container to skip a check for " +
+			"containers of that name only. Only read from Namespaces, added to the exemptions setting of the policy.",
+	}
+	podSecurityStandardsLog = ctrl.Log.WithName("pod_security_standards")
+)
+
+// PodSecurityStandards checks Pods against the baseline or restricted Pod
+// Security Standards. The level of a Namespace is its pod-security-level
+// annotation, or the first rule of the levels setting whose label selector
+// matches the Namespace, or the level setting. Rules are separated by
+// semicolons, e.g. "privileged:kubernetes.io/metadata.name in (kube-system);
+// restricted:team". Namespaces without a level aren't checked.
+//
+// On UPDATE only the ephemeral containers being added are checked, nothing else
+// the checks read can change once the Pod exists.
+//
+// Violations are listed by check ID, in audit mode as admission warnings.
+type PodSecurityStandards struct{}
+
+// podSecurityLevelRule assigns a level to the Namespaces matching a selector.
+type podSecurityLevelRule struct {
+	level    pss.Level
+	selector labels.Selector
+}
+
+func (p PodSecurityStandards) Name() string {
+	return "Pod Security Standards"
+}
+
+func (p PodSecurityStandards) Type() int {
+	return PolicyTypePod
+}
+
+func (p PodSecurityStandards) Admission() bool {
+	return true
+}
+
+func (p PodSecurityStandards) Phase() Phase {
+	return PhaseValidate
+}
+
+func (p PodSecurityStandards) Validate(obj runtime.Object, env *Env) (error, bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("could not cast object to Pod"), false
+	}
+	if !env.creating() && len(addedEphemeralContainers(env, pod)) == 0 {
+		return nil, false
+	}
+
+	level, _, err := p.resolve(env, pod)
+	if err != nil {
+		warnInvalidAnnotations(env, p, pod, err)
+		return nil, false
+	}
+	return nil, level != pss.LevelPrivileged
+}
+
+func (p PodSecurityStandards) Apply(obj runtime.Object, env *Env) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		podSecurityStandardsLog.Error(fmt.Errorf("could not cast object to Pod"), "error casting object to Pod")
+		return nil
+	}
+
+	level, exemptions, err := p.resolve(env, pod)
+	if err != nil {
+		return err
+	}
+
+	violations := pss.Evaluate(pod, level, exemptions)
+	if !env.creating() {
+		violations = addedContainerViolations(env, pod, violations)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		messages = append(messages, violation.String())
+	}
+	return Deny("violates Pod Security Standards level %s: %s", level, strings.Join(messages, "; "))
+}
+
+func (p PodSecurityStandards) Categories() []string {
+	return securityCategories
+}
+
+func (p PodSecurityStandards) Annotations() []annotation.Key {
+	return []annotation.Key{podSecurityLevelAnnotation, podSecurityExemptAnnotation}
+}
+
+func (p PodSecurityStandards) ValidateConfig(policyConfig config.PolicyConfig) error {
+	if level := policyConfig.Setting("level", ""); level != "" {
+		if _, err := pss.ParseLevel(level); err != nil {
+			return fmt.Errorf("settings.level: %w", err)
+		}
+	}
+	if _, err := podSecurityLevelRules(policyConfig); err != nil {
+		return err
+	}
+	if _, err := podSecurityExemptions(policyConfig.Setting("exemptions", "")); err != nil {
+		return fmt.Errorf("settings.exemptions: %w", err)
+	}
+	return nil
+}
+
+// resolve returns the level and exemptions for the Namespace of pod.
+func (p PodSecurityStandards) resolve(env *Env, pod *corev1.Pod) (pss.Level, []pss.Exemption, error) {
+	policyConfig := Config(p)
+
+	namespace, err := getNamespace(env, pod.Namespace)
+	if err != nil {
+		return "", nil, err
+	}
+
+	exemptions, err := podSecurityExemptions(policyConfig.Setting("exemptions", ""))
+	if err != nil {
+		return "", nil, err
+	}
+	if value, ok := podSecurityExemptAnnotation.Value(namespace.Annotations); ok {
+		namespaceExemptions, err := podSecurityExemptions(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("annotation %s on Namespace %s: %w", podSecurityExemptAnnotation.FullName(), namespace.Name, err)
+		}
+		exemptions = append(exemptions, namespaceExemptions...)
+	}
+
+	if value, ok := podSecurityLevelAnnotation.Value(namespace.Annotations); ok {
+		level, err := pss.ParseLevel(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("annotation %s on Namespace %s: %w", podSecurityLevelAnnotation.FullName(), namespace.Name, err)
+		}
+		return level, exemptions, nil
+	}
+
+	rules, err := podSecurityLevelRules(policyConfig)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, rule := range rules {
+		if rule.selector.Matches(labels.Set(namespace.Labels)) {
+			return rule.level, exemptions, nil
+		}
+	}
+
+	level, err := pss.ParseLevel(policyConfig.Setting("level", string(pss.LevelPrivileged)))
+	return level, exemptions, err
+}
+
+// addedContainerViolations keeps the violations of the ephemeral containers an
+// UPDATE adds. Names are unique across every kind of container of a Pod.
+func addedContainerViolations(env *Env, pod *corev1.Pod, violations []pss.Violation) []pss.Violation {
+	added := map[string]bool{}
+	for _, container := range addedEphemeralContainers(env, pod) {
+		added[container.Name] = true
+	}
+
+	var kept []pss.Violation
+	for _, violation := range violations {
+		if added[violation.Container] {
+			kept = append(kept, violation)
+		}
+	}
+	return kept
+}
+
+func podSecurityLevelRules(policyConfig config.PolicyConfig) ([]podSecurityLevelRule, error) {
+	var rules []podSecurityLevelRule
+	for _, rule := range strings.Split(policyConfig.Setting("levels", ""), ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		value, expression, ok := strings.Cut(rule, ":")
+		if !ok {
+			return nil, fmt.Errorf("settings.levels: rule %q: expected level:selector", rule)
+		}
+		level, err := pss.ParseLevel(value)
+		if err != nil {
+			return nil, fmt.Errorf("settings.levels: rule %q: %w", rule, err)
+		}
+		selector, err := labels.Parse(strings.TrimSpace(expression))
+		if err != nil {
+			return nil, fmt.Errorf("settings.levels: rule %q: %w", rule, err)
+		}
+		rules = append(rules, podSecurityLevelRule{level: level, selector: selector})
+	}
+	return rules, nil
+}
+
+func podSecurityExemptions(value string) ([]pss.Exemption, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	entries, err := annotation.SplitList(value)
+	if err != nil {
+		return nil, err
+	}
+
+	exemptions := make([]pss.Exemption, 0, len(entries))
+	for _, entry := range entries {
+		exemption, err := pss.ParseExemption(entry)
+		if err != nil {
+			return nil, err
+		}
+		exemptions = append(exemptions, exemption)
+	}
+	return exemptions, nil
+}
+
+func init() {
+	RegisterPolicy(&PodSecurityStandards{})
+}
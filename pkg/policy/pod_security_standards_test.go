@@ -0,0 +1,180 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPodSecurityStandards(t *testing.T) {
+	privileged := true
+
+	tests := []struct {
+		name        string
+		mode        string
+		settings    map[string]string
+		namespace   *corev1.Namespace
+		annotations map[string]string
+		pod         corev1.PodSpec
+		operation   string
+		// ephemeral is added to pod by an UPDATE, pod is the old object then.
+		ephemeral   *corev1.EphemeralContainer
+		wantOutcome string
+		wantMessage string
+	}{
+		{
+			name:        "no level",
+			pod:         corev1.PodSpec{HostNetwork: true},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "level from settings",
+			settings:    map[string]string{"level": "baseline"},
+			pod:         corev1.PodSpec{HostNetwork: true},
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: "violates Pod Security Standards level baseline: hostNamespaces: hostNetwork must not be true",
+		},
+		{
+			name:        "compliant Pod",
+			settings:    map[string]string{"level": "baseline"},
+			pod:         corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			wantOutcome: audit.OutcomeApplied,
+		},
+		{
+			name:        "audit mode does not deny",
+			mode:        config.ModeAudit,
+			settings:    map[string]string{"level": "baseline"},
+			pod:         corev1.PodSpec{HostNetwork: true},
+			wantOutcome: audit.OutcomeAudited,
+			wantMessage: "hostNamespaces: hostNetwork must not be true",
+		},
+		{
+			name:        "first matching rule wins",
+			settings:    map[string]string{"level": "restricted", "levels": "privileged:team=infra; baseline:team"},
+			namespace:   newNamespace(map[string]string{"team": "web"}, nil),
+			pod:         corev1.PodSpec{HostNetwork: true, Containers: []corev1.Container{{Name: "web"}}},
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: "violates Pod Security Standards level baseline: hostNamespaces: hostNetwork must not be true",
+		},
+		{
+			name:        "privileged rule",
+			settings:    map[string]string{"level": "restricted", "levels": "privileged:team=infra; baseline:team"},
+			namespace:   newNamespace(map[string]string{"team": "infra"}, nil),
+			pod:         corev1.PodSpec{HostNetwork: true},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "namespace annotation overrides settings",
+			settings:    map[string]string{"level": "restricted", "levels": "baseline:team"},
+			namespace:   newNamespace(map[string]string{"team": "web"}, map[string]string{"policy-control.aumer.io/pod-security-level": "privileged"}),
+			pod:         corev1.PodSpec{HostNetwork: true},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "pod annotation is ignored",
+			settings:    map[string]string{"level": "baseline"},
+			annotations: map[string]string{"policy-control.aumer.io/pod-security-level": "privileged"},
+			pod:         corev1.PodSpec{HostNetwork: true},
+			wantOutcome: audit.OutcomeDenied,
+		},
+		{
+			name:        "exemptions from settings and namespace",
+			settings:    map[string]string{"level": "baseline", "exemptions": "hostNamespaces"},
+			namespace:   newNamespace(nil, map[string]string{"policy-control.aumer.io/pod-security-exempt": "hostPorts:web"}),
+			pod:         corev1.PodSpec{HostNetwork: true, Containers: []corev1.Container{{Name: "web", Ports: []corev1.ContainerPort{{HostPort: 80}}}}},
+			wantOutcome: audit.OutcomeApplied,
+		},
+		{
+			name:        "invalid namespace annotation",
+			settings:    map[string]string{"level": "baseline"},
+			namespace:   newNamespace(nil, map[string]string{"policy-control.aumer.io/pod-security-level": "strict"}),
+			pod:         corev1.PodSpec{HostNetwork: true},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "updates are skipped",
+			settings:    map[string]string{"level": "baseline"},
+			operation:   "UPDATE",
+			pod:         corev1.PodSpec{HostNetwork: true},
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "added ephemeral container is checked",
+			settings:    map[string]string{"level": "restricted"},
+			operation:   "UPDATE",
+			pod:         corev1.PodSpec{HostNetwork: true, Containers: []corev1.Container{{Name: "web"}}},
+			ephemeral:   &corev1.EphemeralContainer{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug", SecurityContext: &corev1.SecurityContext{Privileged: &privileged}}},
+			wantOutcome: audit.OutcomeDenied,
+			wantMessage: "privileged: container debug must not set securityContext.privileged=true",
+		},
+		{
+			name:        "only the added ephemeral container is checked",
+			settings:    map[string]string{"level": "baseline"},
+			operation:   "UPDATE",
+			pod:         corev1.PodSpec{HostNetwork: true, Containers: []corev1.Container{{Name: "web", SecurityContext: &corev1.SecurityContext{Privileged: &privileged}}}},
+			ephemeral:   &corev1.EphemeralContainer{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug"}},
+			wantOutcome: audit.OutcomeApplied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Policies = map[string]config.PolicyConfig{"pod-security-standards": {Mode: tt.mode, Settings: tt.settings}}
+			config.Set(cfg)
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			namespace := tt.namespace
+			if namespace == nil {
+				namespace = newNamespace(nil, nil)
+			}
+			pod := newPod(tt.annotations)
+			pod.Spec = tt.pod
+			env := newTestEnv(namespace)
+			env.Operation = tt.operation
+			if tt.ephemeral != nil {
+				env.OldObject = pod
+				pod = pod.DeepCopy()
+				pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, *tt.ephemeral)
+			}
+
+			entry, err := ApplyPolicy(PodSecurityStandards{}, pod, env)
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, tt.wantOutcome)
+			}
+			if !strings.Contains(entry.Message, tt.wantMessage) {
+				t.Errorf("message = %q, want it to contain %q", entry.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestPodSecurityStandardsValidateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		wantErr  bool
+	}{
+		{name: "empty"},
+		{name: "valid", settings: map[string]string{"level": "baseline", "levels": "privileged:kubernetes.io/metadata.name in (kube-system); restricted:team", "exemptions": "runAsUser,hostPorts:web"}},
+		{name: "unknown level", settings: map[string]string{"level": "strict"}, wantErr: true},
+		{name: "rule without selector", settings: map[string]string{"levels": "baseline"}, wantErr: true},
+		{name: "invalid selector", settings: map[string]string{"levels": "baseline:team in ("}, wantErr: true},
+		{name: "unknown exemption", settings: map[string]string{"exemptions": "hostPort"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := PodSecurityStandards{}.ValidateConfig(config.PolicyConfig{Settings: tt.settings})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
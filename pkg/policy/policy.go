@@ -0,0 +1,551 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"gomodules.xyz/jsonpatch/v2"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	policyLog = ctrl.Log.WithName("policy")
+)
+
+const (
+	PolicyTypeUnknown = iota
+	PolicyTypePod
+	PolicyTypeIngress
+	PolicyTypeService
+	PolicyTypeDeployment
+	PolicyTypeStatefulSet
+	PolicyTypeNamespace
+)
+
+var policyRegistry []PolicyInterface
+
+type PolicyInterface interface {
+	Name() string
+	Validate(obj runtime.Object, env *Env) (error, bool)
+	Apply(obj runtime.Object, env *Env) error
+	Type() int
+}
+
+// Env is how a policy reaches the cluster. The controller builds it from the
+// manager client, the eval command from an in-memory store.
+type Env struct {
+	Client client.Client
+	// Recorder is nil outside the cluster, Events are dropped then.
+	Recorder record.EventRecorder
+	// Clock is what policies should read the time from, so that tests can fix
+	// it. The real clock is used if it is nil.
+	Clock clock.PassiveClock
+	// RequestUID and UserInfo identify the admission request being handled, if
+	// any. They end up in the audit log.
+	RequestUID string
+	UserInfo   *authenticationv1.UserInfo
+	// Operation is the admission operation, e.g. CREATE or UPDATE. It is empty
+	// outside admission.
+	Operation string
+	// OldObject is the object before an admission UPDATE, nil otherwise.
+	OldObject runtime.Object
+}
+
+func NewEnv(c client.Client) *Env {
+	return &Env{Client: c, Clock: clock.RealClock{}}
+}
+
+func (e *Env) now() time.Time {
+	if e.Clock == nil {
+		return time.Now().UTC()
+	}
+	return e.Clock.Now().UTC()
+}
+
+// creating reports whether the object is being created, which is assumed
+// outside admission. Policies changing immutable fields check it.
+func (e *Env) creating() bool {
+	return e.Operation == "" || e.Operation == "CREATE"
+}
+
+// Eventf records an Event on obj.
+func (e *Env) Eventf(obj runtime.Object, eventType string, reason string, format string, args ...interface{}) {
+	if e.Recorder == nil {
+		return
+	}
+	e.Recorder.Eventf(obj, eventType, reason, format, args...)
+}
+
+// Warningf records a Warning Event on obj.
+func (e *Env) Warningf(obj runtime.Object, reason string, format string, args ...interface{}) {
+	e.Eventf(obj, corev1.EventTypeWarning, reason, format, args...)
+}
+
+// warnDeprecatedAnnotations reports annotations of obj under a deprecated
+// prefix. They keep working until the prefix is removed from the config.
+func warnDeprecatedAnnotations(env *Env, obj runtime.Object) {
+	accessor, ok := obj.(client.Object)
+	if !ok {
+		return
+	}
+	deprecated := annotation.Deprecated(accessor.GetAnnotations())
+	if len(deprecated) == 0 {
+		return
+	}
+	policyLog.Info("object uses deprecated annotation prefixes", "object", client.ObjectKeyFromObject(accessor), "annotations", deprecated)
+	env.Warningf(obj, "DeprecatedAnnotation", "Annotations use a deprecated prefix, move them to %s: %s", config.Current().AnnotationPrefix, strings.Join(deprecated, ", "))
+}
+
+// warnInvalidAnnotations reports annotations of obj that failed to parse, both
+// as a Warning Event and in the log.
+func warnInvalidAnnotations(env *Env, p PolicyInterface, obj client.Object, err error) {
+	policyLog.Info("invalid annotations, skipping policy", "policy", p.Name(), "object", client.ObjectKeyFromObject(obj), "error", err.Error())
+	env.Warningf(obj, "InvalidAnnotation", "%s skipped: %v", p.Name(), err)
+}
+
+// DeniedError is returned by a policy to reject an object, as opposed to failing
+// to evaluate it.
+type DeniedError struct {
+	Message string
+}
+
+func (e *DeniedError) Error() string {
+	return e.Message
+}
+
+func Deny(format string, args ...interface{}) error {
+	return &DeniedError{Message: fmt.Sprintf(format, args...)}
+}
+
+func IsDenied(err error) bool {
+	var denied *DeniedError
+	return errors.As(err, &denied)
+}
+
+// Denied reports whether any of the decisions rejected the object.
+func Denied(decisions []audit.Entry) bool {
+	for _, decision := range decisions {
+		if decision.Outcome == audit.OutcomeDenied {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyFieldWatcher can be implemented by policies that need a reconcile when
+// fields change that don't bump the generation and aren't policy annotations.
+// Fields are dotted paths into the object, e.g. "metadata.labels".
+type PolicyFieldWatcher interface {
+	WatchedFields() []string
+}
+
+// PolicyDependencies can be implemented by policies whose outcome depends on
+// other objects, such as a referenced Secret or Service. The controller indexes
+// parents by their dependencies so that changing one re-reconciles every parent
+// depending on it.
+// Returned objects only need their type, name and namespace set.
+type PolicyDependencies interface {
+	Dependencies(obj runtime.Object) []client.Object
+}
+
+// PolicySources can be implemented by policies that copy objects into every
+// object of their type, such as a Secret into every Namespace. A change to a
+// source reconciles all objects of the policy type. Returned objects only need
+// their type, name and namespace set.
+type PolicySources interface {
+	Sources() []client.Object
+}
+
+// PolicyConfigValidator can be implemented by policies that read settings from
+// the config file, so that a bad value is rejected when the config is loaded.
+type PolicyConfigValidator interface {
+	ValidateConfig(cfg config.PolicyConfig) error
+}
+
+// PolicyAnnotations can be implemented by policies that read annotations. The
+// keys are registered under the policy key, which makes them known to the linter
+// and listed by explain.
+type PolicyAnnotations interface {
+	Annotations() []annotation.Key
+}
+
+// PolicyAdmission can be implemented by policies that mutate or reject objects
+// as they are admitted, rather than from a controller after they are stored.
+type PolicyAdmission interface {
+	Admission() bool
+}
+
+// Phase orders the policies of a type. Policies run by phase, and by key within
+// a phase, so the order doesn't depend on the order they are registered in.
+type Phase int
+
+const (
+	// PhaseDefault policies fill in what the object as written leaves unset.
+	PhaseDefault Phase = iota
+	// PhaseInject policies add containers and volumes, the default phase doesn't
+	// see them.
+	PhaseInject
+	// PhaseRewrite policies change the whole object, including what was
+	// injected.
+	PhaseRewrite
+	// PhaseValidate policies check the object as it will be stored, after every
+	// other policy changed it.
+	PhaseValidate
+)
+
+// PolicyPhase can be implemented by policies that must run before or after
+// other policies of their type. Policies that don't implement it run in
+// PhaseDefault.
+type PolicyPhase interface {
+	Phase() Phase
+}
+
+// PhaseOf returns the phase p runs in.
+func PhaseOf(p PolicyInterface) Phase {
+	if phased, ok := p.(PolicyPhase); ok {
+		return phased.Phase()
+	}
+	return PhaseDefault
+}
+
+// IsAdmission reports whether p runs at admission.
+func IsAdmission(p PolicyInterface) bool {
+	admission, ok := p.(PolicyAdmission)
+	return ok && admission.Admission()
+}
+
+// Key returns the name a policy is configured under, e.g.
+// "ingress-generate-gatus" for "Ingress Generate Gatus".
+func Key(p PolicyInterface) string {
+	return strings.ReplaceAll(strings.ToLower(p.Name()), " ", "-")
+}
+
+// Config returns the current settings of a policy.
+func Config(p PolicyInterface) config.PolicyConfig {
+	return config.Current().Policy(Key(p))
+}
+
+func RegisterPolicy(impl PolicyInterface) {
+	policyRegistry = append(policyRegistry, impl)
+	sort.SliceStable(policyRegistry, func(i, j int) bool {
+		if PhaseOf(policyRegistry[i]) != PhaseOf(policyRegistry[j]) {
+			return PhaseOf(policyRegistry[i]) < PhaseOf(policyRegistry[j])
+		}
+		return Key(policyRegistry[i]) < Key(policyRegistry[j])
+	})
+	if annotated, ok := impl.(PolicyAnnotations); ok {
+		annotation.Register(Key(impl), annotated.Annotations()...)
+	}
+}
+
+// AllPolicies returns every registered policy, ordered by phase and key.
+func AllPolicies() []PolicyInterface {
+	return policyRegistry
+}
+
+func PoliciesByType(policyType int) []PolicyInterface {
+	var policies []PolicyInterface
+	for _, p := range policyRegistry {
+		if p.Type() == policyType {
+			policies = append(policies, p)
+		}
+	}
+	return policies
+}
+
+// TypeForObject returns the policy type that handles obj.
+func TypeForObject(obj runtime.Object) int {
+	switch obj.(type) {
+	case *networkingv1.Ingress:
+		return PolicyTypeIngress
+	case *corev1.Pod:
+		return PolicyTypePod
+	case *corev1.Service:
+		return PolicyTypeService
+	case *appsv1.Deployment:
+		return PolicyTypeDeployment
+	case *appsv1.StatefulSet:
+		return PolicyTypeStatefulSet
+	case *corev1.Namespace:
+		return PolicyTypeNamespace
+	default:
+		return PolicyTypeUnknown
+	}
+}
+
+func WatchedFieldsByType(policyType int) []string {
+	var fields []string
+	for _, p := range PoliciesByType(policyType) {
+		if watcher, ok := p.(PolicyFieldWatcher); ok {
+			fields = append(fields, watcher.WatchedFields()...)
+		}
+	}
+	return fields
+}
+
+func DependenciesByType(policyType int, obj runtime.Object) []client.Object {
+	var dependencies []client.Object
+	for _, p := range PoliciesByType(policyType) {
+		if dependent, ok := p.(PolicyDependencies); ok {
+			dependencies = append(dependencies, dependent.Dependencies(obj)...)
+		}
+	}
+	return dependencies
+}
+
+func SourcesByType(policyType int) []client.Object {
+	var sources []client.Object
+	for _, p := range PoliciesByType(policyType) {
+		if copier, ok := p.(PolicySources); ok {
+			sources = append(sources, copier.Sources()...)
+		}
+	}
+	return sources
+}
+
+// ApplyPoliciesByType runs every enabled policy of the given type against obj,
+// writes each decision to the audit log and returns the decisions. Policies in
+// audit mode run against a copy of obj and a dry-run client, so their changes
+// are recorded as the patch of the decision and their writes are validated but
+// not persisted, and their denials are not enforced.
+func ApplyPoliciesByType(policyType int, obj runtime.Object, env *Env) ([]audit.Entry, error) {
+	return applyPolicies(PoliciesByType(policyType), obj, env)
+}
+
+// ApplyControllerPolicies is ApplyPoliciesByType for the policies that don't run
+// at admission.
+func ApplyControllerPolicies(policyType int, obj runtime.Object, env *Env) ([]audit.Entry, error) {
+	var policies []PolicyInterface
+	for _, p := range PoliciesByType(policyType) {
+		if !IsAdmission(p) {
+			policies = append(policies, p)
+		}
+	}
+	return applyPolicies(policies, obj, env)
+}
+
+// ApplyAdmissionPolicies is ApplyPoliciesByType for the policies that run at
+// admission.
+func ApplyAdmissionPolicies(policyType int, obj runtime.Object, env *Env) ([]audit.Entry, error) {
+	var policies []PolicyInterface
+	for _, p := range PoliciesByType(policyType) {
+		if IsAdmission(p) {
+			policies = append(policies, p)
+		}
+	}
+	return applyPolicies(policies, obj, env)
+}
+
+func applyPolicies(policies []PolicyInterface, obj runtime.Object, env *Env) ([]audit.Entry, error) {
+	var decisions []audit.Entry
+	warnDeprecatedAnnotations(env, obj)
+
+	var selection policySelection
+	if accessor, ok := obj.(client.Object); ok {
+		var err error
+		selection, err = selectPolicies(env, accessor)
+		if err != nil {
+			policyLog.Info("invalid policy selection", "object", client.ObjectKeyFromObject(accessor), "error", err.Error())
+			env.Warningf(obj, "InvalidAnnotation", "%v", err)
+		}
+	}
+
+	for _, p := range policies {
+		policyConfig := Config(p)
+		if !policyConfig.IsEnabled() {
+			policyLog.Info("policy disabled", "policy", p.Name())
+			continue
+		}
+
+		if !selection.selects(p) {
+			policyLog.Info("policy not selected", "policy", p.Name(), "selection", selection.origin)
+			entry := newEntry(p, obj, env)
+			decisions = append(decisions, recordDecision(entry, audit.NewRecordingClient(env.Client), audit.OutcomeSkipped, "not selected by "+policiesAnnotation.FullName()+" on "+selection.origin))
+			continue
+		}
+
+		entry, err := ApplyPolicy(p, obj, env)
+		decisions = append(decisions, entry)
+		if err != nil {
+			return decisions, err
+		}
+	}
+	return decisions, nil
+}
+
+// ApplyPolicy runs a single policy against obj and records its decision, whether
+// or not the policy is enabled or selected for obj. The decision carries the
+// JSON patch the policy made, or would have made in audit mode. Only errors
+// other than a denial are returned.
+func ApplyPolicy(p PolicyInterface, obj runtime.Object, env *Env) (audit.Entry, error) {
+	entry := newEntry(p, obj, env)
+
+	policyClient := env.Client
+	if entry.Mode == config.ModeAudit {
+		policyClient = client.NewDryRunClient(policyClient)
+	}
+	recorder := audit.NewRecordingClient(policyClient)
+	policyEnv := *env
+	policyEnv.Client = recorder
+
+	// Policies in audit mode run against a copy, their changes only end up in
+	// the patch of the decision.
+	target := obj
+	if entry.Mode == config.ModeAudit {
+		target = obj.DeepCopyObject()
+	}
+	before, marshalErr := json.Marshal(obj)
+	if marshalErr != nil {
+		policyLog.Error(marshalErr, "unable to snapshot object", "policy", p.Name())
+	}
+
+	policyLog.Info("applying policy", "policy", p.Name(), "mode", entry.Mode)
+	err, result := p.Validate(target, &policyEnv)
+	if err == nil && result {
+		err = p.Apply(target, &policyEnv)
+	}
+	if marshalErr == nil {
+		entry.Patch = decisionPatch(p, before, target)
+	}
+
+	switch {
+	case err != nil && IsDenied(err):
+		policyLog.Info("policy denied object", "policy", p.Name(), "reason", err.Error())
+		if entry.Mode == config.ModeAudit {
+			return recordDecision(entry, recorder, audit.OutcomeAudited, err.Error()), nil
+		}
+		return recordDecision(entry, recorder, audit.OutcomeDenied, err.Error()), nil
+	case err != nil:
+		policyLog.Error(err, "error running policy", "policy", p.Name())
+		return recordDecision(entry, recorder, audit.OutcomeError, err.Error()), err
+	case !result:
+		policyLog.Info("policy not applicable", "policy", p.Name())
+		return recordDecision(entry, recorder, audit.OutcomeSkipped, "policy not applicable"), nil
+	case entry.Mode == config.ModeAudit:
+		return recordDecision(entry, recorder, audit.OutcomeAudited, ""), nil
+	default:
+		return recordDecision(entry, recorder, audit.OutcomeApplied, ""), nil
+	}
+}
+
+// ReportViolations runs the admission policies in audit mode against an object
+// that already exists, and records a decision for each one it violates. Policies
+// are run against a copy of obj with a dry-run client, so nothing is changed.
+// Enforced policies are left out, they would have denied the object.
+func ReportViolations(policyType int, obj runtime.Object, env *Env) []audit.Entry {
+	var violations []audit.Entry
+	for _, p := range PoliciesByType(policyType) {
+		policyConfig := Config(p)
+		if !IsAdmission(p) || !policyConfig.IsEnabled() || policyConfig.PolicyMode() != config.ModeAudit {
+			continue
+		}
+
+		entry := newEntry(p, obj, env)
+		recorder := audit.NewRecordingClient(client.NewDryRunClient(env.Client))
+		policyEnv := *env
+		policyEnv.Client = recorder
+
+		copied := obj.DeepCopyObject()
+		err, result := p.Validate(copied, &policyEnv)
+		if err == nil && result {
+			err = p.Apply(copied, &policyEnv)
+		}
+		if err == nil || !IsDenied(err) {
+			continue
+		}
+
+		policyLog.Info("existing object violates policy", "policy", p.Name(), "object", entry.Object, "reason", err.Error())
+		env.Warningf(obj, "PolicyViolation", "%s: %v", p.Name(), err)
+		violations = append(violations, recordDecision(entry, recorder, audit.OutcomeAudited, err.Error()))
+	}
+	return violations
+}
+
+// decisionPatch returns the JSON patch a policy made to obj, from its JSON before
+// the policy ran, or nil if it made none.
+func decisionPatch(p PolicyInterface, before []byte, obj runtime.Object) json.RawMessage {
+	after, err := json.Marshal(obj)
+	if err != nil {
+		policyLog.Error(err, "unable to snapshot object", "policy", p.Name())
+		return nil
+	}
+	operations, err := jsonpatch.CreatePatch(before, after)
+	if err != nil {
+		policyLog.Error(err, "unable to create patch", "policy", p.Name())
+		return nil
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+	patch, err := json.Marshal(operations)
+	if err != nil {
+		policyLog.Error(err, "unable to marshal patch", "policy", p.Name())
+		return nil
+	}
+	return patch
+}
+
+func newEntry(p PolicyInterface, obj runtime.Object, env *Env) audit.Entry {
+	return audit.Entry{
+		Timestamp:  env.now(),
+		RequestUID: env.RequestUID,
+		UserInfo:   env.UserInfo,
+		Object:     audit.NewObjectRef(obj, env.Client.Scheme()),
+		Policy:     p.Name(),
+		Mode:       Config(p).PolicyMode(),
+	}
+}
+
+func recordDecision(entry audit.Entry, recorder *audit.RecordingClient, outcome string, message string) audit.Entry {
+	entry.Outcome = outcome
+	entry.Message = message
+	entry.Generated = recorder.Generated()
+	audit.Record(entry)
+	return entry
+}
+
+func RegisterPolicies() {
+	policies := AllPolicies()
+	for _, p := range policies {
+		policyLog.Info("registering policy", "policy", p.Name())
+	}
+}
+
+func validateConfig(cfg *config.Config) error {
+	policies := map[string]PolicyInterface{}
+	for _, p := range AllPolicies() {
+		policies[Key(p)] = p
+	}
+
+	for _, key := range cfg.PolicyKeys() {
+		p, ok := policies[key]
+		if !ok {
+			return fmt.Errorf("policies.%s: unknown policy", key)
+		}
+		if validator, ok := p.(PolicyConfigValidator); ok {
+			if err := validator.ValidateConfig(cfg.Policies[key]); err != nil {
+				return fmt.Errorf("policies.%s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func init() {
+	config.AddValidator(validateConfig)
+}
@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"github.com/aumer-amr/k8s-policy-control/pkg/memory"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestEnv returns an Env backed by a fake client holding objects.
+func newTestEnv(objects ...client.Object) *Env {
+	return NewEnv(fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithRESTMapper(memory.NewRESTMapper(clientgoscheme.Scheme)).
+		WithObjects(objects...).
+		Build())
+}
+
+// newNamespace returns the default Namespace with labels and annotations.
+func newNamespace(labels map[string]string, annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: labels, Annotations: annotations}}
+}
+
+// newPod returns the Pod web in the default Namespace with annotations and
+// containers.
+func newPod(annotations map[string]string, containers ...corev1.Container) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: annotations},
+		Spec:       corev1.PodSpec{Containers: containers},
+	}
+}
+
+// newDeployment returns the Deployment web in the default Namespace with
+// replicas and annotations, selecting its Pods by the app label.
+func newDeployment(replicas int32, annotations map[string]string) *appsv1.Deployment {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: annotations},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: selector,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: selector.MatchLabels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx"}}},
+			},
+		},
+	}
+}
+
+func TestApplyPolicyRecordsPatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		wantOutcome string
+		wantMutated bool
+	}{
+		{name: "enforce mutates the object", mode: config.ModeEnforce, wantOutcome: audit.OutcomeApplied, wantMutated: true},
+		{name: "audit leaves the object alone", mode: config.ModeAudit, wantOutcome: audit.OutcomeAudited, wantMutated: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Policies = map[string]config.PolicyConfig{"pod-security-defaults": {Mode: tt.mode}}
+			config.Set(cfg)
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			pod := newPod(nil, corev1.Container{Name: "web", Image: "nginx:1.25"})
+			env := newTestEnv()
+
+			entry, err := ApplyPolicy(PodSecurityDefaults{}, pod, env)
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != tt.wantOutcome {
+				t.Errorf("outcome = %q, want %q", entry.Outcome, tt.wantOutcome)
+			}
+			if mutated := pod.Spec.Containers[0].SecurityContext != nil; mutated != tt.wantMutated {
+				t.Errorf("object mutated = %v, want %v", mutated, tt.wantMutated)
+			}
+			if !strings.Contains(string(entry.Patch), `"path":"/spec/containers/0/securityContext"`) {
+				t.Errorf("patch = %s, want an add of /spec/containers/0/securityContext", entry.Patch)
+			}
+		})
+	}
+}
+
+func TestApplyPolicyWithoutChangesHasNoPatch(t *testing.T) {
+	config.Set(config.Default())
+
+	pod := newPod(nil, corev1.Container{Name: "web", Image: "nginx:1.25"})
+	env := newTestEnv()
+
+	entry, err := ApplyPolicy(PodInject{}, pod, env)
+	if err != nil {
+		t.Fatalf("ApplyPolicy() error = %v", err)
+	}
+	if entry.Patch != nil {
+		t.Errorf("patch = %s, want none", entry.Patch)
+	}
+}
+
+func TestPoliciesByTypeOrderedByPhase(t *testing.T) {
+	policies := PoliciesByType(PolicyTypePod)
+	for i := 1; i < len(policies); i++ {
+		if PhaseOf(policies[i]) < PhaseOf(policies[i-1]) {
+			t.Errorf("%s (phase %d) runs before %s (phase %d)", policies[i-1].Name(), PhaseOf(policies[i-1]), policies[i].Name(), PhaseOf(policies[i]))
+		}
+	}
+}
+
+func TestApplyPoliciesMirrorsBeforeCheckingRegistries(t *testing.T) {
+	cfg := config.Default()
+	cfg.Policies = map[string]config.PolicyConfig{
+		"pod-registry-mirror": {Settings: map[string]string{"mirrors": "docker.io=mirror.local/dockerhub"}},
+		"pod-image-policy":    {Settings: map[string]string{"allowedRegistries": "mirror.local"}},
+	}
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	pod := newPod(nil, corev1.Container{Name: "web", Image: "nginx:1.25"})
+	decisions, err := ApplyPoliciesByType(PolicyTypePod, pod, newTestEnv())
+	if err != nil {
+		t.Fatalf("ApplyPoliciesByType() error = %v", err)
+	}
+	for _, decision := range decisions {
+		if decision.Outcome == audit.OutcomeDenied {
+			t.Errorf("%s denied the Pod: %s", decision.Policy, decision.Message)
+		}
+	}
+	if got, want := pod.Spec.Containers[0].Image, "mirror.local/dockerhub/library/nginx:1.25"; got != want {
+		t.Errorf("image = %q, want %q", got, want)
+	}
+}
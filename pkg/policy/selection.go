@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// policiesAnnotation selects which policies run against an object. It is read
+// by the framework before any policy, so it works for every policy alike.
+var policiesAnnotation = annotation.Key{
+	Name:    "policies",
+	Type:    annotation.TypeList,
+	Inherit: true,
+	Description: "Comma separated policy keys or categories, e.g. ingress-generate-gatus or monitoring. " +
+		"Entries prefixed with ! are excluded. If any entry is not excluded only the listed policies run, * lists all of them. " +
+		"Exclusions win over inclusions.",
+}
+
+// PolicyCategories can be implemented by policies that belong to one or more
+// categories, so that they can be selected together with the policies
+// annotation.
+type PolicyCategories interface {
+	Categories() []string
+}
+
+// policySelection is the parsed policies annotation.
+type policySelection struct {
+	include []string
+	exclude []string
+	origin  string
+}
+
+// selects reports whether p runs under the selection.
+func (s policySelection) selects(p PolicyInterface) bool {
+	for _, entry := range s.exclude {
+		if matchesPolicy(entry, p) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, entry := range s.include {
+		if matchesPolicy(entry, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesPolicy(entry string, p PolicyInterface) bool {
+	if entry == "*" || entry == Key(p) {
+		return true
+	}
+	if categorized, ok := p.(PolicyCategories); ok {
+		for _, category := range categorized.Categories() {
+			if entry == category {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// knownSelectionEntry reports whether entry names a registered policy or
+// category.
+func knownSelectionEntry(entry string) bool {
+	if entry == "*" {
+		return true
+	}
+	for _, p := range AllPolicies() {
+		if matchesPolicy(entry, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectPolicies reads the policies annotation of obj, through owners,
+// Namespace and cluster defaults like any inheritable annotation. Unknown
+// entries are ignored and returned as the error, so that a typo can be reported
+// without turning policies on or off by accident.
+func selectPolicies(env *Env, obj client.Object) (policySelection, error) {
+	parser := annotationParser(env, obj)
+	entries := parser.List(policiesAnnotation)
+	if err := parser.Err(); err != nil {
+		return policySelection{}, err
+	}
+
+	var selection policySelection
+	if origin, ok := parser.Origin(policiesAnnotation); ok {
+		selection.origin = origin.String()
+	}
+
+	var unknown []string
+	for _, entry := range entries {
+		excluded := strings.HasPrefix(entry, "!")
+		entry = strings.TrimSpace(strings.TrimPrefix(entry, "!"))
+		if !knownSelectionEntry(entry) {
+			unknown = append(unknown, entry)
+			continue
+		}
+		if excluded {
+			selection.exclude = append(selection.exclude, entry)
+		} else {
+			selection.include = append(selection.include, entry)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return selection, fmt.Errorf("annotation %s: unknown policies or categories: %s", policiesAnnotation.FullName(), strings.Join(unknown, ", "))
+	}
+	return selection, nil
+}
+
+func init() {
+	annotation.Register(annotation.FrameworkOwner, policiesAnnotation)
+}
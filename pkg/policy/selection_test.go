@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+)
+
+func TestSelectPolicies(t *testing.T) {
+	policies := []PolicyInterface{PodInject{}, PodDefaultResources{}, PodImagePolicy{}, PodRegistryMirror{}, PodSecurityStandards{}}
+
+	tests := []struct {
+		name                 string
+		namespaceAnnotations map[string]string
+		annotations          map[string]string
+		want                 []string
+		wantOrigin           string
+		wantErr              string
+	}{
+		{
+			name: "no annotation selects everything",
+			want: []string{"pod-inject", "pod-default-resources", "pod-image-policy", "pod-registry-mirror", "pod-security-standards"},
+		},
+		{
+			name:        "opt in by key",
+			annotations: map[string]string{"policy-control.aumer.io/policies": "pod-inject, pod-security-standards"},
+			want:        []string{"pod-inject", "pod-security-standards"},
+			wantOrigin:  "object",
+		},
+		{
+			name:        "opt in by category",
+			annotations: map[string]string{"policy-control.aumer.io/policies": "images"},
+			want:        []string{"pod-image-policy", "pod-registry-mirror"},
+		},
+		{
+			name:        "opt out only keeps the rest",
+			annotations: map[string]string{"policy-control.aumer.io/policies": "!images, !pod-inject"},
+			want:        []string{"pod-default-resources", "pod-security-standards"},
+		},
+		{
+			name:        "everything but a category",
+			annotations: map[string]string{"policy-control.aumer.io/policies": "*, !security"},
+			want:        []string{"pod-inject", "pod-default-resources", "pod-image-policy", "pod-registry-mirror"},
+		},
+		{
+			name:        "exclusions win over inclusions",
+			annotations: map[string]string{"policy-control.aumer.io/policies": "images, !pod-registry-mirror"},
+			want:        []string{"pod-image-policy"},
+		},
+		{
+			name:                 "inherited from the namespace",
+			namespaceAnnotations: map[string]string{"policy-control.aumer.io/policies": "!injection"},
+			want:                 []string{"pod-default-resources", "pod-image-policy", "pod-registry-mirror", "pod-security-standards"},
+			wantOrigin:           "namespace Namespace/default",
+		},
+		{
+			name:                 "object replaces the namespace",
+			namespaceAnnotations: map[string]string{"policy-control.aumer.io/policies": "!injection"},
+			annotations:          map[string]string{"policy-control.aumer.io/policies": "injection"},
+			want:                 []string{"pod-inject"},
+			wantOrigin:           "object",
+		},
+		{
+			name:        "legacy prefix",
+			annotations: map[string]string{"k8s-ycl.bjw-s.dev/policies": "!resources"},
+			want:        []string{"pod-inject", "pod-image-policy", "pod-registry-mirror", "pod-security-standards"},
+		},
+		{
+			name:        "unknown entries are ignored and reported",
+			annotations: map[string]string{"policy-control.aumer.io/policies": "pod-injct, !imags, pod-inject"},
+			want:        []string{"pod-inject"},
+			wantErr:     "unknown policies or categories: pod-injct, imags",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Set(config.Default())
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			selection, err := selectPolicies(newTestEnv(newNamespace(nil, tt.namespaceAnnotations)), newPod(tt.annotations))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("selectPolicies() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("selectPolicies() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if !strings.HasPrefix(selection.origin, tt.wantOrigin) {
+				t.Errorf("origin = %q, want %q", selection.origin, tt.wantOrigin)
+			}
+
+			var selected []string
+			for _, p := range policies {
+				if selection.selects(p) {
+					selected = append(selected, Key(p))
+				}
+			}
+			if !reflect.DeepEqual(selected, tt.want) {
+				t.Errorf("selected = %q, want %q", selected, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPoliciesSkipsUnselected(t *testing.T) {
+	config.Set(config.Default())
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	pod := newPod(map[string]string{"policy-control.aumer.io/policies": "!pod-image-policy"})
+	decisions, err := ApplyPoliciesByType(PolicyTypePod, pod, newTestEnv())
+	if err != nil {
+		t.Fatalf("ApplyPoliciesByType() error = %v", err)
+	}
+	for _, decision := range decisions {
+		if decision.Policy != (PodImagePolicy{}).Name() {
+			continue
+		}
+		if decision.Outcome != audit.OutcomeSkipped || !strings.Contains(decision.Message, "not selected by policy-control.aumer.io/policies on object") {
+			t.Errorf("outcome = %q (%s), want skipped as not selected", decision.Outcome, decision.Message)
+		}
+		return
+	}
+	t.Error("no decision for the image policy")
+}
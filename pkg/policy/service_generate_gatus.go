@@ -0,0 +1,102 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var (
+	serviceGenerateGatusLog = ctrl.Log.WithName("service_generate_gatus")
+)
+
+const (
+	gatusDefaultServiceProtocol = "http"
+	gatusDefaultServicePath     = "/"
+)
+
+// ServiceGenerateGatus generates a Gatus endpoint probing a Service through its
+// cluster DNS name, for workloads that aren't exposed through an Ingress.
+type ServiceGenerateGatus struct {
+	Env *Env
+}
+
+func (s ServiceGenerateGatus) Name() string {
+	return "Service Generate Gatus"
+}
+
+func (s ServiceGenerateGatus) Type() int {
+	return PolicyTypeService
+}
+
+func (s ServiceGenerateGatus) Validate(obj runtime.Object, env *Env) (error, bool) {
+	s.Env = env
+
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		return fmt.Errorf("could not cast object to Service"), false
+	}
+
+	return nil, validateGatus(env, s, service, gatusGenerateServiceAnnotation, func(parser *annotation.Parser) (GatusEndpoint, error) {
+		return generateServiceGatusEndpoint(parser, service, Config(s))
+	})
+}
+
+func (s ServiceGenerateGatus) Apply(obj runtime.Object, env *Env) error {
+	s.Env = env
+
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		serviceGenerateGatusLog.Error(fmt.Errorf("could not cast object to Service"), "error casting object to Service")
+		return nil
+	}
+
+	return s.Handle(service, false)
+}
+
+func (s ServiceGenerateGatus) Handle(service *corev1.Service, fromValidate bool) error {
+	return handleGatusConfigMap(s.Env, Key(s), service, fromValidate, func(parser *annotation.Parser) (GatusEndpoint, error) {
+		return generateServiceGatusEndpoint(parser, service, Config(s))
+	})
+}
+
+func (s ServiceGenerateGatus) Categories() []string {
+	return gatusCategories
+}
+
+func (s ServiceGenerateGatus) Annotations() []annotation.Key {
+	return append([]annotation.Key{gatusGenerateServiceAnnotation}, gatusAnnotations...)
+}
+
+// WatchedFields reconciles Services whose ports or cluster IP change, the
+// generated endpoint is derived from them and a spec change of a Service doesn't
+// bump its generation.
+func (s ServiceGenerateGatus) WatchedFields() []string {
+	return []string{"spec.ports", "spec.clusterIP"}
+}
+
+func (s ServiceGenerateGatus) ValidateConfig(policyConfig config.PolicyConfig) error {
+	return validateGatusConfig(policyConfig)
+}
+
+func generateServiceGatusEndpoint(p *annotation.Parser, service *corev1.Service, policyConfig config.PolicyConfig) (GatusEndpoint, error) {
+	defaultHost := getObjectName(service) + "." + service.GetNamespace() + ".svc"
+	if len(service.Spec.Ports) > 0 {
+		defaultHost += ":" + strconv.Itoa(int(service.Spec.Ports[0].Port))
+	}
+
+	protocol := p.String(gatusProtocolAnnotation, policyConfig.Setting("protocol", gatusDefaultServiceProtocol))
+	host := p.String(gatusHostAnnotation, defaultHost)
+	path := p.String(gatusPathAnnotation, gatusDefaultServicePath)
+
+	return generateGatusEndpoint(p, service, policyConfig, protocol+"://"+host+path)
+}
+
+func init() {
+	RegisterPolicy(&ServiceGenerateGatus{})
+}
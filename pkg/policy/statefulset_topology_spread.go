@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// StatefulSetTopologySpread spreads the replicas of a StatefulSet over nodes by
+// adding topology spread constraints to its Pod template.
+type StatefulSetTopologySpread struct{}
+
+func (s StatefulSetTopologySpread) Name() string {
+	return "StatefulSet Topology Spread"
+}
+
+func (s StatefulSetTopologySpread) Type() int {
+	return PolicyTypeStatefulSet
+}
+
+func (s StatefulSetTopologySpread) Admission() bool {
+	return true
+}
+
+func (s StatefulSetTopologySpread) Validate(obj runtime.Object, env *Env) (error, bool) {
+	statefulSet, ok := obj.(*appsv1.StatefulSet)
+	if !ok {
+		return fmt.Errorf("could not cast object to StatefulSet"), false
+	}
+
+	return nil, validateTopologySpread(env, s, statefulSet, statefulSet.Spec.Replicas, statefulSet.Spec.Selector, &statefulSet.Spec.Template)
+}
+
+func (s StatefulSetTopologySpread) Apply(obj runtime.Object, env *Env) error {
+	statefulSet, ok := obj.(*appsv1.StatefulSet)
+	if !ok {
+		return fmt.Errorf("could not cast object to StatefulSet")
+	}
+
+	// Pods of a StatefulSet are replaced one by one in place, so every revision
+	// counts towards the spread.
+	return applyTopologySpread(env, s, statefulSet, statefulSet.Spec.Selector, &statefulSet.Spec.Template, nil)
+}
+
+func (s StatefulSetTopologySpread) Categories() []string {
+	return schedulingCategories
+}
+
+func (s StatefulSetTopologySpread) Annotations() []annotation.Key {
+	return topologySpreadAnnotations
+}
+
+func (s StatefulSetTopologySpread) ValidateConfig(policyConfig config.PolicyConfig) error {
+	return validateTopologySpreadConfig(policyConfig)
+}
+
+func init() {
+	RegisterPolicy(&StatefulSetTopologySpread{})
+}
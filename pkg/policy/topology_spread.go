@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/annotation"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	topologySpreadAnnotation = annotation.Key{
+		Name:        "topology-spread",
+		Inherit:     true,
+		Type:        annotation.TypeBool,
+		Default:     "true",
+		Description: "Spread the replicas of the workload over nodes. Set it to false to leave the Pod template as it is.",
+	}
+	topologySpreadZoneAnnotation = annotation.Key{
+		Name:        "topology-spread-zone",
+		Inherit:     true,
+		Type:        annotation.TypeBool,
+		Description: "Also spread the replicas over zones. Defaults to the zone setting of the policy.",
+	}
+	topologySpreadWhenUnsatisfiableAnnotation = annotation.Key{
+		Name:        "topology-spread-when-unsatisfiable",
+		Inherit:     true,
+		Type:        annotation.TypeEnum,
+		Allowed:     []string{string(corev1.ScheduleAnyway), string(corev1.DoNotSchedule)},
+		Description: "What the scheduler does with a replica it can't spread, defaults to the whenUnsatisfiable setting of the policy or ScheduleAnyway.",
+	}
+	topologyAntiAffinityAnnotation = annotation.Key{
+		Name:        "topology-anti-affinity",
+		Inherit:     true,
+		Type:        annotation.TypeBool,
+		Description: "Also prefer not to schedule replicas on the same node through Pod anti-affinity, unless the template has one. Defaults to the antiAffinity setting of the policy.",
+	}
+	topologySpreadLog = ctrl.Log.WithName("topology_spread")
+)
+
+const (
+	hostnameTopologyKey = "kubernetes.io/hostname"
+	zoneTopologyKey     = "topology.kubernetes.io/zone"
+	// antiAffinityWeight is high so that the preference wins over most others,
+	// without turning it into a requirement.
+	antiAffinityWeight = 100
+)
+
+// schedulingCategories select every policy changing where Pods run with the
+// policies annotation.
+var schedulingCategories = []string{"scheduling"}
+
+var topologySpreadAnnotations = []annotation.Key{
+	topologySpreadAnnotation,
+	topologySpreadZoneAnnotation,
+	topologySpreadWhenUnsatisfiableAnnotation,
+	topologyAntiAffinityAnnotation,
+}
+
+// topologySpreadSettings is what the topology spread policies add to a Pod
+// template.
+type topologySpreadSettings struct {
+	enabled           bool
+	zone              bool
+	whenUnsatisfiable corev1.UnsatisfiableConstraintAction
+	antiAffinity      bool
+}
+
+func resolveTopologySpreadSettings(parser *annotation.Parser, policyConfig config.PolicyConfig) (topologySpreadSettings, error) {
+	settings := topologySpreadSettings{}
+	settings.enabled, _ = parser.Bool(topologySpreadAnnotation)
+
+	var set bool
+	if settings.zone, set = parser.Bool(topologySpreadZoneAnnotation); !set {
+		settings.zone, _ = annotation.ParseBool(policyConfig.Setting("zone", "false"))
+	}
+	if settings.antiAffinity, set = parser.Bool(topologyAntiAffinityAnnotation); !set {
+		settings.antiAffinity, _ = annotation.ParseBool(policyConfig.Setting("antiAffinity", "false"))
+	}
+	settings.whenUnsatisfiable = corev1.UnsatisfiableConstraintAction(parser.String(topologySpreadWhenUnsatisfiableAnnotation, policyConfig.Setting("whenUnsatisfiable", string(corev1.ScheduleAnyway))))
+
+	return settings, parser.Err()
+}
+
+func validateTopologySpreadConfig(policyConfig config.PolicyConfig) error {
+	for _, setting := range []string{"zone", "antiAffinity"} {
+		if value := policyConfig.Setting(setting, ""); value != "" {
+			if _, err := annotation.ParseBool(value); err != nil {
+				return fmt.Errorf("settings.%s: %w", setting, err)
+			}
+		}
+	}
+	if value := policyConfig.Setting("whenUnsatisfiable", ""); value != "" {
+		if err := topologySpreadWhenUnsatisfiableAnnotation.Check(value); err != nil {
+			return fmt.Errorf("settings.whenUnsatisfiable: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateTopologySpread decides whether a topology spread policy applies to a
+// workload: it has more than one replica, a selector, and a template without
+// spread constraints.
+func validateTopologySpread(env *Env, p PolicyInterface, workload client.Object, replicas *int32, selector *metav1.LabelSelector, template *corev1.PodTemplateSpec) bool {
+	settings, err := resolveTopologySpreadSettings(annotationParser(env, workload), Config(p))
+	if err != nil {
+		warnInvalidAnnotations(env, p, workload, err)
+		return false
+	}
+	if !settings.enabled || selector == nil {
+		return false
+	}
+	// A missing replica count defaults to one.
+	if replicas == nil || *replicas <= 1 {
+		return false
+	}
+	return len(template.Spec.TopologySpreadConstraints) == 0
+}
+
+// applyTopologySpread adds spread constraints by hostname, and optionally zone,
+// to template, matching the Pods of the workload by its own selector. The label
+// keys are added to the selector of each Pod, e.g. pod-template-hash so that
+// only the current revision of a Deployment is counted.
+func applyTopologySpread(env *Env, p PolicyInterface, workload client.Object, selector *metav1.LabelSelector, template *corev1.PodTemplateSpec, matchLabelKeys []string) error {
+	settings, err := resolveTopologySpreadSettings(annotationParser(env, workload), Config(p))
+	if err != nil {
+		return err
+	}
+
+	topologyKeys := []string{hostnameTopologyKey}
+	if settings.zone {
+		topologyKeys = append(topologyKeys, zoneTopologyKey)
+	}
+	for _, topologyKey := range topologyKeys {
+		template.Spec.TopologySpreadConstraints = append(template.Spec.TopologySpreadConstraints, corev1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       topologyKey,
+			WhenUnsatisfiable: settings.whenUnsatisfiable,
+			LabelSelector:     selector.DeepCopy(),
+			MatchLabelKeys:    matchLabelKeys,
+		})
+	}
+
+	if settings.antiAffinity && (template.Spec.Affinity == nil || template.Spec.Affinity.PodAntiAffinity == nil) {
+		if template.Spec.Affinity == nil {
+			template.Spec.Affinity = &corev1.Affinity{}
+		}
+		template.Spec.Affinity.PodAntiAffinity = &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: antiAffinityWeight,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: selector.DeepCopy(),
+					TopologyKey:   hostnameTopologyKey,
+				},
+			}},
+		}
+	}
+
+	topologySpreadLog.Info("Added topology spread constraints", "policy", p.Name(), "workload", client.ObjectKeyFromObject(workload), "topologyKeys", topologyKeys, "antiAffinity", settings.antiAffinity)
+	return nil
+}
@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestDeploymentTopologySpread(t *testing.T) {
+	tests := []struct {
+		name             string
+		settings         map[string]string
+		deployment       *appsv1.Deployment
+		wantOutcome      string
+		wantTopologyKeys []string
+		wantAntiAffinity bool
+	}{
+		{
+			name:             "spread over nodes",
+			deployment:       newDeployment(3, nil),
+			wantOutcome:      audit.OutcomeApplied,
+			wantTopologyKeys: []string{hostnameTopologyKey},
+		},
+		{
+			name:             "zones and anti-affinity from settings",
+			settings:         map[string]string{"zone": "true", "antiAffinity": "true"},
+			deployment:       newDeployment(3, nil),
+			wantOutcome:      audit.OutcomeApplied,
+			wantTopologyKeys: []string{hostnameTopologyKey, zoneTopologyKey},
+			wantAntiAffinity: true,
+		},
+		{
+			name:             "annotation overrides settings",
+			settings:         map[string]string{"zone": "true"},
+			deployment:       newDeployment(3, map[string]string{"policy-control.aumer.io/topology-spread-zone": "false"}),
+			wantOutcome:      audit.OutcomeApplied,
+			wantTopologyKeys: []string{hostnameTopologyKey},
+		},
+		{
+			name:        "single replica",
+			deployment:  newDeployment(1, nil),
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "opted out",
+			deployment:  newDeployment(3, map[string]string{"policy-control.aumer.io/topology-spread": "false"}),
+			wantOutcome: audit.OutcomeSkipped,
+		},
+		{
+			name:        "invalid annotation",
+			deployment:  newDeployment(3, map[string]string{"policy-control.aumer.io/topology-spread-when-unsatisfiable": "Never"}),
+			wantOutcome: audit.OutcomeSkipped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Policies = map[string]config.PolicyConfig{"deployment-topology-spread": {Settings: tt.settings}}
+			config.Set(cfg)
+			t.Cleanup(func() { config.Set(config.Default()) })
+
+			entry, err := ApplyPolicy(DeploymentTopologySpread{}, tt.deployment, newTestEnv())
+			if err != nil {
+				t.Fatalf("ApplyPolicy() error = %v", err)
+			}
+			if entry.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q (%s), want %q", entry.Outcome, entry.Message, tt.wantOutcome)
+			}
+
+			spec := tt.deployment.Spec.Template.Spec
+			var topologyKeys []string
+			for _, constraint := range spec.TopologySpreadConstraints {
+				topologyKeys = append(topologyKeys, constraint.TopologyKey)
+				if len(constraint.MatchLabelKeys) != 1 || constraint.MatchLabelKeys[0] != appsv1.DefaultDeploymentUniqueLabelKey {
+					t.Errorf("matchLabelKeys = %v, want [%s]", constraint.MatchLabelKeys, appsv1.DefaultDeploymentUniqueLabelKey)
+				}
+			}
+			if len(topologyKeys) != len(tt.wantTopologyKeys) {
+				t.Fatalf("topology keys = %v, want %v", topologyKeys, tt.wantTopologyKeys)
+			}
+			for i := range topologyKeys {
+				if topologyKeys[i] != tt.wantTopologyKeys[i] {
+					t.Errorf("topology keys = %v, want %v", topologyKeys, tt.wantTopologyKeys)
+				}
+			}
+			if antiAffinity := spec.Affinity != nil && spec.Affinity.PodAntiAffinity != nil; antiAffinity != tt.wantAntiAffinity {
+				t.Errorf("anti-affinity = %v, want %v", antiAffinity, tt.wantAntiAffinity)
+			}
+		})
+	}
+}
+
+func TestTopologySpreadApplyRejectsOtherKinds(t *testing.T) {
+	pod := newPod(nil)
+	for _, p := range []PolicyInterface{DeploymentTopologySpread{}, StatefulSetTopologySpread{}} {
+		if err := p.Apply(pod, newTestEnv()); err == nil {
+			t.Errorf("%s Apply(Pod) error = nil, want a cast error", p.Name())
+		}
+	}
+}
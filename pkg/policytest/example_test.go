@@ -0,0 +1,93 @@
+package policytest_test
+
+import (
+	"fmt"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policytest"
+	"gomodules.xyz/jsonpatch/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// IngressClassDefault sets the ingressClassName of Ingresses that have none,
+// from the class setting of the policy.
+type IngressClassDefault struct{}
+
+func (i IngressClassDefault) Name() string {
+	return "Ingress Class Default"
+}
+
+func (i IngressClassDefault) Type() int {
+	return policy.PolicyTypeIngress
+}
+
+func (i IngressClassDefault) Validate(obj runtime.Object, env *policy.Env) (error, bool) {
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		return fmt.Errorf("could not cast object to Ingress"), false
+	}
+	return nil, ingress.Spec.IngressClassName == nil
+}
+
+func (i IngressClassDefault) Apply(obj runtime.Object, env *policy.Env) error {
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		return fmt.Errorf("could not cast object to Ingress")
+	}
+	class := policy.Config(i).Setting("class", "nginx")
+	ingress.Spec.IngressClassName = &class
+	env.Eventf(ingress, corev1.EventTypeNormal, "IngressClassDefaulted", "Set ingressClassName to %s", class)
+	return nil
+}
+
+// Policies are registered so that the config can refer to them.
+func init() {
+	policy.RegisterPolicy(&IngressClassDefault{})
+}
+
+// printT reports failed assertions on stdout, standing in for the *testing.T
+// of a real test.
+type printT struct{}
+
+func (printT) Helper() {}
+
+func (printT) Errorf(format string, args ...interface{}) {
+	fmt.Printf("error: "+format+"\n", args...)
+}
+
+func (printT) Fatalf(format string, args ...interface{}) {
+	panic(fmt.Sprintf(format, args...))
+}
+
+func Example() {
+	t := printT{}
+	policytest.SetConfig(t, `
+policies:
+  ingress-class-default:
+    settings:
+      class: traefik
+`)
+
+	h := policytest.New(t, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web"}})
+	result := h.Run(t, IngressClassDefault{}, &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "site"},
+	})
+
+	result.AssertNoError(t)
+	result.AssertOutcome(t, "ingress-class-default", "applied")
+	result.AssertPatch(t, jsonpatch.NewOperation("add", "/spec/ingressClassName", "traefik"))
+	event := result.AssertEvent(t, corev1.EventTypeNormal, "IngressClassDefaulted")
+	fmt.Println(event.Message)
+
+	// Running again finds the class set and skips the policy.
+	result = h.Run(t, IngressClassDefault{}, &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "site"},
+	})
+	result.AssertOutcome(t, "ingress-class-default", "skipped")
+	result.AssertNoPatch(t)
+	// Output:
+	// Set ingressClassName to traefik
+}
@@ -0,0 +1,140 @@
+// Package policytest runs policies against an in-memory object store, so that
+// policies can be table tested without a cluster or a manager.
+//
+//	func TestMyPolicy(t *testing.T) {
+//		tests := []struct {
+//			name    string
+//			ingress *networkingv1.Ingress
+//			outcome string
+//		}{...}
+//		for _, tt := range tests {
+//			t.Run(tt.name, func(t *testing.T) {
+//				h := policytest.New(t, namespace)
+//				result := h.Run(t, MyPolicy{}, tt.ingress)
+//				result.AssertOutcome(t, "my-policy", tt.outcome)
+//				result.AssertGenerated(t, "ConfigMap", "default", "my-config")
+//			})
+//		}
+//	}
+package policytest
+
+import (
+	"context"
+	"time"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/config"
+	"github.com/aumer-amr/k8s-policy-control/pkg/eval"
+	"github.com/aumer-amr/k8s-policy-control/pkg/memory"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Epoch is the time the clock of a new Harness starts at.
+var Epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// T is the part of testing.TB the harness uses.
+type T interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// Harness holds the store, recorder and clock policies run with.
+type Harness struct {
+	Scheme   *runtime.Scheme
+	Store    *memory.Client
+	Recorder *Recorder
+	Clock    *clocktesting.FakeClock
+}
+
+// New returns a harness using the client-go scheme with objects in its store,
+// such as the Namespaces and Services the policy reads.
+func New(t T, objects ...client.Object) *Harness {
+	t.Helper()
+	return NewWithScheme(t, clientgoscheme.Scheme, objects...)
+}
+
+func NewWithScheme(t T, scheme *runtime.Scheme, objects ...client.Object) *Harness {
+	t.Helper()
+
+	stored := make([]client.Object, 0, len(objects))
+	for _, obj := range objects {
+		stored = append(stored, obj.DeepCopyObject().(client.Object))
+	}
+	store, err := memory.NewClient(scheme, memory.NewRESTMapper(scheme), stored...)
+	if err != nil {
+		t.Fatalf("policytest: loading objects: %v", err)
+	}
+
+	return &Harness{
+		Scheme:   scheme,
+		Store:    store,
+		Recorder: &Recorder{},
+		Clock:    clocktesting.NewFakeClock(Epoch),
+	}
+}
+
+// SetConfig activates a config file given as YAML. The config is global, tests
+// that set it can't run in parallel.
+func SetConfig(t T, data string) {
+	t.Helper()
+	cfg, err := config.Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("policytest: config: %v", err)
+	}
+	config.Set(cfg)
+}
+
+// Env is what policies run with.
+func (h *Harness) Env() *policy.Env {
+	return &policy.Env{Client: h.Store, Recorder: h.Recorder, Clock: h.Clock}
+}
+
+// Run runs a single policy against obj, whether or not it is enabled, and
+// returns what it did. obj is added to the store first if it isn't there.
+func (h *Harness) Run(t T, p policy.PolicyInterface, obj client.Object) *Result {
+	t.Helper()
+	return h.run(t, obj, func(mutated client.Object, env *policy.Env) ([]audit.Entry, error) {
+		entry, err := policy.ApplyPolicy(p, mutated, env)
+		return []audit.Entry{entry}, err
+	})
+}
+
+// RunAll runs the whole pipeline for the type of obj, as the controller does:
+// every enabled policy the policies annotation selects.
+func (h *Harness) RunAll(t T, obj client.Object) *Result {
+	t.Helper()
+	policyType := policy.TypeForObject(obj)
+	if policyType == policy.PolicyTypeUnknown {
+		t.Fatalf("policytest: no policy type for %T", obj)
+	}
+	return h.run(t, obj, func(mutated client.Object, env *policy.Env) ([]audit.Entry, error) {
+		return policy.ApplyPoliciesByType(policyType, mutated, env)
+	})
+}
+
+func (h *Harness) run(t T, obj client.Object, apply func(client.Object, *policy.Env) ([]audit.Entry, error)) *Result {
+	t.Helper()
+
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := h.Store.Get(context.Background(), client.ObjectKeyFromObject(obj), existing); apierrors.IsNotFound(err) {
+		if err := h.Store.Create(context.Background(), obj.DeepCopyObject().(client.Object)); err != nil {
+			t.Fatalf("policytest: storing object: %v", err)
+		}
+	} else if err != nil {
+		t.Fatalf("policytest: reading object: %v", err)
+	}
+
+	seen := h.Recorder.len()
+	result, err := eval.Apply(h.Env(), h.Store, obj, apply)
+	return &Result{
+		Result: result,
+		Events: h.Recorder.Events()[seen:],
+		Err:    err,
+	}
+}
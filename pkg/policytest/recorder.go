@@ -0,0 +1,74 @@
+package policytest
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Event is an Event a policy recorded.
+type Event struct {
+	// Object is Kind/namespace/name of the object the Event is about, without
+	// the kind if the object carries no TypeMeta.
+	Object      string
+	Type        string
+	Reason      string
+	Message     string
+	Annotations map[string]string
+}
+
+// Recorder is an in-memory record.EventRecorder.
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *Recorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.AnnotatedEventf(object, nil, eventtype, reason, "%s", message)
+}
+
+func (r *Recorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.AnnotatedEventf(object, nil, eventtype, reason, messageFmt, args...)
+}
+
+func (r *Recorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, Event{
+		Object:      eventObject(object),
+		Type:        eventtype,
+		Reason:      reason,
+		Message:     fmt.Sprintf(messageFmt, args...),
+		Annotations: annotations,
+	})
+}
+
+// Events returns every Event recorded so far.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event{}, r.events...)
+}
+
+func (r *Recorder) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func eventObject(object runtime.Object) string {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return ""
+	}
+	name := accessor.GetName()
+	if accessor.GetNamespace() != "" {
+		name = accessor.GetNamespace() + "/" + name
+	}
+	if kind := object.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		name = kind + "/" + name
+	}
+	return name
+}
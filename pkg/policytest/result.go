@@ -0,0 +1,169 @@
+package policytest
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aumer-amr/k8s-policy-control/pkg/audit"
+	"github.com/aumer-amr/k8s-policy-control/pkg/eval"
+	"github.com/aumer-amr/k8s-policy-control/pkg/policy"
+	"gomodules.xyz/jsonpatch/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Result is what a run did: the decisions, the patch to the object, the
+// objects written to the store and the Events recorded.
+type Result struct {
+	eval.Result
+	Events []Event
+	// Err is an error other than a denial returned by a policy.
+	Err error
+}
+
+// AssertNoError fails if a policy returned an error.
+func (r *Result) AssertNoError(t T) {
+	t.Helper()
+	if r.Err != nil {
+		t.Errorf("unexpected error: %v", r.Err)
+	}
+}
+
+// AssertOutcome fails unless the policy, by name or key, decided outcome, e.g.
+// audit.OutcomeApplied.
+func (r *Result) AssertOutcome(t T, policyName string, outcome string) audit.Entry {
+	t.Helper()
+	for _, decision := range r.Decisions {
+		if decision.Policy != policyName && keyOf(decision.Policy) != policyName {
+			continue
+		}
+		if decision.Outcome != outcome {
+			t.Errorf("policy %s: expected outcome %s, got %s (%s)", policyName, outcome, decision.Outcome, decision.Message)
+		}
+		return decision
+	}
+	t.Errorf("policy %s: no decision, the policy didn't run", policyName)
+	return audit.Entry{}
+}
+
+func (r *Result) AssertDenied(t T) {
+	t.Helper()
+	if !r.Denied() {
+		t.Errorf("expected the object to be denied")
+	}
+}
+
+func (r *Result) AssertAllowed(t T) {
+	t.Helper()
+	if r.Denied() {
+		t.Errorf("expected the object to be allowed, got %s", describeDecisions(r.Decisions))
+	}
+}
+
+// AssertPatch fails unless the object was patched with exactly operations, in
+// any order.
+func (r *Result) AssertPatch(t T, operations ...jsonpatch.JsonPatchOperation) {
+	t.Helper()
+	expected := normalize(operations)
+	actual := normalize(r.Patch)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("patch mismatch\nexpected: %s\nactual:   %s", strings.Join(expected, "\n          "), strings.Join(actual, "\n          "))
+	}
+}
+
+func (r *Result) AssertNoPatch(t T) {
+	t.Helper()
+	if len(r.Patch) > 0 {
+		t.Errorf("expected no patch, got %s", strings.Join(normalize(r.Patch), ", "))
+	}
+}
+
+// AssertGenerated fails unless an object of kind was written to the store, and
+// returns it for further checks.
+func (r *Result) AssertGenerated(t T, kind string, namespace string, name string) client.Object {
+	t.Helper()
+	if obj := r.generated(kind, namespace, name); obj != nil {
+		return obj
+	}
+	t.Errorf("expected %s %s/%s to be generated, got %s", kind, namespace, name, describeObjects(r.Generated))
+	return nil
+}
+
+func (r *Result) AssertNotGenerated(t T, kind string, namespace string, name string) {
+	t.Helper()
+	if r.generated(kind, namespace, name) != nil {
+		t.Errorf("expected %s %s/%s not to be generated", kind, namespace, name)
+	}
+}
+
+// AssertEvent fails unless an Event of eventType with reason was recorded, and
+// returns the first one.
+func (r *Result) AssertEvent(t T, eventType string, reason string) Event {
+	t.Helper()
+	for _, event := range r.Events {
+		if event.Type == eventType && event.Reason == reason {
+			return event
+		}
+	}
+	t.Errorf("expected a %s Event with reason %s, got %v", eventType, reason, r.Events)
+	return Event{}
+}
+
+func (r *Result) AssertNoEvents(t T) {
+	t.Helper()
+	if len(r.Events) > 0 {
+		t.Errorf("expected no Events, got %v", r.Events)
+	}
+}
+
+func (r *Result) generated(kind string, namespace string, name string) client.Object {
+	for _, obj := range r.Generated {
+		if obj.GetObjectKind().GroupVersionKind().Kind == kind && obj.GetNamespace() == namespace && obj.GetName() == name {
+			return obj
+		}
+	}
+	return nil
+}
+
+func keyOf(name string) string {
+	for _, p := range policy.AllPolicies() {
+		if p.Name() == name {
+			return policy.Key(p)
+		}
+	}
+	return name
+}
+
+func normalize(operations []jsonpatch.JsonPatchOperation) []string {
+	normalized := make([]string, 0, len(operations))
+	for _, operation := range operations {
+		data, err := json.Marshal(operation)
+		if err != nil {
+			normalized = append(normalized, err.Error())
+			continue
+		}
+		normalized = append(normalized, string(data))
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+func describeObjects(objects []client.Object) string {
+	if len(objects) == 0 {
+		return "nothing"
+	}
+	names := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		names = append(names, obj.GetObjectKind().GroupVersionKind().Kind+" "+obj.GetNamespace()+"/"+obj.GetName())
+	}
+	return strings.Join(names, ", ")
+}
+
+func describeDecisions(decisions []audit.Entry) string {
+	parts := make([]string, 0, len(decisions))
+	for _, decision := range decisions {
+		parts = append(parts, decision.Policy+": "+decision.Outcome+" "+decision.Message)
+	}
+	return strings.Join(parts, "; ")
+}